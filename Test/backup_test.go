@@ -0,0 +1,195 @@
+package Test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func (s *backupSuite) TestSnapshot() {
+	var buffer bytes.Buffer
+	if err := s.db.Snapshot(&buffer, nil); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	data, _ := s.storage.Read()
+	if buffer.String() != string(data) {
+		s.Fail("Expected the stored database", fmt.Sprintf("Recibe: %s", buffer.String()))
+	}
+}
+
+func (s *backupSuite) TestSnapshot_DuringTransaction() {
+	tb, _ := s.db.GetTableByName("Users")
+	errorHandler(s.db.Begin())
+	errorHandler(tb.AddValues("maria", "40"))
+	var buffer bytes.Buffer
+	err := s.db.Snapshot(&buffer, nil)
+	errorHandler(s.db.Rollback())
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if strings.Contains(buffer.String(), "maria") || !strings.Contains(buffer.String(), "juan") {
+		s.Fail("Expected the database before the transaction", fmt.Sprintf("Recibe: %s", buffer.String()))
+	}
+}
+
+func (s *backupSuite) TestBackup_Encrypted() {
+	path := filepath.Join(s.T().TempDir(), "backup.txt")
+	kdf := &tdb.KeyDerivation{N: 1024, R: 8, P: 1}
+	err := s.db.Backup(path, &tdb.BackupOptions{EncryptionKey: "backup-secret", KeyDerivation: kdf})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "juan") {
+		s.Fail("Expected an encrypted backup", fmt.Sprintf("Recibe: %s", data))
+	}
+
+	if _, err = tdb.Open(path, &tdb.Options{EncryptionKey: "secret", ReadOnly: true}); !errors.Is(err, tdb.ErrInvalidKey) {
+		s.Fail("Expected ErrInvalidKey", fmt.Sprintf("Recibe: %v", err))
+	}
+	backup, err := tdb.Open(path, &tdb.Options{EncryptionKey: "backup-secret", ReadOnly: true})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ := backup.GetTableByName("Users")
+	if rows := tb.GetRows(); len(rows) != 2 || rows[1].SearchValue("name") != "juan" {
+		s.Fail("Expected the rows of the database", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *backupSuite) TestBackup_EncryptedColumns() {
+	storage := tdb.NewMemoryStorage()
+	kdf := &tdb.KeyDerivation{N: 1024, R: 8, P: 1}
+	db, _ := tdb.Create("testDbBackup.txt", &tdb.Options{
+		EncryptionKey:    "secret",
+		Storage:          storage,
+		KeyDerivation:    kdf,
+		EncryptedColumns: []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}},
+	})
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	errorHandler(tb.AddValues("pedro", "111"))
+
+	path := filepath.Join(s.T().TempDir(), "backup.txt")
+	if err := db.Backup(path, &tdb.BackupOptions{EncryptionKey: "backup-secret", KeyDerivation: kdf}); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	backup, err := tdb.Open(path, &tdb.Options{
+		EncryptionKey:    "backup-secret",
+		EncryptedColumns: []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}},
+	})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "pedro") || strings.Contains(string(data), "111") {
+		s.Fail("Expected only the column to be encrypted", fmt.Sprintf("Recibe: %s", data))
+	}
+	tb, _ = backup.GetTableByName("Users")
+	if rows := tb.GetRows(); len(rows) != 1 || rows[0].SearchValue("ssn") != "111" {
+		s.Fail("Expected the decrypted column", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *backupSuite) TestBackup_EncryptedChecksums() {
+	kdf := &tdb.KeyDerivation{N: 1024, R: 8, P: 1}
+	db, _ := tdb.Create("testDbBackup.txt", &tdb.Options{
+		EncryptionKey: "secret",
+		Storage:       tdb.NewMemoryStorage(),
+		KeyDerivation: kdf,
+		Checksums:     true,
+	})
+	tb, _ := db.NewTable("Users", []string{"name"})
+	errorHandler(tb.AddValues("pedro"))
+
+	path := filepath.Join(s.T().TempDir(), "backup.txt")
+	if err := db.Backup(path, &tdb.BackupOptions{EncryptionKey: "backup-secret", KeyDerivation: kdf}); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if _, err := tdb.Open(path, &tdb.Options{EncryptionKey: "backup-secret", Checksums: true, ReadOnly: true}); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *backupSuite) TestBackup_EncryptedColumnsChecksums() {
+	kdf := &tdb.KeyDerivation{N: 1024, R: 8, P: 1}
+	columns := []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}}
+	db, _ := tdb.Create("testDbBackup.txt", &tdb.Options{
+		EncryptionKey:    "secret",
+		Storage:          tdb.NewMemoryStorage(),
+		KeyDerivation:    kdf,
+		EncryptedColumns: columns,
+		Checksums:        true,
+	})
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	errorHandler(tb.AddValues("pedro", "111"))
+
+	path := filepath.Join(s.T().TempDir(), "backup.txt")
+	if err := db.Backup(path, &tdb.BackupOptions{EncryptionKey: "backup-secret", KeyDerivation: kdf}); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	backup, err := tdb.Open(path, &tdb.Options{EncryptionKey: "backup-secret", EncryptedColumns: columns, Checksums: true, ReadOnly: true})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ = backup.GetTableByName("Users")
+	if rows := tb.GetRows(); len(rows) != 1 || rows[0].SearchValue("ssn") != "111" {
+		s.Fail("Expected the decrypted column", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *backupSuite) TestBackup_Permissions() {
+	path := filepath.Join(s.T().TempDir(), "backup.txt")
+	errorHandler(os.WriteFile(path, []byte("old backup"), 0644))
+	if err := s.db.Backup(path, nil); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		s.Fail("Expected the backup to be 0600", fmt.Sprintf("Recibe: %s", info.Mode().Perm()))
+	}
+
+	dir := filepath.Join(s.T().TempDir(), "backups")
+	path, err := s.db.RotatingBackup(dir, 1, nil)
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0700 {
+		s.Fail("Expected the backup directory to be 0700", fmt.Sprintf("Recibe: %s", info.Mode().Perm()))
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		s.Fail("Expected the rotating backup to be 0600", fmt.Sprintf("Recibe: %s", info.Mode().Perm()))
+	}
+}
+
+func (s *backupSuite) TestRotatingBackup() {
+	dir := s.T().TempDir()
+	errorHandler(os.WriteFile(filepath.Join(dir, "testDbCsv-notes.txt"), []byte("keep"), 0644))
+	var paths []string
+	for range 3 {
+		path, err := s.db.RotatingBackup(dir, 2, nil)
+		if err != nil {
+			s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+		}
+		paths = append(paths, path)
+	}
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		s.Fail("Expected the oldest backup to be deleted", fmt.Sprintf("Recibe: %v", err))
+	}
+	for _, path := range append(paths[1:], filepath.Join(dir, "testDbCsv-notes.txt")) {
+		if _, err := os.Stat(path); err != nil {
+			s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+		}
+	}
+	if _, err := s.db.RotatingBackup(dir, 0, nil); err == nil {
+		s.Fail("Expected an error", "Recibe: nil")
+	}
+}
+
+func TestBackup(t *testing.T) {
+	suite.Run(t, new(backupSuite))
+}
@@ -0,0 +1,175 @@
+package Test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// damage breaks a row of Users, removes its insertion marker and adds a duplicate id and a dangling reference to Houses
+func (s *checkSuite) damage() {
+	data, _ := s.storage.Read()
+	raw := strings.Replace(string(data), "|1| 2 |2| juan |3| 30\n!*!\n", "|1| 2 |2| juan\n", 1)
+	raw = strings.Replace(raw, "|1| 1 |2| pedro_avenue |3| 1\n", "|1| 1 |2| pedro_avenue |3| 1\n|1| 1 |2| copy_avenue |3| 1\n|1| 2 |2| lost_avenue |3| 9\n", 1)
+	errorHandler(s.storage.Write([]byte(raw)))
+}
+
+func (s *checkSuite) TestCheck() {
+	report, err := s.db.Check()
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if !report.OK() || len(report.Issues) != 0 || report.Tables != 2 || report.Rows != 3 {
+		s.Fail("Expected a healthy database", fmt.Sprintf("Recibe: %+v", report))
+	}
+}
+
+func (s *checkSuite) TestCheck_DeletedColumn() {
+	tb, _ := s.db.GetTableByName("Users")
+	errorHandler(tb.DeleteColumn("name"))
+	if report, _ := s.db.Check(); !report.OK() {
+		s.Fail("Expected a healthy database", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+}
+
+func (s *checkSuite) TestCheck_LegacyLinks() {
+	data, _ := s.storage.Read()
+	segments := strings.Split(strings.TrimPrefix(string(data), "FMT 1\n"), "////")
+	for i, segment := range segments {
+		if strings.Contains(segment, "-----__tdb_meta-----") {
+			segments[i] = "\n-----Links-----\n[1] id [2] table1 [3] columnLink1 [3] table2 [4] columnLink2\n" +
+				"|1| 1 |2| Users |3| id |3| Houses |4| id_owner\n!*!\n-----Links_End-----\n"
+		}
+	}
+	errorHandler(s.storage.Write([]byte(strings.Join(segments, "////"))))
+	report, err := tdb.Check("testDbCsv.txt", &tdb.Options{Storage: s.storage})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Problem != tdb.ProblemVersion || report.Issues[0].Table != "Links" {
+		s.Fail("Expected only the legacy Links notice", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+}
+
+func (s *checkSuite) TestCheck_ReportEveryProblem() {
+	s.damage()
+	if _, err := tdb.Open("testDbCsv.txt", &tdb.Options{Storage: s.storage}); err == nil {
+		s.Fail("Expected an error", "Recibe: nil")
+	}
+	report, err := tdb.Check("testDbCsv.txt", &tdb.Options{Storage: s.storage})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	expected := []tdb.CheckIssue{
+		{Problem: tdb.ProblemMarker, Table: "Users", Line: 9, Message: "insertion marker not found"},
+		{Problem: tdb.ProblemFieldCount, Table: "Users", Line: 12, Message: "row has 2 fields, expected 3"},
+		{Problem: tdb.ProblemDuplicateId, Table: "Houses", Line: 18, Message: "id 1 is already used by line 17"},
+		{Problem: tdb.ProblemForeignKey, Table: "Houses", Line: 19, Message: "foreign key violation: Houses.id_owner value 9 not found in Users.id"},
+	}
+	if len(report.Issues) != len(expected) {
+		s.FailNow("Expected 4 issues", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+	for i, issue := range report.Issues {
+		if issue.Problem != expected[i].Problem || issue.Table != expected[i].Table || issue.Line != expected[i].Line ||
+			issue.Message != expected[i].Message || issue.Repaired {
+			s.Fail(fmt.Sprintf("Expected %+v", expected[i]), fmt.Sprintf("Recibe: %+v", issue))
+		}
+	}
+	if report.OK() {
+		s.Fail("Expected problems", "Recibe: OK")
+	}
+}
+
+func (s *checkSuite) TestRepair() {
+	s.damage()
+	report, err := tdb.Repair("testDbCsv.txt", &tdb.Options{Storage: s.storage})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if report.Quarantined != 2 || report.OK() {
+		s.Fail("Expected 2 quarantined rows and the foreign key left", fmt.Sprintf("Recibe: %+v", report))
+	}
+	if index := slices.IndexFunc(report.Issues, func(issue tdb.CheckIssue) bool { return !issue.Repaired }); report.Issues[index].Problem != tdb.ProblemForeignKey {
+		s.Fail("Expected only the foreign key not repaired", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+
+	db, err := tdb.Open("testDbCsv.txt", &tdb.Options{Storage: s.storage})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	users, _ := db.GetTableByName("Users")
+	houses, _ := db.GetTableByName("Houses")
+	if len(users.GetRows()) != 1 || len(houses.GetRows()) != 2 {
+		s.Fail("Expected the readable rows", fmt.Sprintf("Recibe: %v %v", users.GetRows(), houses.GetRows()))
+	}
+	rows, err := db.SystemTableRows("__tdb_quarantine")
+	if err != nil || len(rows) != 2 {
+		s.FailNow("Expected 2 quarantined rows", fmt.Sprintf("Recibe: %v %v", rows, err))
+	}
+	if rows[0].SearchValue("table") != "Users" || rows[0].SearchValue("line") != "12" || !strings.Contains(rows[0].SearchValue("content"), "juan") {
+		s.Fail("Expected the row of juan", fmt.Sprintf("Recibe: %v", rows[0]))
+	}
+	if rows[1].SearchValue("problem") != "duplicate id" || !strings.Contains(rows[1].SearchValue("content"), "copy_avenue") {
+		s.Fail("Expected the duplicated house", fmt.Sprintf("Recibe: %v", rows[1]))
+	}
+
+	report, _ = db.Check()
+	if len(report.Issues) != 1 || report.Issues[0].Problem != tdb.ProblemForeignKey {
+		s.Fail("Expected the foreign key issue", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+}
+
+func (s *checkSuite) TestRepair_Checksums() {
+	storage := tdb.NewMemoryStorage()
+	config := tdb.DbConfig{DatabaseName: "testDbCheck.txt", Storage: storage, Checksums: true}
+	_, _ = config.CreateDatabase()
+	data, _ := storage.Read()
+	errorHandler(storage.Write([]byte(strings.Replace(string(data), "|3| 32", "|3| 33", 1))))
+
+	report, _ := tdb.Check("testDbCheck.txt", &tdb.Options{Storage: storage})
+	if len(report.Issues) != 1 || report.Issues[0].Problem != tdb.ProblemChecksum || report.Issues[0].Table != "Users" {
+		s.FailNow("Expected a checksum issue in Users", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+	if report, _ = tdb.Repair("testDbCheck.txt", &tdb.Options{Storage: storage}); !report.OK() {
+		s.Fail("Expected the checksums to be repaired", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+	if _, err := tdb.Open("testDbCheck.txt", &tdb.Options{Storage: storage}); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *checkSuite) TestCheck_Encrypted() {
+	storage := tdb.NewMemoryStorage()
+	opts := &tdb.Options{EncryptionKey: "secret", Storage: storage, KeyDerivation: &tdb.KeyDerivation{N: 1024, R: 8, P: 1}}
+	_, _ = tdb.Create("testDbCheck.txt", opts)
+
+	if report, _ := tdb.Check("testDbCheck.txt", opts); !report.OK() {
+		s.Fail("Expected a healthy database", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+	report, _ := tdb.Check("testDbCheck.txt", &tdb.Options{Storage: storage})
+	if len(report.Issues) != 1 || report.Issues[0].Problem != tdb.ProblemEncryption {
+		s.Fail("Expected an encryption issue", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+
+	data, _ := storage.Read()
+	errorHandler(storage.Write(data[:len(data)-8]))
+	report, _ = tdb.Check("testDbCheck.txt", opts)
+	if len(report.Issues) != 1 || report.Issues[0].Problem != tdb.ProblemEncryption {
+		s.Fail("Expected an encryption issue", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+}
+
+func (s *checkSuite) TestRepair_ReadOnly() {
+	db, _ := tdb.Open("testDbCsv.txt", &tdb.Options{Storage: s.storage, ReadOnly: true})
+	if _, err := db.Repair(); !errors.Is(err, tdb.ErrReadOnly) {
+		s.Fail("Expected ErrReadOnly", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	suite.Run(t, new(checkSuite))
+}
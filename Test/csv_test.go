@@ -0,0 +1,134 @@
+package Test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func (s *csvSuite) TestExportCSV() {
+	tb, _ := s.db.GetTableByName("Users")
+	errorHandler(tb.AddValues("lopez, maria \"mary\"", "line one\nline two"))
+	var buffer bytes.Buffer
+	if err := tb.ExportCSV(&buffer); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		s.Fail("Expected a valid CSV", fmt.Sprintf("Recibe: %s", err))
+	}
+	if len(records) != 4 || !reflect.DeepEqual(records[0], []string{"id", "name", "age"}) || !reflect.DeepEqual(records[1], []string{"1", "pedro perez", "20"}) {
+		s.Fail("Expected the header and the rows", fmt.Sprintf("Recibe: %v", records))
+	}
+	if len(records) == 4 && (records[3][1] != "lopez, maria \"mary\"" || records[3][2] != "line one\nline two") {
+		s.Fail("Expected the quoted values", fmt.Sprintf("Recibe: %q", records[3]))
+	}
+}
+
+func (s *csvSuite) TestImportCSV_CreateTable() {
+	file := "id,direction,owner\n10,\"juan avenue, 5\",juan\n11,\"first line\nsecond line\",\n"
+	imported, err := s.db.ImportCSV("Addresses", strings.NewReader(file), nil)
+	if err != nil || imported != 2 {
+		s.Fail("Expected 2 rows imported", fmt.Sprintf("Recibe: %d %v", imported, err))
+	}
+	tb, err := s.db.GetTableByName("Addresses")
+	if err != nil {
+		s.FailNow("Expected Addresses table", fmt.Sprintf("Recibe: %s", err))
+	}
+	row, err := tb.GetRowById("10")
+	if err != nil || row.SearchValue("direction") != "juan avenue, 5" || row.SearchValue("owner") != "juan" {
+		s.Fail("Expected the row with its id", fmt.Sprintf("Recibe: %s %v", row.String(), err))
+	}
+	rows := tb.SearchAll("direction", "first line\nsecond line")
+	if len(rows) != 1 || rows[0].SearchValue("owner") != "null" {
+		s.Fail("Expected the multi-line value with a null owner", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *csvSuite) TestImportCSV_MapColumns() {
+	file := "full name;years\nmaria lopez;41\n"
+	imported, err := s.db.ImportCSV("Users", strings.NewReader(file), &tdb.CSVOptions{
+		Comma:   ';',
+		Columns: map[string]string{"full name": "name", "years": "age"},
+	})
+	if err != nil || imported != 1 {
+		s.Fail("Expected 1 row imported", fmt.Sprintf("Recibe: %d %v", imported, err))
+	}
+	tb, _ := s.db.GetTableByName("Users")
+	row, err := tb.SearchOne("name", "maria lopez")
+	if err != nil || row.SearchValue("age") != "41" || row.SearchValue("id") == "" {
+		s.Fail("Expected maria lopez with a generated id", fmt.Sprintf("Recibe: %s %v", row.String(), err))
+	}
+}
+
+func (s *csvSuite) TestImportCSV_GenerateIds() {
+	file := "id,name\n1,maria\n"
+	imported, err := s.db.ImportCSV("Users", strings.NewReader(file), &tdb.CSVOptions{GenerateIds: true})
+	if err != nil || imported != 1 {
+		s.Fail("Expected 1 row imported", fmt.Sprintf("Recibe: %d %v", imported, err))
+	}
+	tb, _ := s.db.GetTableByName("Users")
+	row, _ := tb.SearchOne("name", "maria")
+	if row.SearchValue("id") == "1" || row.SearchValue("age") != "null" {
+		s.Fail("Expected a new id and a null age", fmt.Sprintf("Recibe: %s", row.String()))
+	}
+}
+
+func (s *csvSuite) TestImportCSV_ReportLineErrors() {
+	file := "id,direction,id_owner\n5,north,2\n1,duplicated,1\n6,too,many,fields\n7,orphan,9\n8,south,1\n"
+	imported, err := s.db.ImportCSV("Houses", strings.NewReader(file), nil)
+	var importError *tdb.ImportError
+	if !errors.As(err, &importError) {
+		s.FailNow("Expected ImportError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if imported != 2 {
+		s.Fail("Expected 2 rows imported", fmt.Sprintf("Recibe: %d", imported))
+	}
+	lines := importError.Lines()
+	if len(lines) != 3 || lines[0].Line != 3 || lines[1].Line != 4 || lines[2].Line != 5 {
+		s.FailNow("Expected errors on lines 3, 4 and 5", fmt.Sprintf("Recibe: %v", lines))
+	}
+	var violation *tdb.ForeignKeyViolationError
+	if !errors.As(lines[2], &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", lines[2]))
+	}
+	tb, _ := s.db.GetTableByName("Houses")
+	if len(tb.GetRows()) != 3 {
+		s.Fail("Expected len of 3", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+}
+
+func (s *csvSuite) TestImportCSV_UnknownColumn() {
+	imported, err := s.db.ImportCSV("Users", strings.NewReader("name,email\nmaria,maria@example.com\n"), nil)
+	var notFound *tdb.NotFoundError
+	if !errors.As(err, &notFound) || imported != 0 {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %d %v", imported, err))
+	}
+}
+
+func (s *csvSuite) TestImportCSV_ExportedTable() {
+	tb, _ := s.db.GetTableByName("Users")
+	var buffer bytes.Buffer
+	errorHandler(tb.ExportCSV(&buffer))
+	if _, err := s.db.ImportCSV("UsersCopy", &buffer, nil); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	copied, _ := s.db.GetTableByName("UsersCopy")
+	if !reflect.DeepEqual(copied.GetColumns(), tb.GetColumns()) || len(copied.GetRows()) != 2 {
+		s.Fail("Expected the same table", fmt.Sprintf("Recibe: %v %d", copied.GetColumns(), len(copied.GetRows())))
+	}
+	row, _ := copied.GetRowById("1")
+	if row.SearchValue("name") != "pedro perez" {
+		s.Fail("Expected pedro perez", fmt.Sprintf("Recibe: %s", row.SearchValue("name")))
+	}
+}
+
+func TestCsv(t *testing.T) {
+	suite.Run(t, new(csvSuite))
+}
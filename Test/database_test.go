@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/sheymor21/text-database/tdb"
 	"github.com/stretchr/testify/suite"
+	"strings"
 	"testing"
 )
 
@@ -20,13 +21,13 @@ func (s *databaseSuite) TestGetTables() {
 	}
 }
 func (s *databaseSuite) TestNewTable() {
-	tb := s.db.NewTable("Test", []string{"name", "age"})
+	tb, _ := s.db.NewTable("Test", []string{"name", "age"})
 	if tb.GetName() != "-----Test-----" {
 		s.Fail("Expected Test Table", fmt.Sprintf("Recibe: %s", tb))
 	}
 }
 func (s *databaseSuite) TestDeleteTable() {
-	s.db.NewTable("Test", []string{"name", "age"})
+	_, _ = s.db.NewTable("Test", []string{"name", "age"})
 	err := s.db.DeleteTable("Test")
 	if err != nil {
 		s.ErrFail(err)
@@ -90,6 +91,13 @@ func (s *databaseSuite) TestFromSql_Select_Where() {
 	}
 }
 
+func (s *databaseSuite) TestFromSql_Select_TableNotFound() {
+	_, err := s.db.FromSql("SELECT name FROM Nope")
+	var notFound *tdb.NotFoundError
+	if !errors.As(err, &notFound) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
 func (s *databaseSuite) TestFromSql_Update() {
 	data, err := s.db.FromSql("Update Users SET age = 25,name = pepe WHERE age = 32")
 	if err != nil {
@@ -145,6 +153,148 @@ func (s *databaseSuite) TestFromSql_Drop() {
 		s.ErrFail(err)
 	}
 }
+func (s *databaseSuite) TestFromSql_CreateTable() {
+	_, err := s.db.FromSql("CREATE TABLE Houses (direction, id_owner)")
+	if err != nil {
+		s.ErrFail(err)
+	}
+	tb, err := s.db.GetTableByName("Houses")
+	if err != nil {
+		s.ErrFail(err)
+	}
+	columns := strings.TrimSpace(strings.Join(tb.GetColumns(), " "))
+	if columns != "[1] id [2] direction [3] id_owner" {
+		s.Fail("Expected [1] id [2] direction [3] id_owner", fmt.Sprintf("Recibe: %s", columns))
+	}
+}
+func (s *databaseSuite) TestFromSql_AlterTable() {
+	for _, sql := range []string{
+		"ALTER TABLE Users DROP COLUMN age",
+		"ALTER TABLE Users ADD COLUMN email",
+		"ALTER TABLE Users RENAME COLUMN email TO mail",
+		"ALTER TABLE Users RENAME TO Customers",
+	} {
+		if _, err := s.db.FromSql(sql); err != nil {
+			s.Fail("Expected nil", fmt.Sprintf("Recibe: %s %s", sql, err))
+		}
+	}
+	tb, err := s.db.GetTableByName("Customers")
+	if err != nil {
+		s.ErrFail(err)
+	}
+	columns := strings.TrimSpace(strings.Join(tb.GetColumns(), " "))
+	if columns != "[1] id [2] name [3] mail" {
+		s.Fail("Expected [1] id [2] name [3] mail", fmt.Sprintf("Recibe: %s", columns))
+	}
+	_, err = s.db.FromSql("ALTER TABLE Customers MODIFY name")
+	var example *tdb.SqlSyntaxError
+	if !errors.As(err, &example) {
+		s.Fail("Expected SqlSyntaxError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+func (s *databaseReadOnlySuite) TestReadOnly_MissingDatabase() {
+	config := tdb.DbConfig{DatabaseName: "testDbMissing.txt", ReadOnly: true}
+	_, err := config.CreateDatabase()
+	var example *tdb.NotFoundError
+	if !errors.As(err, &example) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+func (s *databaseReadOnlySuite) TestReadOnly_Select() {
+	data, err := s.db.FromSql("SELECT * FROM Users")
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if len(data.Rows) != 4 {
+		s.Fail("Expected len of 4", fmt.Sprintf("Recibe: %d", len(data.Rows)))
+	}
+}
+func (s *databaseReadOnlySuite) TestReadOnly_ReturnReadOnlyError() {
+	if _, err := s.db.NewTable("Test", []string{"name"}); !errors.Is(err, tdb.ErrReadOnly) {
+		s.Fail("Expected ErrReadOnly", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := s.db.DeleteTable("Users"); !errors.Is(err, tdb.ErrReadOnly) {
+		s.Fail("Expected ErrReadOnly", fmt.Sprintf("Recibe: %v", err))
+	}
+	if _, err := s.db.FromSql("Delete FROM Users WHERE age = 54"); !errors.Is(err, tdb.ErrReadOnly) {
+		s.Fail("Expected ErrReadOnly", fmt.Sprintf("Recibe: %v", err))
+	}
+	tb, _ := s.db.GetTableByName("Users")
+	if err := tb.AddValues("test", "20"); !errors.Is(err, tdb.ErrReadOnly) {
+		s.Fail("Expected ErrReadOnly", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := tb.UpdateValue("age", "2", "30"); !errors.Is(err, tdb.ErrReadOnly) {
+		s.Fail("Expected ErrReadOnly", fmt.Sprintf("Recibe: %v", err))
+	}
+	tb, _ = s.db.GetTableByName("Users")
+	if len(tb.GetRows()) != 4 {
+		s.Fail("Expected len of 4", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+}
+func (s *databaseOpenSuite) TestOpen_ReturnNotFoundError() {
+	_, err := tdb.Open("testDbOpen.txt", nil)
+	var example *tdb.NotFoundError
+	if !errors.As(err, &example) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+func (s *databaseOpenSuite) TestOpen_NeverWrites() {
+	storage := tdb.NewMemoryStorage()
+	_, _ = tdb.Create("testDbOpen.txt", &tdb.Options{
+		Storage: storage,
+		Seed:    []tdb.DataConfig{{TableName: "Users", Columns: []string{"name"}, Values: []tdb.Values{{"1", "carlos"}}}},
+	})
+	before, _ := storage.Read()
+	db, err := tdb.Open("testDbOpen.txt", &tdb.Options{Storage: storage, EncryptionKey: "secret"})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if after, _ := storage.Read(); string(after) != string(before) {
+		s.Fail("Expected Open not to write the database", fmt.Sprintf("Recibe: %s", after))
+	}
+	tb, _ := db.GetTableByName("Users")
+	if len(tb.GetRows()) != 1 {
+		s.Fail("Expected len of 1", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+}
+func (s *databaseOpenSuite) TestCreate_Empty() {
+	db, err := tdb.Create("testDbOpen.txt", nil)
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if len(db.GetTables()) != 0 {
+		s.Fail("Expected 0 Tables", fmt.Sprintf("Recibe: %d", len(db.GetTables())))
+	}
+}
+func (s *databaseOpenSuite) TestCreate_ReturnAlreadyExistsError() {
+	_, _ = tdb.Create("testDbOpen.txt", nil)
+	_, err := tdb.Create("testDbOpen.txt", nil)
+	if !errors.Is(err, tdb.ErrAlreadyExists) {
+		s.Fail("Expected ErrAlreadyExists", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+func (s *databaseOpenSuite) TestOpenOrCreate_Seed() {
+	opts := &tdb.Options{
+		Seed: []tdb.DataConfig{
+			{TableName: "DataTest", Columns: []string{"name", "age"}, Values: []tdb.Values{{"1", "carlos", "32"}}},
+		},
+	}
+	_, _ = tdb.OpenOrCreate("testDbOpen.txt", opts)
+	db, err := tdb.OpenOrCreate("testDbOpen.txt", opts)
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, err := db.GetTableByName("DataTest")
+	if err != nil {
+		s.Fail("Expected DataTest Table", fmt.Sprintf("Recibe: %s", err))
+	}
+	if len(tb.GetRows()) != 1 {
+		s.Fail("Expected len of 1", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+	if _, err = db.GetTableByName("Users"); err == nil {
+		s.Fail("Expected no Users Table")
+	}
+}
 func TestDatabase(t *testing.T) {
 	t.Run("TestSet: Database", func(t *testing.T) {
 		suite.Run(t, &databaseSuite{})
@@ -157,4 +307,12 @@ func TestDatabase(t *testing.T) {
 	t.Run("TestSet: DatabaseWithStaticData", func(t *testing.T) {
 		suite.Run(t, &databaseWithStaticDataSuite{})
 	})
+
+	t.Run("TestSet: DatabaseReadOnly", func(t *testing.T) {
+		suite.Run(t, &databaseReadOnlySuite{})
+	})
+
+	t.Run("TestSet: DatabaseOpen", func(t *testing.T) {
+		suite.Run(t, &databaseOpenSuite{})
+	})
 }
@@ -0,0 +1,428 @@
+package Test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const legacyLayout = `////
+-----Users-----
+[1] id [2] name [3] age
+|1| 1 |2| pedro |3| 32
+!*!
+-----Users_End-----
+////`
+
+func (s *encryptionSuite) TestCreate_WritesKeyDerivationHeader() {
+	db, err := tdb.Create("testDbEncrypted.txt", s.options)
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	_, _ = db.NewTable("Users", []string{"name", "age"})
+	data, _ := s.storage.Read()
+	if !strings.HasPrefix(string(data), "KDF scrypt N=1024 r=8 p=1 salt=") {
+		s.Fail("Expected key derivation header", fmt.Sprintf("Recibe: %s", data))
+	}
+
+	db, err = tdb.Open("testDbEncrypted.txt", s.options)
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if _, err = db.GetTableByName("Users"); err != nil {
+		s.Fail("Expected Users Table", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *encryptionSuite) TestOpen_WrongKey() {
+	_, _ = tdb.Create("testDbEncrypted.txt", s.options)
+	s.options.EncryptionKey = "wrong"
+	if _, err := tdb.Open("testDbEncrypted.txt", s.options); !errors.Is(err, tdb.ErrInvalidKey) {
+		s.Fail("Expected ErrInvalidKey", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *encryptionSuite) TestOpen_DamagedCiphertext() {
+	_, _ = tdb.Create("testDbEncrypted.txt", s.options)
+	data, _ := s.storage.Read()
+	header, _, _ := strings.Cut(string(data), "\n")
+	_ = s.storage.Write([]byte(header + "\nENG!!!"))
+	var corruption *tdb.CorruptionError
+	if _, err := tdb.Open("testDbEncrypted.txt", s.options); !errors.As(err, &corruption) {
+		s.Fail("Expected CorruptionError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *encryptionSuite) TestCreate_DifferentSaltPerFile() {
+	_, _ = tdb.Create("testDbEncrypted.txt", s.options)
+	first, _ := s.storage.Read()
+	s.SetupTest()
+	_, _ = tdb.Create("testDbEncrypted.txt", s.options)
+	second, _ := s.storage.Read()
+	firstHeader := strings.Split(string(first), "\n")[0]
+	secondHeader := strings.Split(string(second), "\n")[0]
+	if firstHeader == secondHeader {
+		s.Fail("Expected different salts", fmt.Sprintf("Recibe: %s", firstHeader))
+	}
+}
+
+func (s *encryptionSuite) TestCreate_KeyDerivationLimits() {
+	s.options.KeyDerivation = &tdb.KeyDerivation{N: 1 << 30, R: 8, P: 1}
+	if _, err := tdb.Create("testDbEncrypted.txt", s.options); err == nil {
+		s.Fail("Expected an error for an excessive work factor")
+	}
+	s.options.KeyDerivation = &tdb.KeyDerivation{N: 1024, R: 8, P: 1 << 20}
+	if _, err := tdb.Create("testDbEncrypted.txt", s.options); err == nil {
+		s.Fail("Expected an error for an excessive parallelization")
+	}
+	s.options.KeyDerivation = &tdb.KeyDerivation{N: 1 << 20, R: 32, P: 1}
+	if _, err := tdb.Create("testDbEncrypted.txt", s.options); err == nil {
+		s.Fail("Expected an error for an excessive memory use")
+	}
+	s.options.KeyDerivation = &tdb.KeyDerivation{N: 1 << 16, R: 8, P: 64}
+	if _, err := tdb.Create("testDbEncrypted.txt", s.options); err == nil {
+		s.Fail("Expected an error for an excessive work")
+	}
+}
+
+func (s *encryptionSuite) TestUpgradeEncryption_LegacyFile() {
+	_ = s.storage.Write([]byte(legacyEncode(s.T(), "secret", legacyLayout)))
+	db, err := tdb.Open("testDbEncrypted.txt", s.options)
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, err := db.GetTableByName("Users")
+	if err != nil || len(tb.GetRows()) != 1 {
+		s.Fail("Expected legacy Users Table", fmt.Sprintf("Recibe: %v", err))
+	}
+
+	config := tdb.DbConfig{DatabaseName: "testDbEncrypted.txt", EncryptionKey: "secret", KeyDerivation: s.options.KeyDerivation}
+	if err = config.UpgradeEncryption(); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	data, _ := s.storage.Read()
+	if !strings.HasPrefix(string(data), "KDF scrypt") {
+		s.Fail("Expected key derivation header", fmt.Sprintf("Recibe: %s", data))
+	}
+	db, _ = tdb.Open("testDbEncrypted.txt", s.options)
+	tb, err = db.GetTableByName("Users")
+	if err != nil || len(tb.GetRows()) != 1 {
+		s.Fail("Expected upgraded Users Table", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *encryptionSuite) TestRotateKey() {
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	_, _ = db.NewTable("Users", []string{"name", "age"})
+	if err := db.RotateKey("wrong", "new-secret"); !errors.Is(err, tdb.ErrInvalidKey) {
+		s.Fail("Expected ErrInvalidKey", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := db.RotateKey("secret", "new-secret"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	data, _ := s.storage.Read()
+	if strings.Contains(string(data), "Users") {
+		s.Fail("Expected encrypted data", fmt.Sprintf("Recibe: %s", data))
+	}
+	if _, err := db.GetTableByName("Users"); err != nil {
+		s.Fail("Expected Users Table", fmt.Sprintf("Recibe: %s", err))
+	}
+
+	s.options.EncryptionKey = "new-secret"
+	db, _ = tdb.Open("testDbEncrypted.txt", s.options)
+	if _, err := db.GetTableByName("Users"); err != nil {
+		s.Fail("Expected Users Table with new key", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *encryptionSuite) TestRotateKey_ReturnTransactionInProgressError() {
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	_, _ = db.NewTable("Users", []string{"name", "age"})
+	_ = db.Begin()
+	if err := db.RotateKey("secret", "new-secret"); !errors.Is(err, tdb.ErrTransactionInProgress) {
+		s.Fail("Expected ErrTransactionInProgress", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := db.RefreshKey(); !errors.Is(err, tdb.ErrTransactionInProgress) {
+		s.Fail("Expected ErrTransactionInProgress", fmt.Sprintf("Recibe: %v", err))
+	}
+	_ = db.Rollback()
+	if _, err := tdb.Open("testDbEncrypted.txt", s.options); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *encryptionSuite) TestRotateKey_EncryptedColumns() {
+	s.options.EncryptedColumns = []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}}
+	s.options.Checksums = true
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	_ = tb.AddValues("pedro", "111")
+	before, _ := s.storage.Read()
+	if err := db.RotateKey("wrong", "new-secret"); !errors.Is(err, tdb.ErrInvalidKey) {
+		s.Fail("Expected ErrInvalidKey", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := db.RotateKey("secret", "new-secret"); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	after, _ := s.storage.Read()
+	if string(before) == string(after) || strings.Contains(string(after), "| 111") {
+		s.Fail("Expected the column to be encrypted again", fmt.Sprintf("Recibe: %s", after))
+	}
+
+	if _, err := tdb.Open("testDbEncrypted.txt", s.options); !errors.Is(err, tdb.ErrInvalidKey) {
+		s.Fail("Expected ErrInvalidKey with the old key", fmt.Sprintf("Recibe: %v", err))
+	}
+	s.options.EncryptionKey = "new-secret"
+	db, err := tdb.Open("testDbEncrypted.txt", s.options)
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ = db.GetTableByName("Users")
+	if rows := tb.GetRows(); len(rows) != 1 || rows[0].SearchValue("ssn") != "111" {
+		s.Fail("Expected the decrypted column", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *encryptionSuite) TestKeyProvider_EncryptedColumns() {
+	s.T().Setenv("TDB_TEST_KEY", "env-secret")
+	s.options.EncryptionKey = ""
+	s.options.KeyProvider = tdb.NewEnvKeyProvider("TDB_TEST_KEY")
+	s.options.EncryptedColumns = []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}}
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	_ = tb.AddValues("pedro", "111")
+
+	s.T().Setenv("TDB_TEST_KEY", "rotated-secret")
+	if err := db.RefreshKey(); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	s.options.KeyProvider = nil
+	s.options.EncryptionKey = "rotated-secret"
+	db, err := tdb.Open("testDbEncrypted.txt", s.options)
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ = db.GetTableByName("Users")
+	if rows := tb.GetRows(); len(rows) != 1 || rows[0].SearchValue("ssn") != "111" {
+		s.Fail("Expected the decrypted column", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *encryptionSuite) TestKeyProvider_Env() {
+	s.T().Setenv("TDB_TEST_KEY", "env-secret")
+	s.options.EncryptionKey = ""
+	s.options.KeyProvider = tdb.NewEnvKeyProvider("TDB_TEST_KEY")
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	_, _ = db.NewTable("Users", []string{"name", "age"})
+
+	s.T().Setenv("TDB_TEST_KEY", "rotated-secret")
+	if err := db.RefreshKey(); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	db, err := tdb.Open("testDbEncrypted.txt", &tdb.Options{EncryptionKey: "rotated-secret", Storage: s.storage})
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if _, err = db.GetTableByName("Users"); err != nil {
+		s.Fail("Expected Users Table", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *encryptionSuite) TestKeyProvider_FilePermissions() {
+	path := filepath.Join(s.T().TempDir(), "key")
+	_ = os.WriteFile(path, []byte("file-secret\n"), 0644)
+	if _, err := tdb.NewFileKeyProvider(path).Key(); err == nil && runtime.GOOS != "windows" {
+		s.Fail("Expected permissions error")
+	}
+	_ = os.Chmod(path, 0600)
+	key, err := tdb.NewFileKeyProvider(path).Key()
+	if err != nil || key != "file-secret" {
+		s.Fail("Expected file-secret", fmt.Sprintf("Recibe: %s %v", key, err))
+	}
+}
+
+func (s *encryptionSuite) TestKeyProvider_MissingEnv() {
+	s.options.EncryptionKey = ""
+	s.options.KeyProvider = tdb.NewEnvKeyProvider("TDB_TEST_MISSING_KEY")
+	_, err := tdb.Create("testDbEncrypted.txt", s.options)
+	var example *tdb.NotFoundError
+	if !errors.As(err, &example) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *encryptionSuite) TestEncryptedColumns() {
+	s.options.EncryptedColumns = []tdb.EncryptedColumn{
+		{TableName: "Users", ColumnName: "email", Deterministic: true},
+		{TableName: "Users", ColumnName: "ssn"},
+	}
+	db, err := tdb.Create("testDbEncrypted.txt", s.options)
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ := db.NewTable("Users", []string{"name", "email", "ssn"})
+	_ = tb.AddValues("pedro", "pedro@example.com", "111")
+	_ = tb.AddValues("juan", "pedro@example.com", "222")
+
+	data, _ := s.storage.Read()
+	raw := string(data)
+	if !strings.Contains(raw, "pedro") || !strings.Contains(raw, "juan") {
+		s.Fail("Expected readable names", fmt.Sprintf("Recibe: %s", raw))
+	}
+	if strings.Contains(raw, "pedro@example.com") || strings.Contains(raw, "| 111") {
+		s.Fail("Expected encrypted columns", fmt.Sprintf("Recibe: %s", raw))
+	}
+	if strings.Count(raw, "DET:") != 2 || strings.Count(raw, "ENC:") != 2 {
+		s.Fail("Expected 2 deterministic and 2 random cells", fmt.Sprintf("Recibe: %s", raw))
+	}
+
+	db, _ = tdb.Open("testDbEncrypted.txt", s.options)
+	tb, _ = db.GetTableByName("Users")
+	rows := tb.SearchAll("email", "pedro@example.com")
+	if len(rows) != 2 {
+		s.Fail("Expected len of 2", fmt.Sprintf("Recibe: %d", len(rows)))
+	}
+	result, _ := db.FromSql("SELECT name , ssn FROM Users WHERE ssn = 222")
+	if len(result.Rows) != 1 || result.Rows[0].SearchValue("name") != "juan" {
+		s.Fail("Expected juan", fmt.Sprintf("Recibe: %s", result.Rows))
+	}
+
+	id := rows[0].SearchValue("id")
+	_ = tb.UpdateValue("name", id, "pepe")
+	updated, _ := s.storage.Read()
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.Contains(line, "juan") && !strings.Contains(string(updated), line) {
+			s.Fail("Expected unchanged row to keep its ciphertext", fmt.Sprintf("Recibe: %s", updated))
+		}
+	}
+}
+
+func (s *encryptionSuite) TestEncryptedColumns_PrefixedValues() {
+	s.options.EncryptedColumns = []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}}
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	if err := tb.AddValues("ENC:abc", "DET:xyz"); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	data, _ := s.storage.Read()
+	if strings.Contains(string(data), "DET:xyz") {
+		s.Fail("Expected the prefixed value to be encrypted", fmt.Sprintf("Recibe: %s", data))
+	}
+	db, err := tdb.Open("testDbEncrypted.txt", s.options)
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ = db.GetTableByName("Users")
+	rows := tb.GetRows()
+	if len(rows) != 1 || rows[0].SearchValue("name") != "ENC:abc" || rows[0].SearchValue("ssn") != "DET:xyz" {
+		s.Fail("Expected the values as written", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *encryptionSuite) TestEncryptedColumns_Base64() {
+	s.options.EncryptedColumns = []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}}
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	for i := 0; i < 50; i++ {
+		_ = tb.AddValues("pedro", fmt.Sprintf("ssn-%d", i))
+	}
+	data, _ := s.storage.Read()
+	var cell string
+	for _, field := range strings.Fields(string(data)) {
+		if strings.HasPrefix(field, "ENC:") {
+			cell = field
+			if strings.ContainsAny(field, "/+=") {
+				s.Fail("Expected URL-safe base64 cells", fmt.Sprintf("Recibe: %s", field))
+			}
+		}
+	}
+
+	// cells written by older versions are encoded in standard base64
+	cipherText, _ := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(cell, "ENC:"))
+	_ = s.storage.Write([]byte(strings.Replace(string(data), cell, "ENC:"+base64.StdEncoding.EncodeToString(cipherText), 1)))
+	db, err := tdb.Open("testDbEncrypted.txt", s.options)
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ = db.GetTableByName("Users")
+	if rows := tb.SearchAll("ssn", "ssn-49"); len(rows) != 1 {
+		s.Fail("Expected len of 1", fmt.Sprintf("Recibe: %d", len(rows)))
+	}
+}
+
+func (s *encryptionSuite) TestEncryptedColumns_ReturnEncryptedColumnError() {
+	s.options.EncryptedColumns = []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}}
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	_ = tb.AddValues("pedro", "111")
+	if err := tb.UpdateColumnName("ssn", "document"); !errors.Is(err, tdb.ErrEncryptedColumn) {
+		s.Fail("Expected ErrEncryptedColumn", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := tb.UpdateTableName("Customers"); !errors.Is(err, tdb.ErrEncryptedColumn) {
+		s.Fail("Expected ErrEncryptedColumn for the table", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := tb.UpdateColumnName("name", "full_name"); err != nil {
+		s.Fail("Expected nil for a plain column", fmt.Sprintf("Recibe: %s", err))
+	}
+	data, _ := s.storage.Read()
+	if strings.Contains(string(data), "111") || !strings.Contains(string(data), "[3] ssn") {
+		s.Fail("Expected the ssn column to stay encrypted", fmt.Sprintf("Recibe: %s", data))
+	}
+}
+
+func (s *encryptionSuite) TestEncryptedColumns_WrongKey() {
+	s.options.EncryptedColumns = []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}}
+	db, _ := tdb.Create("testDbEncrypted.txt", s.options)
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	_ = tb.AddValues("pedro", "111")
+	s.options.EncryptionKey = "wrong"
+	if _, err := tdb.Open("testDbEncrypted.txt", s.options); !errors.Is(err, tdb.ErrInvalidKey) {
+		s.Fail("Expected ErrInvalidKey", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *encryptionSuite) TestEncryptedColumns_HeaderLimits() {
+	s.options.EncryptedColumns = []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}}
+	_, _ = tdb.Create("testDbEncrypted.txt", s.options)
+	data, _ := s.storage.Read()
+	_ = s.storage.Write([]byte(strings.Replace(string(data), "CKD scrypt N=1024 ", "CKD scrypt N=1073741824 ", 1)))
+	if _, err := tdb.Open("testDbEncrypted.txt", s.options); err == nil {
+		s.Fail("Expected an error for an excessive work factor")
+	}
+	_ = s.storage.Write([]byte(strings.Replace(string(data), "CKD scrypt N=1024 r=8 ", "CKD scrypt N=1048576 r=32 ", 1)))
+	if _, err := tdb.Open("testDbEncrypted.txt", s.options); err == nil {
+		s.Fail("Expected an error for an excessive memory use")
+	}
+}
+
+// legacyEncode encrypts the text like databases written before the key derivation header
+func legacyEncode(t *testing.T, secret string, text string) string {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	ciphertext := gcm.Seal(nonce, nonce, []byte(text), nil)
+	return "ENG" + base64.StdEncoding.EncodeToString(ciphertext)
+}
+
+func TestEncryption(t *testing.T) {
+	t.Run("TestSet: Encryption", func(t *testing.T) {
+		suite.Run(t, &encryptionSuite{})
+	})
+}
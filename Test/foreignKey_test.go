@@ -0,0 +1,409 @@
+package Test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+func (s *foreignKeySuite) TestAddValues_ReturnForeignKeyViolationError() {
+	tb, _ := s.db.GetTableByName("Houses")
+	err := tb.AddValues("juan_avenue", "9")
+	var violation *tdb.ForeignKeyViolationError
+	if !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+		return
+	}
+	if violation.Value() != "9" || violation.ForeignKey().ForeignColumnName != "id_owner" {
+		s.Fail("Expected id_owner value 9", fmt.Sprintf("Recibe: %s", err))
+	}
+	if err = tb.AddValues("juan_avenue", "2"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ = s.db.GetTableByName("Houses")
+	if len(tb.GetRows()) != 2 {
+		s.Fail("Expected 2 rows", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+}
+
+func (s *foreignKeySuite) TestAddValue_ReturnForeignKeyViolationError() {
+	tb, _ := s.db.GetTableByName("Houses")
+	var violation *tdb.ForeignKeyViolationError
+	if err := tb.AddValue("id_owner", "9"); !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := tb.AddValue("direction", "juan_avenue"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *foreignKeySuite) TestUpdateValue_ReturnForeignKeyViolationError() {
+	houses, _ := s.db.GetTableByName("Houses")
+	var violation *tdb.ForeignKeyViolationError
+	if err := houses.UpdateValue("id_owner", "1", "9"); !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	users, _ := s.db.GetTableByName("Users")
+	if err := users.UpdateValue("id", "1", "7"); !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := users.UpdateValue("id", "2", "7"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *foreignKeySuite) TestDeleteRow_ReturnForeignKeyViolationError() {
+	users, _ := s.db.GetTableByName("Users")
+	var violation *tdb.ForeignKeyViolationError
+	if err := users.DeleteRow("1", false); !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := users.DeleteRow("2", false); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *foreignKeySuite) TestSql_ReturnForeignKeyViolationError() {
+	var violation *tdb.ForeignKeyViolationError
+	_, err := s.db.FromSql("INSERT INTO Houses id direction id_owner VALUES 2 juan_avenue 9")
+	if !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	_, err = s.db.FromSql("UPDATE Houses SET id_owner = 9 WHERE id = 1")
+	if !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	_, err = s.db.FromSql("DELETE FROM Users WHERE id = 1")
+	if !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *foreignKeySuite) TestSql_MultiRowStatementIsAtomic() {
+	var violation *tdb.ForeignKeyViolationError
+	_, err := s.db.FromSql("INSERT INTO Houses id direction id_owner VALUES 2 juan_avenue 2 3 maria_avenue 9")
+	if !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	houses, _ := s.db.GetTableByName("Houses")
+	if rows := houses.GetRows(); len(rows) != 1 {
+		s.Fail("Expected the statement to leave 1 row", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *foreignKeySuite) TestDeferForeignKeys() {
+	db, err := tdb.Open("testDbForeignKey.txt", &tdb.Options{Storage: s.storage, DeferForeignKeys: true})
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+		return
+	}
+	_ = db.Begin()
+	houses, _ := db.GetTableByName("Houses")
+	if err = houses.AddValues("juan_avenue", "3"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	_, _ = db.FromSql("INSERT INTO Users id name VALUES 3 carlos")
+	if err = db.Commit(); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+
+	_ = db.Begin()
+	houses, _ = db.GetTableByName("Houses")
+	_ = houses.AddValues("manuel_avenue", "9")
+	var violation *tdb.ForeignKeyViolationError
+	if err = db.Commit(); !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	houses, _ = db.GetTableByName("Houses")
+	if len(houses.SearchAll("id_owner", "9")) != 0 {
+		s.Fail("Expected the transaction to be rolled back")
+	}
+}
+
+func (s *foreignKeySuite) TestRollback() {
+	if err := s.db.Commit(); !errors.Is(err, tdb.ErrNoTransaction) {
+		s.Fail("Expected ErrNoTransaction", fmt.Sprintf("Recibe: %v", err))
+	}
+	_ = s.db.Begin()
+	if err := s.db.Begin(); !errors.Is(err, tdb.ErrTransactionInProgress) {
+		s.Fail("Expected ErrTransactionInProgress", fmt.Sprintf("Recibe: %v", err))
+	}
+	users, _ := s.db.GetTableByName("Users")
+	_ = users.AddValues("carlos")
+	if err := s.db.Rollback(); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	users, _ = s.db.GetTableByName("Users")
+	if len(users.GetRows()) != 2 {
+		s.Fail("Expected 2 rows", fmt.Sprintf("Recibe: %d", len(users.GetRows())))
+	}
+}
+
+func (s *foreignKeySuite) addCompanies(onDelete tdb.ReferentialAction, onUpdate tdb.ReferentialAction) {
+	_, _ = s.db.NewTable("Companies", []string{"name"})
+	_, _ = s.db.NewTable("Teams", []string{"company_id"})
+	_, _ = s.db.NewTable("Members", []string{"team_id"})
+	_, _ = s.db.FromSql("INSERT INTO Companies id name VALUES 1 acme 2 globex")
+	_, _ = s.db.FromSql("INSERT INTO Teams id company_id VALUES 1 1 2 1 3 2")
+	_, _ = s.db.FromSql("INSERT INTO Members id team_id VALUES 1 1 2 2 3 3")
+	errorHandler(s.db.AddForeignKeys([]tdb.ForeignKey{
+		{TableName: "Companies", ColumnName: "id", ForeignTableName: "Teams", ForeignColumnName: "company_id",
+			OnDelete: onDelete, OnUpdate: onUpdate, DefaultValue: "2"},
+		{TableName: "Teams", ColumnName: "id", ForeignTableName: "Members", ForeignColumnName: "team_id",
+			OnDelete: onDelete, OnUpdate: onUpdate},
+	}))
+}
+
+func (s *foreignKeySuite) TestOnDeleteCascade() {
+	s.addCompanies(tdb.Cascade, tdb.NoAction)
+	_, err := s.db.FromSql("DELETE FROM Companies WHERE id = 1")
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	teams, _ := s.db.GetTableByName("Teams")
+	members, _ := s.db.GetTableByName("Members")
+	if len(teams.GetRows()) != 1 || len(members.GetRows()) != 1 {
+		s.Fail("Expected 1 team and 1 member", fmt.Sprintf("Recibe: %d %d", len(teams.GetRows()), len(members.GetRows())))
+	}
+	if members.GetRows()[0].String() != "|1| 3 |2| 3" {
+		s.Fail("Expected |1| 3 |2| 3", fmt.Sprintf("Recibe: %s", members.GetRows()[0].String()))
+	}
+}
+
+func (s *foreignKeySuite) TestOnDeleteSetNullAndSetDefault() {
+	s.addCompanies(tdb.SetDefault, tdb.NoAction)
+	companies, _ := s.db.GetTableByName("Companies")
+	if err := companies.DeleteRow("1", false); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	teams, _ := s.db.GetTableByName("Teams")
+	if len(teams.SearchAll("company_id", "2")) != 3 {
+		s.Fail("Expected 3 teams of company 2", fmt.Sprintf("Recibe: %v", teams.GetRows()))
+	}
+	var violation *tdb.ForeignKeyViolationError
+	if err := companies.DeleteRow("2", false); !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+
+	teams, _ = s.db.GetTableByName("Teams")
+	if err := teams.DeleteRow("1", false); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	members, _ := s.db.GetTableByName("Members")
+	row, _ := members.GetRowById("1")
+	if row.SearchValue("team_id") != "null" {
+		s.Fail("Expected null", fmt.Sprintf("Recibe: %s", row.String()))
+	}
+}
+
+func (s *foreignKeySuite) TestOnDeleteRestrict_Deferred() {
+	s.addCompanies(tdb.Restrict, tdb.Restrict)
+	db, _ := tdb.Open("testDbForeignKey.txt", &tdb.Options{Storage: s.storage, DeferForeignKeys: true})
+	_ = db.Begin()
+	var violation *tdb.ForeignKeyViolationError
+	companies, _ := db.GetTableByName("Companies")
+	if err := companies.DeleteRow("1", false); !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	teams, _ := db.GetTableByName("Teams")
+	if len(teams.GetRows()) != 3 {
+		s.Fail("Expected 3 teams", fmt.Sprintf("Recibe: %d", len(teams.GetRows())))
+	}
+	_ = db.Rollback()
+}
+
+func (s *foreignKeySuite) TestOnUpdateCascade() {
+	s.addCompanies(tdb.NoAction, tdb.Cascade)
+	companies, _ := s.db.GetTableByName("Companies")
+	if err := companies.UpdateValue("id", "1", "5"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	teams, _ := s.db.GetTableByName("Teams")
+	if len(teams.SearchAll("company_id", "5")) != 2 {
+		s.Fail("Expected 2 teams of company 5", fmt.Sprintf("Recibe: %v", teams.GetRows()))
+	}
+	if err := teams.UpdateValue("id", "1", "7"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	members, _ := s.db.GetTableByName("Members")
+	if len(members.SearchAll("team_id", "7")) != 1 {
+		s.Fail("Expected 1 member of team 7", fmt.Sprintf("Recibe: %v", members.GetRows()))
+	}
+}
+
+func (s *foreignKeySuite) TestOnDeleteCascade_Cycle() {
+	_, _ = s.db.NewTable("Employees", []string{"manager_id"})
+	_, _ = s.db.FromSql("INSERT INTO Employees id manager_id VALUES 1 2 2 1 3 3")
+	errorHandler(s.db.AddForeignKey(tdb.ForeignKey{
+		TableName: "Employees", ColumnName: "id", ForeignTableName: "Employees", ForeignColumnName: "manager_id",
+		OnDelete: tdb.Cascade,
+	}))
+	employees, _ := s.db.GetTableByName("Employees")
+	if err := employees.DeleteRow("1", false); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	employees, _ = s.db.GetTableByName("Employees")
+	if len(employees.GetRows()) != 1 {
+		s.Fail("Expected 1 employee", fmt.Sprintf("Recibe: %v", employees.GetRows()))
+	}
+}
+
+func (s *foreignKeySuite) TestAddForeignKey_ReturnInvalidActionError() {
+	err := s.db.AddForeignKey(tdb.ForeignKey{
+		TableName: "Users", ColumnName: "id", ForeignTableName: "Houses", ForeignColumnName: "direction",
+		OnDelete: "DROP",
+	})
+	if err == nil {
+		s.Fail("Expected error")
+	}
+}
+
+func (s *foreignKeySuite) TestForeignKeys() {
+	keys := s.db.ForeignKeys()
+	if len(keys) != 1 || keys[0].ForeignColumnName != "id_owner" || keys[0].OnDelete != tdb.NoAction {
+		s.Fail("Expected Users.id -> Houses.id_owner", fmt.Sprintf("Recibe: %v", keys))
+	}
+	s.addCompanies(tdb.Cascade, tdb.NoAction)
+	keys, err := s.db.ForeignKeysOf("Teams")
+	if err != nil || len(keys) != 2 {
+		s.Fail("Expected 2 keys", fmt.Sprintf("Recibe: %v %v", keys, err))
+	}
+	if _, err = s.db.ForeignKeysOf("Missing"); err == nil {
+		s.Fail("Expected NotFoundError")
+	}
+	data, _ := s.storage.Read()
+	if !strings.Contains(string(data), "[1] id [2] table1 [3] columnLink1 [4] table2 [5] columnLink2") {
+		s.Fail("Expected sequential Links header", fmt.Sprintf("Recibe: %s", data))
+	}
+}
+
+func (s *foreignKeySuite) TestDropForeignKey() {
+	key := tdb.ForeignKey{TableName: "Users", ColumnName: "id", ForeignTableName: "Houses", ForeignColumnName: "id_owner"}
+	if err := s.db.DropForeignKey(key); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	var notFound *tdb.NotFoundError
+	if err := s.db.DropForeignKey(key); !errors.As(err, &notFound) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if len(s.db.ForeignKeys()) != 0 {
+		s.Fail("Expected no keys", fmt.Sprintf("Recibe: %v", s.db.ForeignKeys()))
+	}
+	houses, _ := s.db.GetTableByName("Houses")
+	if err := houses.AddValues("juan_avenue", "9"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *foreignKeySuite) TestForeignKeys_FollowSchemaChanges() {
+	houses, _ := s.db.GetTableByName("Houses")
+	_ = houses.UpdateColumnName("id_owner", "owner")
+	users, _ := s.db.GetTableByName("Users")
+	_ = users.UpdateTableName("Owners")
+	keys := s.db.ForeignKeys()
+	if len(keys) != 1 || keys[0].TableName != "Owners" || keys[0].ForeignColumnName != "owner" {
+		s.Fail("Expected Owners.id -> Houses.owner", fmt.Sprintf("Recibe: %v", keys))
+	}
+	houses, _ = s.db.GetTableByName("Houses")
+	var violation *tdb.ForeignKeyViolationError
+	if err := houses.AddValues("juan_avenue", "9"); !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+
+	_ = houses.DeleteColumn("owner")
+	if len(s.db.ForeignKeys()) != 0 {
+		s.Fail("Expected no keys", fmt.Sprintf("Recibe: %v", s.db.ForeignKeys()))
+	}
+	s.addCompanies(tdb.Cascade, tdb.NoAction)
+	_ = s.db.DeleteTable("Teams")
+	if len(s.db.ForeignKeys()) != 0 {
+		s.Fail("Expected no keys", fmt.Sprintf("Recibe: %v", s.db.ForeignKeys()))
+	}
+}
+
+func (s *foreignKeySuite) TestForeignKeys_LegacyLinks() {
+	data, _ := s.storage.Read()
+	segments := strings.Split(string(data), "////")
+	for i, segment := range segments {
+		if strings.Contains(segment, "-----__tdb_meta-----") {
+			segments[i] = "\n-----Links-----\n[1] id [2] table1 [3] columnLink1 [3] table2 [4] columnLink2\n" +
+				"|1| 1 |2| Users |3| id |3| Houses |4| id_owner\n!*!\n-----Links_End-----\n"
+		}
+	}
+	legacy := strings.Join(segments, "////")
+	_ = s.storage.Write([]byte(legacy))
+	db, err := tdb.Open("testDbForeignKey.txt", &tdb.Options{Storage: s.storage})
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+		return
+	}
+	if data, _ = s.storage.Read(); string(data) != legacy {
+		s.Fail("Expected Open not to write the database")
+	}
+	if keys := db.ForeignKeys(); len(keys) != 1 {
+		s.Fail("Expected the legacy key", fmt.Sprintf("Recibe: %v", keys))
+	}
+	_ = db.Upgrade()
+	if _, err = db.GetTableByName("Links"); err == nil {
+		s.Fail("Expected Links to be moved to the system catalog")
+	}
+	keys := db.ForeignKeys()
+	if len(keys) != 1 || keys[0].ForeignTableName != "Houses" || keys[0].OnUpdate != tdb.NoAction {
+		s.Fail("Expected Users.id -> Houses.id_owner", fmt.Sprintf("Recibe: %v", keys))
+	}
+	_ = db.AddForeignKey(tdb.ForeignKey{TableName: "Users", ColumnName: "name", ForeignTableName: "Houses", ForeignColumnName: "direction"})
+	if len(db.ForeignKeys()) != 2 {
+		s.Fail("Expected 2 keys", fmt.Sprintf("Recibe: %v", db.ForeignKeys()))
+	}
+}
+
+func (s *foreignKeySuite) TestSystemCatalog_Hidden() {
+	for _, tb := range s.db.GetTables() {
+		if strings.HasPrefix(tb.GetName(), "-----__tdb_") {
+			s.Fail("Expected system tables to be hidden", tb.GetName())
+		}
+	}
+	var notFound *tdb.NotFoundError
+	if _, err := s.db.GetTableByName("__tdb_meta"); !errors.As(err, &notFound) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if _, err := s.db.FromSql("SELECT * FROM __tdb_meta"); !errors.As(err, &notFound) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if _, err := s.db.FromSql("DROP TABLE __tdb_meta"); !errors.As(err, &notFound) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if err := s.db.DeleteTable("__tdb_meta"); !errors.As(err, &notFound) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if _, err := s.db.NewTable("__tdb_custom", []string{"name"}); !errors.Is(err, tdb.ErrReservedName) {
+		s.Fail("Expected ErrReservedName", fmt.Sprintf("Recibe: %v", err))
+	}
+	users, _ := s.db.GetTableByName("Users")
+	if err := users.UpdateTableName("__tdb_users"); !errors.Is(err, tdb.ErrReservedName) {
+		s.Fail("Expected ErrReservedName", fmt.Sprintf("Recibe: %v", err))
+	}
+	if len(s.db.ForeignKeys()) != 1 {
+		s.Fail("Expected the foreign key to be kept", fmt.Sprintf("Recibe: %v", s.db.ForeignKeys()))
+	}
+}
+
+func (s *foreignKeySuite) TestSystemTableRows() {
+	if names := s.db.SystemTables(); len(names) != 1 || names[0] != "__tdb_meta" {
+		s.Fail("Expected __tdb_meta", fmt.Sprintf("Recibe: %v", names))
+	}
+	rows, err := s.db.SystemTableRows("__tdb_meta")
+	if err != nil || len(rows) != 1 || rows[0].SearchValue("table2") != "Houses" {
+		s.Fail("Expected Houses link", fmt.Sprintf("Recibe: %v %v", rows, err))
+	}
+	if _, err = s.db.SystemTableRows("Users"); err == nil {
+		s.Fail("Expected NotFoundError")
+	}
+}
+
+func TestForeignKey(t *testing.T) {
+	suite.Run(t, new(foreignKeySuite))
+}
@@ -0,0 +1,127 @@
+package Test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+// legacy rewrites the database without the format header, like databases written by older versions
+func (s *formatSuite) legacy() {
+	data, _ := s.storage.Read()
+	errorHandler(s.storage.Write([]byte(strings.TrimPrefix(string(data), "FMT 1\n"))))
+}
+
+func (s *formatSuite) TestFormatVersion() {
+	data, _ := s.storage.Read()
+	if !strings.HasPrefix(string(data), "FMT 1\n////") {
+		s.Fail("Expected the format header", fmt.Sprintf("Recibe: %s", data))
+	}
+	if s.db.FormatVersion() != 1 {
+		s.Fail("Expected version 1", fmt.Sprintf("Recibe: %d", s.db.FormatVersion()))
+	}
+}
+
+func (s *formatSuite) TestUpgrade() {
+	s.legacy()
+	db, err := tdb.Open("testDbCsv.txt", &tdb.Options{Storage: s.storage})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if db.FormatVersion() != 0 {
+		s.Fail("Expected version 0", fmt.Sprintf("Recibe: %d", db.FormatVersion()))
+	}
+	if err = db.Upgrade(); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if db.FormatVersion() != 1 {
+		s.Fail("Expected version 1", fmt.Sprintf("Recibe: %d", db.FormatVersion()))
+	}
+	tb, _ := db.GetTableByName("Users")
+	if len(tb.GetRows()) != 2 {
+		s.Fail("Expected len of 2", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+}
+
+// writeCounter counts the writes to the storage
+type writeCounter struct {
+	*tdb.MemoryStorage
+	writes int
+}
+
+func (w *writeCounter) Write(data []byte) error {
+	w.writes++
+	return w.MemoryStorage.Write(data)
+}
+
+func (s *formatSuite) TestUpgrade_CurrentFormat() {
+	data, _ := s.storage.Read()
+	storage := &writeCounter{MemoryStorage: tdb.NewMemoryStorage()}
+	errorHandler(storage.Write(data))
+	db, err := tdb.Open("testDbFormat.txt", &tdb.Options{Storage: storage})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	writes := storage.writes
+	if err = db.Upgrade(); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if storage.writes != writes {
+		s.Fail("Expected the database not to be written", fmt.Sprintf("Recibe: %d writes", storage.writes-writes))
+	}
+}
+
+func (s *formatSuite) TestUpgrade_OnWrite() {
+	s.legacy()
+	db, _ := tdb.Open("testDbCsv.txt", &tdb.Options{Storage: s.storage})
+	tb, _ := db.GetTableByName("Users")
+	errorHandler(tb.AddValues("maria", "40"))
+	if db.FormatVersion() != 1 {
+		s.Fail("Expected version 1", fmt.Sprintf("Recibe: %d", db.FormatVersion()))
+	}
+}
+
+func (s *formatSuite) TestUpgrade_LegacyEncryption() {
+	data, _ := s.storage.Read()
+	storage := tdb.NewMemoryStorage()
+	errorHandler(storage.Write([]byte(legacyEncode(s.T(), "secret", strings.TrimPrefix(string(data), "FMT 1\n")))))
+	opts := &tdb.Options{EncryptionKey: "secret", Storage: storage, KeyDerivation: &tdb.KeyDerivation{N: 1024, R: 8, P: 1}}
+	db, err := tdb.Open("testDbFormat.txt", opts)
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if err = db.Upgrade(); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if data, _ = storage.Read(); !strings.HasPrefix(string(data), "KDF scrypt N=1024 ") {
+		s.Fail("Expected the key derivation header", fmt.Sprintf("Recibe: %.40s", data))
+	}
+	db, _ = tdb.Open("testDbFormat.txt", opts)
+	tb, _ := db.GetTableByName("Users")
+	if db.FormatVersion() != 1 || len(tb.GetRows()) != 2 {
+		s.Fail("Expected the upgraded database", fmt.Sprintf("Recibe: %d %d", db.FormatVersion(), len(tb.GetRows())))
+	}
+}
+
+func (s *formatSuite) TestOpen_NewerFormat() {
+	data, _ := s.storage.Read()
+	newer := strings.Replace(string(data), "FMT 1\n", "FMT 2\n", 1)
+	errorHandler(s.storage.Write([]byte(newer)))
+	if _, err := tdb.Open("testDbCsv.txt", &tdb.Options{Storage: s.storage}); !errors.Is(err, tdb.ErrUnsupportedFormat) {
+		s.Fail("Expected ErrUnsupportedFormat", fmt.Sprintf("Recibe: %v", err))
+	}
+	report, _ := tdb.Repair("testDbCsv.txt", &tdb.Options{Storage: s.storage})
+	if len(report.Issues) != 1 || report.Issues[0].Problem != tdb.ProblemVersion || report.Issues[0].Repaired {
+		s.Fail("Expected a version issue", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+	if data, _ = s.storage.Read(); string(data) != newer {
+		s.Fail("Expected the database not to be written", fmt.Sprintf("Recibe: %s", data))
+	}
+}
+
+func TestFormat(t *testing.T) {
+	suite.Run(t, new(formatSuite))
+}
@@ -0,0 +1,174 @@
+package Test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+func (s *integritySuite) TestChecksums_Stored() {
+	data, _ := s.storage.Read()
+	if !strings.HasPrefix(string(data), "FMT 1\nCHK sha256 file ") || !strings.Contains(string(data), "CHK sha256 table ") {
+		s.Fail("Expected checksum header", fmt.Sprintf("Recibe: %s", data))
+	}
+	_, err := tdb.Open("testDbIntegrity.txt", &tdb.Options{Storage: s.storage})
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *integritySuite) TestChecksums_ReturnCorruptionError() {
+	data, _ := s.storage.Read()
+	_ = s.storage.Write([]byte(strings.Replace(string(data), "pedro", "pablo", 1)))
+	_, err := tdb.Open("testDbIntegrity.txt", &tdb.Options{Storage: s.storage})
+	var corruption *tdb.CorruptionError
+	if !errors.As(err, &corruption) {
+		s.Fail("Expected CorruptionError", fmt.Sprintf("Recibe: %v", err))
+		return
+	}
+	if corruption.TableName() != "Users" {
+		s.Fail("Expected Users Table", fmt.Sprintf("Recibe: %s", corruption.TableName()))
+	}
+}
+
+func (s *integritySuite) TestChecksums_Stripped() {
+	data, _ := s.storage.Read()
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "CHK ") {
+			lines = append(lines, line)
+		}
+	}
+	_ = s.storage.Write([]byte(strings.Replace(strings.Join(lines, "\n"), "pedro", "pablo", 1)))
+	opts := &tdb.Options{Storage: s.storage, Checksums: true}
+	var corruption *tdb.CorruptionError
+	if _, err := tdb.Open("testDbIntegrity.txt", opts); !errors.As(err, &corruption) {
+		s.Fail("Expected CorruptionError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if _, err := tdb.Open("testDbIntegrity.txt", &tdb.Options{Storage: s.storage}); err != nil {
+		s.Fail("Expected nil without Checksums", fmt.Sprintf("Recibe: %s", err))
+	}
+
+	report, _ := tdb.Repair("testDbIntegrity.txt", opts)
+	if len(report.Issues) != 1 || report.Issues[0].Problem != tdb.ProblemChecksum || !report.Issues[0].Repaired {
+		s.Fail("Expected a repaired checksum issue", fmt.Sprintf("Recibe: %+v", report.Issues))
+	}
+	if _, err := tdb.Open("testDbIntegrity.txt", opts); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *integritySuite) TestStructure_ReturnCorruptionError() {
+	data, _ := s.storage.Read()
+	_, body, _ := strings.Cut(string(data), "////")
+	body = strings.Replace("////"+body, "-----Users_End-----", "-----User_End-----", 1)
+	_ = s.storage.Write([]byte(body))
+	_, err := tdb.Open("testDbIntegrity.txt", &tdb.Options{Storage: s.storage})
+	var corruption *tdb.CorruptionError
+	if !errors.As(err, &corruption) || corruption.TableName() != "Users" {
+		s.Fail("Expected CorruptionError in Users", fmt.Sprintf("Recibe: %v", err))
+	}
+
+	_ = s.storage.Write([]byte(strings.Replace("////"+strings.Split(string(data), "////")[1]+"////", "|3| 32", "", 1)))
+	_, err = tdb.Open("testDbIntegrity.txt", &tdb.Options{Storage: s.storage})
+	if !errors.As(err, &corruption) {
+		s.Fail("Expected CorruptionError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *integritySuite) TestStructure_ColumnNumbering() {
+	data, _ := s.storage.Read()
+	_, body, _ := strings.Cut(string(data), "////")
+	body = "////" + body
+	_ = s.storage.Write([]byte(strings.Replace(body, "[2] name [3] age", "[3] name [2] age", 1)))
+	var corruption *tdb.CorruptionError
+	if _, err := tdb.Open("testDbIntegrity.txt", &tdb.Options{Storage: s.storage}); !errors.As(err, &corruption) {
+		s.Fail("Expected CorruptionError", fmt.Sprintf("Recibe: %v", err))
+	}
+
+	gap := strings.Replace(body, "[3] age", "[4] age", 1)
+	_ = s.storage.Write([]byte(strings.ReplaceAll(gap, "|3| ", "|4| ")))
+	if _, err := tdb.Open("testDbIntegrity.txt", &tdb.Options{Storage: s.storage}); err != nil {
+		s.Fail("Expected nil for a deleted column gap", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *integritySuite) TestChecksums_Encrypted() {
+	storage := tdb.NewMemoryStorage()
+	opts := &tdb.Options{
+		EncryptionKey: "secret",
+		Storage:       storage,
+		Checksums:     true,
+		KeyDerivation: &tdb.KeyDerivation{N: 1024, R: 8, P: 1},
+	}
+	db, _ := tdb.Create("testDbIntegrity.txt", opts)
+	tb, _ := db.NewTable("Users", []string{"name"})
+	_ = tb.AddValues("pedro")
+	_ = db.RotateKey("secret", "new-secret")
+	opts.EncryptionKey = "new-secret"
+	db, err := tdb.Open("testDbIntegrity.txt", opts)
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+		return
+	}
+	tb, _ = db.GetTableByName("Users")
+	if len(tb.GetRows()) != 1 {
+		s.Fail("Expected len of 1", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+}
+
+func (s *integritySuite) TestChecksums_EncryptedColumnsDowngrade() {
+	storage := tdb.NewMemoryStorage()
+	opts := &tdb.Options{
+		EncryptionKey:    "secret",
+		Storage:          storage,
+		Checksums:        true,
+		KeyDerivation:    &tdb.KeyDerivation{N: 1024, R: 8, P: 1},
+		EncryptedColumns: []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}},
+	}
+	db, _ := tdb.Create("testDbIntegrity.txt", opts)
+	tb, _ := db.NewTable("Users", []string{"name", "ssn"})
+	_ = tb.AddValues("pedro", "111")
+	data, _ := storage.Read()
+
+	// the tampered body gets plain SHA-256 checksums, which don't need the key
+	var header, body []string
+	for _, line := range strings.Split(strings.Replace(string(data), "pedro", "mallory", 1), "\n") {
+		if len(body) == 0 && (strings.HasPrefix(line, "FMT ") || strings.HasPrefix(line, "CKD ")) {
+			header = append(header, line)
+		} else if len(body) > 0 || !strings.HasPrefix(line, "CHK ") {
+			body = append(body, line)
+		}
+	}
+	tampered := strings.Join(body, "\n")
+	sum := sha256.Sum256([]byte(tampered))
+	header = append(header, "CHK sha256 file "+hex.EncodeToString(sum[:]))
+	for _, segment := range strings.Split(tampered, "////") {
+		if strings.Contains(segment, "-----") {
+			sum = sha256.Sum256([]byte(segment))
+			name := strings.Trim(strings.Split(segment, "\n")[1], "-")
+			header = append(header, "CHK sha256 table "+hex.EncodeToString(sum[:])+" "+name)
+		}
+	}
+	_ = storage.Write([]byte(strings.Join(header, "\n") + "\n" + tampered))
+	var corruption *tdb.CorruptionError
+	if _, err := tdb.Open("testDbIntegrity.txt", opts); !errors.As(err, &corruption) {
+		s.Fail("Expected CorruptionError", fmt.Sprintf("Recibe: %v", err))
+	}
+
+	_ = storage.Write([]byte(strings.Join(header[:2], "\n") + "\n" + tampered))
+	if _, err := tdb.Open("testDbIntegrity.txt", opts); !errors.As(err, &corruption) {
+		s.Fail("Expected CorruptionError without checksums", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	t.Run("TestSet: Integrity", func(t *testing.T) {
+		suite.Run(t, &integritySuite{})
+	})
+}
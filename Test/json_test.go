@@ -0,0 +1,134 @@
+package Test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+func (s *jsonSuite) TestRowsMarshalJSON() {
+	tb, _ := s.db.GetTableByName("Users")
+	errorHandler(tb.AddColumn("email"))
+	result, err := s.db.FromSql("SELECT name , email FROM Users WHERE name = juan")
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	encoded, err := json.Marshal(result.Rows)
+	if err != nil || string(encoded) != `[{"name":"juan","email":null}]` {
+		s.Fail("Expected juan as json", fmt.Sprintf("Recibe: %s %v", encoded, err))
+	}
+	encoded, _ = json.Marshal(tdb.Rows{})
+	if string(encoded) != "[]" {
+		s.Fail("Expected an empty array", fmt.Sprintf("Recibe: %s", encoded))
+	}
+}
+
+func (s *jsonSuite) TestExportJSON_Table() {
+	tb, _ := s.db.GetTableByName("Users")
+	var buffer bytes.Buffer
+	errorHandler(tb.ExportJSON(&buffer))
+	expected := `[{"id":"1","name":"pedro perez","age":"20"},{"id":"2","name":"juan","age":"30"}]` + "\n"
+	if buffer.String() != expected {
+		s.Fail("Expected the rows as json", fmt.Sprintf("Recibe: %s", buffer.String()))
+	}
+}
+
+func (s *jsonSuite) TestExportNDJSON_ImportNDJSON() {
+	tb, _ := s.db.GetTableByName("Users")
+	var buffer bytes.Buffer
+	errorHandler(tb.ExportNDJSON(&buffer))
+	if buffer.String() != "{\"id\":\"1\",\"name\":\"pedro perez\",\"age\":\"20\"}\n{\"id\":\"2\",\"name\":\"juan\",\"age\":\"30\"}\n" {
+		s.Fail("Expected an object per line", fmt.Sprintf("Recibe: %s", buffer.String()))
+	}
+	imported, err := s.db.ImportNDJSON("UsersCopy", &buffer, nil)
+	if err != nil || imported != 2 {
+		s.Fail("Expected 2 rows imported", fmt.Sprintf("Recibe: %d %v", imported, err))
+	}
+	copied, _ := s.db.GetTableByName("UsersCopy")
+	row, err := copied.GetRowById("1")
+	if err != nil || row.SearchValue("name") != "pedro perez" || row.SearchValue("age") != "20" {
+		s.Fail("Expected pedro perez with its id", fmt.Sprintf("Recibe: %s %v", row.String(), err))
+	}
+}
+
+func (s *jsonSuite) TestImportNDJSON_ReportLineErrors() {
+	file := `{"full_name": "maria lopez", "age": 41, "tags": ["a", "b"]}
+
+{"name": "broken"
+{"id": "1", "name": "duplicated"}
+{"name": "ana", "email": "ana@example.com"}
+{"name": "luis", "age": null}`
+	imported, err := s.db.ImportNDJSON("Users", strings.NewReader(file), &tdb.JSONOptions{Columns: map[string]string{"full_name": "name"}})
+	var importError *tdb.ImportError
+	if !errors.As(err, &importError) || imported != 1 {
+		s.FailNow("Expected ImportError and 1 row imported", fmt.Sprintf("Recibe: %d %v", imported, err))
+	}
+	lines := importError.Lines()
+	if len(lines) != 4 || lines[0].Line != 1 || lines[1].Line != 3 || lines[2].Line != 4 || lines[3].Line != 5 {
+		s.Fail("Expected errors on lines 1, 3, 4 and 5", fmt.Sprintf("Recibe: %v", lines))
+	}
+
+	imported, err = s.db.ImportNDJSON("Users", strings.NewReader(`{"full_name": "maria lopez", "age": 41}`), &tdb.JSONOptions{Columns: map[string]string{"full_name": "name"}})
+	if err != nil || imported != 1 {
+		s.Fail("Expected 1 row imported", fmt.Sprintf("Recibe: %d %v", imported, err))
+	}
+	tb, _ := s.db.GetTableByName("Users")
+	row, _ := tb.SearchOne("name", "maria lopez")
+	if row.SearchValue("age") != "41" {
+		s.Fail("Expected age 41", fmt.Sprintf("Recibe: %s", row.String()))
+	}
+	row, _ = tb.SearchOne("name", "luis")
+	if row.SearchValue("age") != "null" {
+		s.Fail("Expected a null age", fmt.Sprintf("Recibe: %s", row.String()))
+	}
+}
+
+func (s *jsonSuite) TestExportJSON_ImportJSON() {
+	var buffer bytes.Buffer
+	if err := s.db.ExportJSON(&buffer); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if !strings.Contains(buffer.String(), `"name": "Houses"`) || !strings.Contains(buffer.String(), `"ForeignColumnName": "id_owner"`) {
+		s.Fail("Expected the tables and foreign keys", fmt.Sprintf("Recibe: %s", buffer.String()))
+	}
+
+	db, _ := tdb.Create("testDbJsonCopy.txt", &tdb.Options{Storage: tdb.NewMemoryStorage()})
+	if err := db.ImportJSON(&buffer); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	houses, err := db.GetTableByName("Houses")
+	if err != nil || len(houses.GetRows()) != 1 {
+		s.Fail("Expected Houses with 1 row", fmt.Sprintf("Recibe: %v", err))
+	}
+	keys := db.ForeignKeys()
+	if len(keys) != 1 || keys[0].TableName != "Users" || keys[0].ForeignColumnName != "id_owner" {
+		s.Fail("Expected the foreign key", fmt.Sprintf("Recibe: %v", keys))
+	}
+	users, _ := db.GetTableByName("Users")
+	if err = users.DeleteRow("1", false); err == nil {
+		s.Fail("Expected ForeignKeyViolationError")
+	}
+}
+
+func (s *jsonSuite) TestImportJSON_RollbackOnError() {
+	document := `{"tables": [
+		{"name": "Cars", "columns": ["id", "model"], "rows": [{"id": "1", "model": "a"}]},
+		{"name": "Users", "columns": ["id", "name", "age"], "rows": [{"id": "1", "name": "pedro", "age": "3"}]}
+	], "foreignKeys": []}`
+	err := s.db.ImportJSON(strings.NewReader(document))
+	if err == nil || !strings.Contains(err.Error(), "table Users row 1") {
+		s.Fail("Expected the duplicated id error", fmt.Sprintf("Recibe: %v", err))
+	}
+	if _, err = s.db.GetTableByName("Cars"); err == nil {
+		s.Fail("Expected Cars to be rolled back")
+	}
+}
+
+func TestJson(t *testing.T) {
+	suite.Run(t, new(jsonSuite))
+}
@@ -0,0 +1,441 @@
+package Test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func createTableMigration(id string, tableName string, columns ...string) tdb.Migration {
+	return tdb.Migration{
+		ID: id,
+		Up: func(db tdb.Db) error {
+			_, err := db.NewTable(tableName, columns)
+			return err
+		},
+		Down: func(db tdb.Db) error {
+			return db.DeleteTable(tableName)
+		},
+	}
+}
+
+func (s *migrationSuite) TestMigrate() {
+	calls := 0
+	seed := tdb.Migration{
+		ID: "0002_seed_users",
+		Up: func(db tdb.Db) error {
+			calls++
+			tb, err := db.GetTableByName("Users")
+			if err != nil {
+				return err
+			}
+			return tb.AddValues("pedro")
+		},
+	}
+	migrations := []tdb.Migration{createTableMigration("0001_create_users", "Users", "name"), seed}
+	if err := tdb.Migrate(s.db, migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if err := tdb.Migrate(s.db, migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if calls != 1 {
+		s.Fail("Expected the migration to run once", fmt.Sprintf("Recibe: %d", calls))
+	}
+	rows, err := s.db.SystemTableRows("__tdb_migrations")
+	if err != nil || len(rows) != 2 || rows[1].SearchValue("migration") != "0002_seed_users" {
+		s.Fail("Expected 2 applied migrations", fmt.Sprintf("Recibe: %v %v", rows, err))
+	}
+	for _, tb := range s.db.GetTables() {
+		if tb.GetName() == "-----__tdb_migrations-----" {
+			s.Fail("Expected the migrations table to be hidden")
+		}
+	}
+}
+
+func (s *migrationSuite) TestMigrate_RollbackOnError() {
+	failing := tdb.Migration{
+		ID: "0002_fail",
+		Up: func(db tdb.Db) error {
+			return errors.New("boom")
+		},
+	}
+	err := tdb.Migrate(s.db, createTableMigration("0001_create_users", "Users", "name"), failing)
+	if err == nil {
+		s.Fail("Expected error")
+	}
+	if _, err = s.db.GetTableByName("Users"); err == nil {
+		s.Fail("Expected Users to be rolled back")
+	}
+	if rows, _ := s.db.SystemTableRows("__tdb_migrations"); len(rows) != 0 {
+		s.Fail("Expected no applied migration", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func (s *migrationSuite) TestMigrate_ReturnValidationError() {
+	m := createTableMigration("0001_create_users", "Users", "name")
+	if err := tdb.Migrate(s.db, m, m); err == nil {
+		s.Fail("Expected duplicate ID error")
+	}
+	if err := tdb.Migrate(s.db, tdb.Migration{ID: "0001"}); err == nil {
+		s.Fail("Expected missing Up error")
+	}
+}
+
+func (s *migrationSuite) TestMigrateTo() {
+	migrations := []tdb.Migration{
+		createTableMigration("0001_create_users", "Users", "name"),
+		createTableMigration("0002_create_houses", "Houses", "direction"),
+		createTableMigration("0003_create_cars", "Cars", "model"),
+	}
+	if err := s.db.MigrateTo("0002_create_houses", migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if s.db.MigrationVersion() != "0002_create_houses" || len(s.db.GetTables()) != 2 {
+		s.Fail("Expected version 0002", fmt.Sprintf("Recibe: %s", s.db.MigrationVersion()))
+	}
+	if err := s.db.MigrateTo("0003_create_cars", migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if err := s.db.MigrateTo("0001_create_users", migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if s.db.MigrationVersion() != "0001_create_users" || len(s.db.GetTables()) != 1 {
+		s.Fail("Expected version 0001", fmt.Sprintf("Recibe: %s %d", s.db.MigrationVersion(), len(s.db.GetTables())))
+	}
+	if err := s.db.MigrateTo("", migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if s.db.MigrationVersion() != "" || len(s.db.GetTables()) != 0 {
+		s.Fail("Expected no version", fmt.Sprintf("Recibe: %s", s.db.MigrationVersion()))
+	}
+	var notFound *tdb.NotFoundError
+	if err := s.db.MigrateTo("0009_missing", migrations...); !errors.As(err, &notFound) {
+		s.Fail("Expected NotFoundError", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *migrationSuite) TestMigrateTo_ReturnIrreversibleMigrationError() {
+	irreversible := createTableMigration("0002_create_houses", "Houses", "direction")
+	irreversible.Down = nil
+	migrations := []tdb.Migration{
+		createTableMigration("0001_create_users", "Users", "name"),
+		irreversible,
+		createTableMigration("0003_create_cars", "Cars", "model"),
+	}
+	_ = tdb.Migrate(s.db, migrations...)
+	err := s.db.MigrateTo("0001_create_users", migrations...)
+	if !errors.Is(err, tdb.ErrIrreversibleMigration) {
+		s.Fail("Expected ErrIrreversibleMigration", fmt.Sprintf("Recibe: %v", err))
+	}
+	if s.db.MigrationVersion() != "0003_create_cars" || len(s.db.GetTables()) != 3 {
+		s.Fail("Expected nothing to be rolled back", fmt.Sprintf("Recibe: %s", s.db.MigrationVersion()))
+	}
+	if err = s.db.MigrateTo("0002_create_houses", migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+// readGeneratedMigration returns the content of the generated migration file with the name
+func readGeneratedMigration(name string) string {
+	entries, _ := os.ReadDir("migrations")
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), name+"_Migration_") {
+			data, _ := os.ReadFile("migrations/" + entry.Name())
+			return string(data)
+		}
+	}
+	return ""
+}
+
+func (s *migrationSuite) TestCreateMigration_GeneratesSchemaDiff() {
+	defer os.RemoveAll("migrations")
+	config := tdb.DbConfig{DatabaseName: "testDbMigration.txt"}
+	users, _ := s.db.NewTable("Users", []string{"name", "age"})
+	_ = users.AddValues("pedro", "20")
+	config.CreateMigration("init")
+	if code := readGeneratedMigration("init"); !strings.Contains(code, `db.NewTable("Users", []string{"name", "age"})`) {
+		s.Fail("Expected the Users table to be created", fmt.Sprintf("Recibe: %s", code))
+	}
+
+	users, _ = s.db.GetTableByName("Users")
+	_ = users.AddColumn("email")
+	_ = users.UpdateColumnName("age", "years")
+	_, _ = s.db.NewTable("Houses", []string{"direction", "id_owner"})
+	_ = s.db.AddForeignKey(tdb.ForeignKey{TableName: "Users", ColumnName: "id", ForeignTableName: "Houses", ForeignColumnName: "id_owner"})
+	config.CreateMigration("changes")
+	code := readGeneratedMigration("changes")
+	for _, expected := range []string{
+		`addColumn(db, "Users", "email")`,
+		`renameColumn(db, "Users", "age", "years")`,
+		`db.NewTable("Houses", []string{"direction", "id_owner"})`,
+		`db.AddForeignKey(tdb.ForeignKey{TableName: "Users", ColumnName: "id", ForeignTableName: "Houses", ForeignColumnName: "id_owner"`,
+	} {
+		if !strings.Contains(code, expected) {
+			s.Fail("Expected "+expected, fmt.Sprintf("Recibe: %s", code))
+		}
+	}
+	if strings.Contains(code, `db.NewTable("Users"`) || strings.Contains(code, "pedro") {
+		s.Fail("Expected only the schema changes", fmt.Sprintf("Recibe: %s", code))
+	}
+
+	config.CreateMigration("nothing")
+	if code = readGeneratedMigration("nothing"); code != "" {
+		s.Fail("Expected no migration without schema changes", fmt.Sprintf("Recibe: %s", code))
+	}
+}
+
+// legacyMigration is a migration file written by the first version of the generator
+const legacyMigration = `
+package migrations
+
+import "text-database/tdb"
+
+	// migrationName        = "legacy"
+	// databaseName         = "testDbMigration.txt"
+	// migrationVersion     = 1
+	// migrationDate        = "2024-01-01 00:00:00 +0000 UTC"
+	// migrationDescription = "Initial Migration"
+
+func generateLegacy() {
+tablesS := &[]table{
+		{
+			name:    "Users",
+			columns: []string{"name","age"},
+			values:  []value{{value: []string{"1","O'Hara","20"}},},
+		},
+	}
+	config := tdb.DbConfig{EncryptionKey: "", DatabaseName: "testDbMigration.txt"}
+	db, err := config.CreateDatabase()
+	if err != nil {
+		return
+	}
+
+	for _, t := range *tablesS {
+		tb := db.NewTable(t.name, t.columns)
+		for _, v := range t.values {
+			tb = tb.AddValues(v.value)
+		}
+	}
+}`
+
+func (s *migrationSuite) TestCreateMigration_UpgradesLegacyFiles() {
+	defer os.RemoveAll("migrations")
+	_ = os.MkdirAll("migrations", 0755)
+	_ = os.WriteFile("migrations/legacy_Migration_1700000000.go", []byte(legacyMigration), 0644)
+	_, _ = s.db.NewTable("Houses", []string{"direction"})
+	tdb.DbConfig{DatabaseName: "testDbMigration.txt"}.CreateMigration("houses")
+
+	code := readGeneratedMigration("legacy")
+	for _, expected := range []string{
+		`import "github.com/sheymor21/text-database/tdb"`,
+		"var LegacyMigration = tdb.Migration{",
+		`ID: "legacy_Migration_1700000000",`,
+		`values:  []value{{value: []string{"1","O'Hara","20"}},},`,
+		"return createTables(db, *tablesS)",
+	} {
+		if !strings.Contains(code, expected) {
+			s.Fail("Expected "+expected, fmt.Sprintf("Recibe: %s", code))
+		}
+	}
+	if strings.Contains(code, "func generateLegacy()") {
+		s.Fail("Expected the generate function to be replaced", fmt.Sprintf("Recibe: %s", code))
+	}
+	constructor, _ := os.ReadFile("migrations/constructor.go")
+	if !strings.Contains(string(constructor), "\t\tLegacyMigration,\n\t\tHousesMigration,") {
+		s.Fail("Expected the legacy migration to be listed first", fmt.Sprintf("Recibe: %s", constructor))
+	}
+}
+
+func (s *migrationSuite) TestCreateSeedMigration() {
+	defer os.RemoveAll("migrations")
+	users, _ := s.db.NewTable("Users", []string{"name"})
+	_ = users.AddValues("pedro")
+	tdb.DbConfig{DatabaseName: "testDbMigration.txt"}.CreateSeedMigration("seed")
+	id := users.GetRows()[0].SearchValue("id")
+	code := readGeneratedMigration("seed")
+	insert := fmt.Sprintf(`INSERT INTO "Users" ("id", "name") VALUES ('%s', 'pedro')`, id)
+	remove := fmt.Sprintf(`DELETE FROM "Users" WHERE "id" = '%s'`, id)
+	if !strings.Contains(code, fmt.Sprintf("execSql(db, %q)", insert)) || !strings.Contains(code, fmt.Sprintf("execSql(db, %q)", remove)) {
+		s.Fail("Expected the rows of Users to be seeded", fmt.Sprintf("Recibe: %s", code))
+	}
+}
+
+func (s *migrationSuite) TestCreateSeedMigration_QuotedValues() {
+	defer os.RemoveAll("migrations")
+	users, _ := s.db.NewTable("Users", []string{"name", "street"})
+	_ = users.AddValues("O'Hara", "main st, 5")
+	_ = users.AddValues("juan  perez", "null")
+	tdb.DbConfig{DatabaseName: "testDbMigration.txt"}.CreateSeedMigration("seed")
+	code := readGeneratedMigration("seed")
+	_, quoted, _ := strings.Cut(code, "execSql(db, ")
+	quoted, err := strconv.QuotedPrefix(quoted)
+	if err != nil {
+		s.FailNow("Expected the insert statement", fmt.Sprintf("Recibe: %s", code))
+	}
+	insert, _ := strconv.Unquote(quoted)
+
+	db, _ := tdb.Create("testDbSeed.txt", &tdb.Options{Storage: tdb.NewMemoryStorage()})
+	_, _ = db.NewTable("Users", []string{"name", "street"})
+	if _, err = db.FromSql(insert); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ := db.GetTableByName("Users")
+	rows := tb.GetRows()
+	if len(rows) != 2 || rows[0].SearchValue("name") != "O'Hara" || rows[0].SearchValue("street") != "main st, 5" ||
+		rows[1].SearchValue("name") != "juan  perez" || rows[1].SearchValue("street") != "null" {
+		s.Fail("Expected the seeded values", fmt.Sprintf("Recibe: %v", rows))
+	}
+}
+
+func sqlMigrationFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_users.up.sql":   {Data: []byte("-- users of the app\nCREATE TABLE Users (name, age);\nINSERT INTO Users id name age\nVALUES 1 pedro 20;\n")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE Users;")},
+		"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE Users ADD COLUMN email;")},
+		"0002_add_email.down.sql":    {Data: []byte("ALTER TABLE Users DROP COLUMN email;")},
+		"README.md":                  {Data: []byte("not a migration")},
+	}
+}
+
+func (s *migrationSuite) TestLoadSqlMigrations() {
+	migrations, err := tdb.LoadSqlMigrations(sqlMigrationFiles())
+	if err != nil || len(migrations) != 2 || migrations[0].ID != "0001_create_users" || migrations[1].ID != "0002_add_email" {
+		s.Fail("Expected 2 sorted migrations", fmt.Sprintf("Recibe: %v %v", migrations, err))
+		return
+	}
+	if err = tdb.Migrate(s.db, migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ := s.db.GetTableByName("Users")
+	columns := strings.TrimSpace(strings.Join(tb.GetColumns(), " "))
+	if columns != "[1] id [2] name [3] age [4] email" || len(tb.GetRows()) != 1 {
+		s.Fail("Expected the Users table with one row", fmt.Sprintf("Recibe: %s %v", columns, tb.GetRows()))
+	}
+	rows, _ := s.db.SystemTableRows("__tdb_migrations")
+	if len(rows) != 2 || rows[0].SearchValue("checksum") != migrations[0].Checksum {
+		s.Fail("Expected the checksums to be recorded", fmt.Sprintf("Recibe: %v", rows))
+	}
+	if err = s.db.MigrateTo("", migrations...); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if _, err = s.db.GetTableByName("Users"); err == nil {
+		s.Fail("Expected the Users table to be dropped")
+	}
+}
+
+func (s *migrationSuite) TestLoadSqlMigrations_ReturnChecksumMismatchError() {
+	files := sqlMigrationFiles()
+	migrations, _ := tdb.LoadSqlMigrations(files)
+	_ = tdb.Migrate(s.db, migrations...)
+	files["0002_add_email.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE Users ADD COLUMN phone;")}
+	migrations, _ = tdb.LoadSqlMigrations(files)
+	err := tdb.Migrate(s.db, migrations...)
+	if !errors.Is(err, tdb.ErrMigrationChecksumMismatch) {
+		s.Fail("Expected ErrMigrationChecksumMismatch", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func (s *migrationSuite) TestLoadSqlMigrations_ReturnMissingUpError() {
+	files := sqlMigrationFiles()
+	delete(files, "0002_add_email.up.sql")
+	if _, err := tdb.LoadSqlMigrations(files); err == nil {
+		s.Fail("Expected an error for a migration without up file")
+	}
+}
+
+func (s *migrationSuite) TestPlanMigrations_ReturnTransactionInProgressError() {
+	_ = s.db.Begin()
+	defer func() { _ = s.db.Rollback() }()
+	_, err := tdb.PlanMigrations(s.db, createTableMigration("0001_create_users", "Users", "name"))
+	if !errors.Is(err, tdb.ErrTransactionInProgress) {
+		s.Fail("Expected ErrTransactionInProgress", fmt.Sprintf("Recibe: %v", err))
+	}
+	if _, err = s.db.NewTable("Users", []string{"name"}); err != nil {
+		s.Fail("Expected the transaction to be kept", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+func (s *migrationSuite) TestPlanMigrations() {
+	seed := tdb.Migration{
+		ID: "0002_seed_users",
+		Up: func(db tdb.Db) error {
+			tb, err := db.GetTableByName("Users")
+			if err != nil {
+				return err
+			}
+			if err = tb.AddValues("pedro"); err != nil {
+				return err
+			}
+			_, err = db.FromSql("INSERT INTO Users id name VALUES 9 juan")
+			return err
+		},
+	}
+	migrations := []tdb.Migration{createTableMigration("0001_create_users", "Users", "name"), seed}
+	plan, err := tdb.PlanMigrations(s.db, migrations...)
+	if err != nil || plan.Err() != nil || len(plan.Migrations) != 2 {
+		s.Fail("Expected 2 planned migrations", fmt.Sprintf("Recibe: %v %v", plan, err))
+		return
+	}
+	operations := plan.Migrations[1].Operations
+	if len(operations) != 2 || operations[0].Operation != "AddValues Users" || operations[0].AffectedRows != 1 ||
+		operations[1].Operation != "SQL INSERT INTO Users id name VALUES 9 juan" || operations[1].AffectedRows != 1 {
+		s.Fail("Expected the seed operations", fmt.Sprintf("Recibe: %v", operations))
+	}
+	if plan.Migrations[0].Operations[0].Operation != "NewTable Users (name)" {
+		s.Fail("Expected NewTable Users (name)", fmt.Sprintf("Recibe: %v", plan.Migrations[0].Operations))
+	}
+	if _, err = s.db.GetTableByName("Users"); err == nil {
+		s.Fail("Expected the plan to leave the database unchanged")
+	}
+	if version := s.db.MigrationVersion(); version != "" {
+		s.Fail("Expected no applied migration", fmt.Sprintf("Recibe: %s", version))
+	}
+}
+
+func (s *migrationSuite) TestPlanMigrations_ReportForeignKeyViolation() {
+	users, _ := s.db.NewTable("Users", []string{"name"})
+	houses, _ := s.db.NewTable("Houses", []string{"id_owner"})
+	_ = users.AddValues("pedro")
+	id := users.GetRows()[0].SearchValue("id")
+	_ = houses.AddValues(id)
+	_ = s.db.AddForeignKey(tdb.ForeignKey{TableName: "Users", ColumnName: "id", ForeignTableName: "Houses", ForeignColumnName: "id_owner"})
+	remove := tdb.Migration{
+		ID: "0001_remove_pedro",
+		Up: func(db tdb.Db) error {
+			tb, _ := db.GetTableByName("Users")
+			return tb.DeleteRow(id, false)
+		},
+	}
+	plan, err := tdb.PlanMigrations(s.db, remove)
+	var violation *tdb.ForeignKeyViolationError
+	if err != nil || !errors.As(plan.Migrations[0].Err, &violation) || !errors.As(plan.Err(), &violation) {
+		s.Fail("Expected a ForeignKeyViolationError", fmt.Sprintf("Recibe: %v %v", plan, err))
+	}
+	if users, _ = s.db.GetTableByName("Users"); len(users.GetRows()) != 1 {
+		s.Fail("Expected the plan to leave the database unchanged")
+	}
+}
+
+func (s *migrationSuite) TestPlanMigrateTo() {
+	migrations := []tdb.Migration{createTableMigration("0001_create_users", "Users", "name")}
+	_ = tdb.Migrate(s.db, migrations...)
+	plan, err := s.db.PlanMigrateTo("", migrations...)
+	if err != nil || len(plan.Migrations) != 1 || !plan.Migrations[0].Down ||
+		plan.Migrations[0].Operations[0].Operation != "DeleteTable Users" {
+		s.Fail("Expected the rollback of 0001_create_users", fmt.Sprintf("Recibe: %v %v", plan, err))
+	}
+	if s.db.MigrationVersion() != "0001_create_users" {
+		s.Fail("Expected the plan to leave the migrations applied")
+	}
+}
+
+func TestMigration(t *testing.T) {
+	suite.Run(t, new(migrationSuite))
+}
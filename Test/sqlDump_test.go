@@ -0,0 +1,128 @@
+package Test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+func (s *sqlDumpSuite) TestDump() {
+	var buffer bytes.Buffer
+	if err := s.db.Dump(&buffer); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	expected := `-- text-database dump of testDbCsv.txt
+
+CREATE TABLE "Users" (
+  "id" TEXT PRIMARY KEY,
+  "name" TEXT,
+  "age" TEXT
+);
+INSERT INTO "Users" ("id", "name", "age") VALUES ('1', 'pedro perez', '20');
+INSERT INTO "Users" ("id", "name", "age") VALUES ('2', 'juan', '30');
+
+CREATE TABLE "Houses" (
+  "id" TEXT PRIMARY KEY,
+  "direction" TEXT,
+  "id_owner" TEXT,
+  FOREIGN KEY ("id_owner") REFERENCES "Users" ("id")
+);
+INSERT INTO "Houses" ("id", "direction", "id_owner") VALUES ('1', 'pedro_avenue', '1');
+`
+	if buffer.String() != expected {
+		s.Fail("Expected the SQL script", fmt.Sprintf("Recibe: %s", buffer.String()))
+	}
+}
+
+func (s *sqlDumpSuite) TestDump_Restore() {
+	errorHandler(s.db.DropForeignKey(tdb.ForeignKey{TableName: "Users", ColumnName: "id", ForeignTableName: "Houses", ForeignColumnName: "id_owner"}))
+	errorHandler(s.db.AddForeignKey(tdb.ForeignKey{
+		TableName: "Users", ColumnName: "id", ForeignTableName: "Houses", ForeignColumnName: "id_owner",
+		OnDelete: tdb.SetDefault, OnUpdate: tdb.Cascade, DefaultValue: "2",
+	}))
+	users, _ := s.db.GetTableByName("Users")
+	errorHandler(users.AddColumn("notes"))
+	errorHandler(users.AddValues("o'neil; --x", "40", "first line\nsecond line"))
+	var buffer bytes.Buffer
+	errorHandler(s.db.Dump(&buffer))
+
+	db, _ := tdb.Create("testDbRestore.txt", &tdb.Options{Storage: tdb.NewMemoryStorage()})
+	if err := db.Restore(&buffer); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	restored, _ := db.GetTableByName("Users")
+	if len(restored.GetRows()) != 3 {
+		s.Fail("Expected len of 3", fmt.Sprintf("Recibe: %d", len(restored.GetRows())))
+	}
+	row, err := restored.SearchOne("name", "o'neil; --x")
+	if err != nil || row.SearchValue("notes") != "first line\nsecond line" || row.SearchValue("age") != "40" {
+		s.Fail("Expected the quoted values", fmt.Sprintf("Recibe: %s %v", row.String(), err))
+	}
+	row, _ = restored.GetRowById("1")
+	if row.SearchValue("name") != "pedro perez" || row.SearchValue("notes") != "null" {
+		s.Fail("Expected pedro perez with null notes", fmt.Sprintf("Recibe: %s", row.String()))
+	}
+	keys := db.ForeignKeys()
+	if len(keys) != 1 || keys[0].OnDelete != tdb.SetDefault || keys[0].OnUpdate != tdb.Cascade || keys[0].DefaultValue != "2" {
+		s.Fail("Expected the foreign key with its actions", fmt.Sprintf("Recibe: %v", keys))
+	}
+}
+
+func (s *sqlDumpSuite) TestDump_RestoreSelfReference() {
+	employees, _ := s.db.NewTable("Employees", []string{"name", "boss"})
+	errorHandler(s.db.AddForeignKey(tdb.ForeignKey{TableName: "Employees", ColumnName: "id", ForeignTableName: "Employees", ForeignColumnName: "boss"}))
+	_, err := s.db.FromSql("INSERT INTO Employees (id, name, boss) VALUES ('1', 'ana', NULL), ('2', 'luis', '1')")
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	employees, _ = s.db.GetTableByName("Employees")
+	errorHandler(employees.UpdateValue("boss", "1", "2"))
+	var buffer bytes.Buffer
+	errorHandler(s.db.Dump(&buffer))
+
+	db, _ := tdb.Create("testDbRestore.txt", &tdb.Options{Storage: tdb.NewMemoryStorage()})
+	if err = db.Restore(&buffer); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	restored, _ := db.GetTableByName("Employees")
+	if row, _ := restored.GetRowById("1"); len(restored.GetRows()) != 2 || row.SearchValue("boss") != "2" {
+		s.Fail("Expected the employees with their bosses", fmt.Sprintf("Recibe: %v", restored.GetRows()))
+	}
+}
+
+func (s *sqlDumpSuite) TestRestore_RollbackOnError() {
+	script := "CREATE TABLE Cars (model);\nINSERT INTO Cars (id, model) VALUES ('1', 'a');\nINSERT INTO Houses (id, direction, id_owner) VALUES ('2', 'north', '9');"
+	err := s.db.Restore(strings.NewReader(script))
+	var violation *tdb.ForeignKeyViolationError
+	if !errors.As(err, &violation) {
+		s.Fail("Expected ForeignKeyViolationError", fmt.Sprintf("Recibe: %v", err))
+	}
+	if _, err = s.db.GetTableByName("Cars"); err == nil {
+		s.Fail("Expected Cars to be rolled back")
+	}
+}
+
+func (s *sqlDumpSuite) TestFromSql_QuotedValues() {
+	if _, err := s.db.FromSql("INSERT INTO Users (id, name, age) VALUES ('3', 'maria lopez', NULL)"); err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	data, err := s.db.FromSql("SELECT name , age FROM Users WHERE name = 'maria lopez'")
+	if err != nil || len(data.Rows) != 1 || data.Rows[0].SearchValue("age") != "null" {
+		s.Fail("Expected maria lopez with a null age", fmt.Sprintf("Recibe: %v %v", data.Rows, err))
+	}
+}
+
+func (s *sqlDumpSuite) TestFromSql_CreateExistingTable() {
+	_, err := s.db.FromSql("CREATE TABLE Users (name)")
+	if !errors.Is(err, tdb.ErrTableExists) {
+		s.Fail("Expected ErrTableExists", fmt.Sprintf("Recibe: %v", err))
+	}
+}
+
+func TestSqlDump(t *testing.T) {
+	suite.Run(t, new(sqlDumpSuite))
+}
@@ -0,0 +1,208 @@
+package Test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type memoryStorageSuite struct {
+	suite.Suite
+	db      tdb.Db
+	storage *tdb.MemoryStorage
+}
+
+func (s *memoryStorageSuite) SetupTest() {
+	s.storage = tdb.NewMemoryStorage()
+	config := tdb.DbConfig{DatabaseName: "testDbMemory.txt", Storage: s.storage}
+	s.db, _ = config.CreateDatabase()
+}
+
+func (s *memoryStorageSuite) TestDatabaseNotWrittenToDisk() {
+	_, _ = s.db.NewTable("Test", []string{"name", "age"})
+	if _, err := os.Stat("testDbMemory.txt"); err == nil {
+		s.Fail("Expected no file on disk")
+	}
+	data, _ := s.storage.Read()
+	if len(data) == 0 {
+		s.Fail("Expected data in memory storage")
+	}
+}
+
+func (s *memoryStorageSuite) TestAddValues() {
+	tb, _ := s.db.GetTableByName("Users")
+	_ = tb.AddValues("test", "20")
+	tb, _ = s.db.GetTableByName("Users")
+	rows := tb.GetRows()
+	if len(rows) != 5 {
+		s.Fail("Expected len of 5", fmt.Sprintf("Recibe: %d", len(rows)))
+	}
+}
+
+func (s *memoryStorageSuite) TestFSStorage() {
+	layout, _ := s.storage.Read()
+	fsys := fstest.MapFS{"embedded.txt": &fstest.MapFile{Data: layout}}
+	config := tdb.DbConfig{DatabaseName: "embedded.txt", Storage: tdb.NewFSStorage(fsys, "embedded.txt")}
+	db, err := config.CreateDatabase()
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, err := db.GetTableByName("Users")
+	if err != nil {
+		s.Fail("Expected Users Table", fmt.Sprintf("Recibe: %s", err))
+	}
+	if len(tb.GetRows()) != 4 {
+		s.Fail("Expected len of 4", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+}
+
+type streamStorageSuite struct {
+	suite.Suite
+	db   tdb.Db
+	file *os.File
+}
+
+func (s *streamStorageSuite) SetupTest() {
+	s.file, _ = os.CreateTemp(s.T().TempDir(), "stream*.txt")
+	config := tdb.DbConfig{DatabaseName: "testDbStream.txt", Storage: tdb.NewStreamStorage(s.file)}
+	s.db, _ = config.CreateDatabase()
+}
+
+func (s *streamStorageSuite) TearDownTest() {
+	_ = s.file.Close()
+}
+
+func (s *streamStorageSuite) TestExists() {
+	empty, _ := os.CreateTemp(s.T().TempDir(), "empty*.txt")
+	defer empty.Close()
+	if tdb.NewStreamStorage(empty).Exists() {
+		s.Fail("Expected an empty stream not to exist")
+	}
+	if !tdb.NewStreamStorage(s.file).Exists() {
+		s.Fail("Expected the database stream to exist")
+	}
+}
+
+func (s *streamStorageSuite) TestReopen() {
+	tb, _ := s.db.GetTableByName("Users")
+	_ = tb.AddValues("test", "20")
+	config := tdb.DbConfig{DatabaseName: "testDbStream.txt", Storage: tdb.NewStreamStorage(s.file)}
+	db, err := config.CreateDatabase()
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ = db.GetTableByName("Users")
+	if len(tb.GetRows()) != 5 {
+		s.Fail("Expected len of 5", fmt.Sprintf("Recibe: %d", len(tb.GetRows())))
+	}
+}
+
+func (s *streamStorageSuite) TestWriteTruncates() {
+	storage := tdb.NewStreamStorage(s.file)
+	_ = storage.Write([]byte("short"))
+	data, err := storage.Read()
+	if err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if string(data) != "short" {
+		s.Fail("Expected short", fmt.Sprintf("Recibe: %q", data))
+	}
+}
+
+func (s *streamStorageSuite) TestWriteReturnsNotTruncatableError() {
+	stream := struct{ io.ReadWriteSeeker }{s.file}
+	storage := tdb.NewStreamStorage(stream)
+	if err := storage.Write([]byte("short")); !errors.Is(err, tdb.ErrStreamNotTruncatable) {
+		s.Fail("Expected ErrStreamNotTruncatable", fmt.Sprintf("Recibe: %v", err))
+	}
+	if data, _ := storage.Read(); !strings.Contains(string(data), "-----Users-----") {
+		s.Fail("Expected the stream to be left as it was", fmt.Sprintf("Recibe: %q", data))
+	}
+}
+
+type fileStorageSuite struct {
+	suite.Suite
+	path string
+}
+
+func (s *fileStorageSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "testDbFile.txt")
+}
+
+func (s *fileStorageSuite) TestWriteReplacesFile() {
+	storage := tdb.NewFileStorage(s.path)
+	if storage.Exists() {
+		s.Fail("Expected the file not to exist")
+	}
+	_ = storage.Write([]byte("first version"))
+	_ = storage.Write([]byte("second"))
+	data, _ := storage.Read()
+	if string(data) != "second" {
+		s.Fail("Expected second", fmt.Sprintf("Recibe: %q", data))
+	}
+	entries, _ := os.ReadDir(filepath.Dir(s.path))
+	if len(entries) != 1 {
+		s.Fail("Expected no temporary files left behind", fmt.Sprintf("Recibe: %d entries", len(entries)))
+	}
+}
+
+func (s *fileStorageSuite) TestWriteKeepsPermissions() {
+	storage := tdb.NewFileStorage(s.path)
+	_ = storage.Write([]byte("first version"))
+	if info, _ := os.Stat(s.path); info.Mode().Perm() != 0600 {
+		s.Fail("Expected a new file to be 0600", fmt.Sprintf("Recibe: %s", info.Mode().Perm()))
+	}
+	_ = os.Chmod(s.path, 0640)
+	_ = storage.Write([]byte("second"))
+	if info, _ := os.Stat(s.path); info.Mode().Perm() != 0640 {
+		s.Fail("Expected the file to keep 0640", fmt.Sprintf("Recibe: %s", info.Mode().Perm()))
+	}
+}
+
+func (s *fileStorageSuite) TestWriteFollowsSymlink() {
+	link := filepath.Join(filepath.Dir(s.path), "link.txt")
+	_ = os.WriteFile(s.path, []byte("first version"), 0600)
+	if err := os.Symlink(s.path, link); err != nil {
+		s.T().Skip("symlinks are not supported")
+	}
+	_ = tdb.NewFileStorage(link).Write([]byte("second"))
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		s.Fail("Expected the symlink to be kept", fmt.Sprintf("Recibe: %s", info.Mode()))
+	}
+	if data, _ := os.ReadFile(s.path); string(data) != "second" {
+		s.Fail("Expected the target to be written", fmt.Sprintf("Recibe: %q", data))
+	}
+}
+
+func (s *fileStorageSuite) TestFSStorageIsReadOnly() {
+	fsys := fstest.MapFS{"db.txt": &fstest.MapFile{Data: []byte("data")}}
+	storage := tdb.NewFSStorage(fsys, "db.txt")
+	if !storage.Exists() {
+		s.Fail("Expected the file to exist")
+	}
+	if err := storage.Write([]byte("x")); err != tdb.ErrReadOnly {
+		s.Fail("Expected ErrReadOnly", fmt.Sprintf("Recibe: %v", err))
+	}
+	if tdb.NewFSStorage(fsys, "missing.txt").Exists() {
+		s.Fail("Expected missing file not to exist")
+	}
+}
+
+func TestStorage(t *testing.T) {
+	t.Run("TestSet: MemoryStorage", func(t *testing.T) {
+		suite.Run(t, &memoryStorageSuite{})
+	})
+	t.Run("TestSet: StreamStorage", func(t *testing.T) {
+		suite.Run(t, &streamStorageSuite{})
+	})
+	t.Run("TestSet: FileStorage", func(t *testing.T) {
+		suite.Run(t, &fileStorageSuite{})
+	})
+}
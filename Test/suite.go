@@ -26,6 +26,52 @@ type tableSuiteWithStaticData struct {
 type databaseWithEncryptionSuite struct {
 	databaseSuite
 }
+type databaseReadOnlySuite struct {
+	suite.Suite
+	db tdb.Db
+}
+type encryptionSuite struct {
+	suite.Suite
+	storage *tdb.MemoryStorage
+	options *tdb.Options
+}
+type integritySuite struct {
+	suite.Suite
+	storage *tdb.MemoryStorage
+}
+type foreignKeySuite struct {
+	suite.Suite
+	db      tdb.Db
+	storage *tdb.MemoryStorage
+}
+type migrationSuite struct {
+	suite.Suite
+	db      tdb.Db
+	storage *tdb.MemoryStorage
+}
+type csvSuite struct {
+	suite.Suite
+	db      tdb.Db
+	storage *tdb.MemoryStorage
+}
+type jsonSuite struct {
+	csvSuite
+}
+type sqlDumpSuite struct {
+	csvSuite
+}
+type backupSuite struct {
+	csvSuite
+}
+type checkSuite struct {
+	csvSuite
+}
+type formatSuite struct {
+	csvSuite
+}
+type databaseOpenSuite struct {
+	suite.Suite
+}
 type databaseWithStaticDataSuite struct {
 	suite.Suite
 	db       tdb.Db
@@ -111,6 +157,93 @@ func (s *databaseWithStaticDataSuite) TearDownTest() {
 	errorHandler(os.Remove("testDbWithStaticData.txt"))
 }
 
+func (s *databaseReadOnlySuite) SetupTest() {
+	config := tdb.DbConfig{EncryptionKey: "", DatabaseName: "testDbReadOnly.txt"}
+	_, _ = config.CreateDatabase()
+	config.ReadOnly = true
+	s.db, _ = config.CreateDatabase()
+}
+
+func (s *databaseReadOnlySuite) TearDownTest() {
+	errorHandler(os.Remove("testDbReadOnly.txt"))
+}
+
+func (s *databaseOpenSuite) TearDownTest() {
+	if _, err := os.Stat("testDbOpen.txt"); err == nil {
+		errorHandler(os.Remove("testDbOpen.txt"))
+	}
+}
+
+func (s *encryptionSuite) SetupTest() {
+	s.storage = tdb.NewMemoryStorage()
+	s.options = &tdb.Options{
+		EncryptionKey: "secret",
+		Storage:       s.storage,
+		KeyDerivation: &tdb.KeyDerivation{N: 1024, R: 8, P: 1},
+	}
+}
+
+func (s *integritySuite) SetupTest() {
+	s.storage = tdb.NewMemoryStorage()
+	config := tdb.DbConfig{DatabaseName: "testDbIntegrity.txt", Storage: s.storage, Checksums: true}
+	_, _ = config.CreateDatabase()
+}
+
+func (s *foreignKeySuite) SetupTest() {
+	s.storage = tdb.NewMemoryStorage()
+	s.db, _ = tdb.Create("testDbForeignKey.txt", &tdb.Options{
+		Storage: s.storage,
+		Seed: []tdb.DataConfig{
+			{
+				TableName: "Users",
+				Columns:   []string{"name"},
+				Values:    []tdb.Values{{"1", "pedro"}, {"2", "juan"}},
+			},
+			{
+				TableName: "Houses",
+				Columns:   []string{"direction", "id_owner"},
+				Values:    []tdb.Values{{"1", "pedro_avenue", "1"}},
+			},
+		},
+	})
+	errorHandler(s.db.AddForeignKey(tdb.ForeignKey{
+		TableName:         "Users",
+		ColumnName:        "id",
+		ForeignTableName:  "Houses",
+		ForeignColumnName: "id_owner",
+	}))
+}
+
+func (s *migrationSuite) SetupTest() {
+	s.storage = tdb.NewMemoryStorage()
+	s.db, _ = tdb.Create("testDbMigration.txt", &tdb.Options{Storage: s.storage})
+}
+
+func (s *csvSuite) SetupTest() {
+	s.storage = tdb.NewMemoryStorage()
+	s.db, _ = tdb.Create("testDbCsv.txt", &tdb.Options{
+		Storage: s.storage,
+		Seed: []tdb.DataConfig{
+			{
+				TableName: "Users",
+				Columns:   []string{"name", "age"},
+				Values:    []tdb.Values{{"1", "pedro perez", "20"}, {"2", "juan", "30"}},
+			},
+			{
+				TableName: "Houses",
+				Columns:   []string{"direction", "id_owner"},
+				Values:    []tdb.Values{{"1", "pedro_avenue", "1"}},
+			},
+		},
+	})
+	errorHandler(s.db.AddForeignKey(tdb.ForeignKey{
+		TableName:         "Users",
+		ColumnName:        "id",
+		ForeignTableName:  "Houses",
+		ForeignColumnName: "id_owner",
+	}))
+}
+
 func (s *tableSuite) ErrFail(err error) {
 	expected := fmt.Sprintf("Expected %s", reflect.TypeOf(&tdb.NotFoundError{}))
 	recibe := fmt.Sprintf("Recibe: %s", reflect.TypeOf(err))
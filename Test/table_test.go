@@ -37,7 +37,7 @@ func (s *tableSuite) TestAddValue() {
 }
 func (s *tableSuite) TestAddValues() {
 	tb, _ := s.db.GetTableByName("Users")
-	tb.AddValues("test", "20")
+	_ = tb.AddValues("test", "20")
 	tb, _ = s.db.GetTableByName("Users")
 	rows := tb.GetRows()
 	id, index := getIdAndIndex(rows)
@@ -47,7 +47,7 @@ func (s *tableSuite) TestAddValues() {
 }
 func (s *tableSuite) TestUpdateTableName() {
 	tb, _ := s.db.GetTableByName("Users")
-	tb.UpdateTableName("Test")
+	_ = tb.UpdateTableName("Test")
 	tb, _ = s.db.GetTableByName("Test")
 	if tb.GetName() != "-----Test-----" {
 		s.Fail("Expected Test Table", fmt.Sprintf("Recibe: %s", tb))
@@ -93,6 +93,63 @@ func (s *tableSuite) TestDeleteColumn() {
 		s.Fail("Expected [1] id [2] name", fmt.Sprintf("Recibe: %s", columns))
 	}
 }
+func (s *tableSuite) TestAddColumn() {
+	tb, _ := s.db.GetTableByName("Users")
+	if err := tb.AddColumn("email"); err != nil {
+		s.Fail("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	tb, _ = s.db.GetTableByName("Users")
+	columns := strings.TrimSpace(strings.Join(tb.GetColumns(), " "))
+	if columns != "[1] id [2] name [3] age [4] email" {
+		s.Fail("Expected [1] id [2] name [3] age [4] email", fmt.Sprintf("Recibe: %s", columns))
+	}
+	if value := tb.GetRows()[0].SearchValue("email"); value != "null" {
+		s.Fail("Expected null", fmt.Sprintf("Recibe: %s", value))
+	}
+	if err := tb.AddColumn("email"); err == nil {
+		s.Fail("Expected an error for a duplicated column")
+	}
+}
+func (s *tableSuite) TestAddValues_KeepValuesWithSpaces() {
+	tb, _ := s.db.GetTableByName("Users")
+	_ = tb.AddValues("pedro perez", "20")
+	tb, _ = s.db.GetTableByName("Users")
+	_ = tb.AddValues("juan", "30")
+	_ = tb.AddColumn("email")
+	_ = tb.AddColumn("phone")
+	_ = tb.DeleteColumn("email")
+	row, err := tb.SearchOne("age", "20")
+	if err != nil || row.SearchValue("name") != "pedro perez" || row.SearchValue("phone") != "null" {
+		s.Fail("Expected pedro perez", fmt.Sprintf("Recibe: %s %v", row.String(), err))
+	}
+}
+func (s *tableSuite) TestOrderBy_ValuesWithSpaces() {
+	tb, _ := s.db.GetTableByName("Users")
+	_ = tb.AddValues("aaron perez", "99")
+	tb, _ = s.db.GetTableByName("Users")
+	rows := tb.GetRows()
+	_ = rows.OrderByDescend("name")
+	if rows[0].SearchValue("name") != "aaron perez" || rows[0].SearchValue("age") != "99" {
+		s.Fail("Expected aaron perez", fmt.Sprintf("Recibe: %s", rows[0].String()))
+	}
+}
+func (s *tableSuite) TestRowColumns() {
+	tb, _ := s.db.GetTableByName("Users")
+	row, _ := tb.GetRowById("2")
+	if columns := strings.Join(row.Columns(), " "); columns != "id name age" {
+		s.Fail("Expected id name age", fmt.Sprintf("Recibe: %s", columns))
+	}
+}
+func (s *tableSuite) TestDeleteColumn_KeepOtherValues() {
+	tb, _ := s.db.GetTableByName("Users")
+	_ = tb.AddValues("pedro perez", "20")
+	tb, _ = s.db.GetTableByName("Users")
+	_ = tb.DeleteColumn("age")
+	tb, _ = s.db.GetTableByName("Users")
+	if _, err := tb.SearchOne("name", "pedro perez"); err != nil {
+		s.Fail("Expected pedro perez", fmt.Sprintf("Recibe: %v", err))
+	}
+}
 func (s *tableSuite) TestAddValue_ReturnColumnError() {
 	tb, _ := s.db.GetTableByName("Users")
 	err := tb.AddValue("test", "value")
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// runTables lists the tables with their columns and number of rows
+func runTables(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	db, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	var records [][]string
+	for _, tb := range db.GetTables() {
+		records = append(records, []string{tableName(tb), strings.Join(tableColumns(tb), ", "), strconv.Itoa(len(tb.GetRows()))})
+	}
+	return c.write([]string{"table", "columns", "rows"}, records)
+}
+
+// runSchema shows the columns of a table with the foreign keys they take part in
+func runSchema(c *cli, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	db, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	header, records, err := schemaRecords(db, args[1])
+	if err != nil {
+		return err
+	}
+	return c.write(header, records)
+}
+
+// runQuery runs an SQL statement and writes the selected rows or the number of affected rows
+func runQuery(c *cli, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	sql := strings.TrimSpace(args[1])
+	selecting := strings.HasPrefix(strings.ToUpper(sql), "SELECT")
+	db, err := c.open(args[0], selecting)
+	if err != nil {
+		return err
+	}
+	result, err := db.FromSql(sql)
+	if err != nil {
+		return err
+	}
+	if !selecting {
+		fmt.Fprintf(c.stdout, "%d rows affected\n", result.AffectRows)
+		return nil
+	}
+	header, records := rowRecords(result.Rows, nil)
+	return c.write(header, records)
+}
+
+// runInsert inserts a row built from column=value arguments, the missing columns are null
+func runInsert(c *cli, args []string) error {
+	if len(args) < 3 {
+		return errUsage
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	tb, err := db.GetTableByName(args[1])
+	if err != nil {
+		return err
+	}
+	record := map[string]string{}
+	for _, arg := range args[2:] {
+		column, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return errUsage
+		}
+		record[column] = value
+	}
+	if err = insertRecord(tb, record); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "1 row inserted")
+	return nil
+}
+
+// runExport writes every row of a table, or the whole database as a JSON document when no table is given
+func runExport(c *cli, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errUsage
+	}
+	if len(args) == 1 && c.format != "json" {
+		return errors.New("the whole database can only be exported with -format json")
+	}
+	db, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	var export func(w io.Writer) error
+	if len(args) == 1 {
+		export = db.ExportJSON
+	} else {
+		tb, err := db.GetTableByName(args[1])
+		if err != nil {
+			return err
+		}
+		switch c.format {
+		case "csv":
+			export = tb.ExportCSV
+		case "json":
+			export = tb.ExportJSON
+		case "ndjson":
+			export = tb.ExportNDJSON
+		default:
+			header, records := rowRecords(tb.GetRows(), tableColumns(tb))
+			return c.write(header, records)
+		}
+	}
+	w, closeOutput, err := c.writer()
+	if err != nil {
+		return err
+	}
+	if err = export(w); err != nil {
+		_ = closeOutput()
+		return err
+	}
+	return closeOutput()
+}
+
+// runImport inserts the rows of a CSV, JSON or NDJSON file into a table, creating it from the columns of the file
+// when it doesn't exist, or imports a JSON document written by export when no table is given
+func runImport(c *cli, args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errUsage
+	}
+	if c.format != "csv" && c.format != "json" && c.format != "ndjson" {
+		return fmt.Errorf("unknown format %q, use csv, json or ndjson", c.format)
+	}
+	if len(args) == 2 && c.format != "json" {
+		return errors.New("a whole database can only be imported with -format json")
+	}
+	file, err := os.Open(args[len(args)-1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	if len(args) == 2 {
+		if err = db.ImportJSON(file); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, "database imported")
+		return nil
+	}
+	imported := 0
+	switch c.format {
+	case "csv":
+		imported, err = db.ImportCSV(args[1], file, nil)
+	case "ndjson":
+		imported, err = db.ImportNDJSON(args[1], file, nil)
+	case "json":
+		imported, err = importJSONArray(db, args[1], file)
+	}
+	fmt.Fprintf(c.stdout, "%d rows imported\n", imported)
+	return err
+}
+
+// runDump writes the database as an SQL script
+func runDump(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	db, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	w, closeOutput, err := c.writer()
+	if err != nil {
+		return err
+	}
+	if err = db.Dump(w); err != nil {
+		_ = closeOutput()
+		return err
+	}
+	return closeOutput()
+}
+
+// runRestore runs an SQL script on the database, "-" reads it from the standard input
+func runRestore(c *cli, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	script := c.stdin
+	if args[1] != "-" {
+		file, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		script = file
+	}
+	if err = db.Restore(script); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "database restored")
+	return nil
+}
+
+// runBackup copies the database to a file, or with -keep to a directory of rotating backups
+func runBackup(c *cli, args []string) error {
+	if len(args) != 2 || c.keep < 0 {
+		return errUsage
+	}
+	db, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	opts := &tdb.BackupOptions{EncryptionKey: c.backupKey}
+	if opts.EncryptionKey == "" && c.backupKeyEnv != "" {
+		opts.EncryptionKey = os.Getenv(c.backupKeyEnv)
+	}
+	path := args[1]
+	if c.keep > 0 {
+		path, err = db.RotatingBackup(args[1], c.keep, opts)
+	} else {
+		err = db.Backup(path, opts)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "backup written to %s\n", path)
+	return nil
+}
+
+// runUpgrade rewrites the database in the current file format
+func runUpgrade(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	version := db.FormatVersion()
+	if err = db.Upgrade(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "database upgraded from format version %d to %d\n", version, db.FormatVersion())
+	return nil
+}
+
+// runEncrypt encrypts a plain database with the key
+func runEncrypt(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	encrypted, err := tdb.IsEncrypted(tdb.NewFileStorage(args[0]))
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return errors.New("the database is already encrypted")
+	}
+	hasColumns, err := tdb.HasEncryptedColumns(tdb.NewFileStorage(args[0]))
+	if err != nil {
+		return err
+	}
+	if hasColumns {
+		return errors.New("the database has encrypted columns")
+	}
+	key, err := c.encryptionKey()
+	if err != nil {
+		return err
+	}
+	if _, err = (tdb.DbConfig{DatabaseName: args[0], EncryptionKey: key}).CreateDatabase(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "database encrypted")
+	return nil
+}
+
+// runDecrypt removes the encryption of the database
+func runDecrypt(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	encrypted, err := tdb.IsEncrypted(tdb.NewFileStorage(args[0]))
+	if err != nil {
+		return err
+	}
+	if !encrypted {
+		return errors.New("the database is not encrypted")
+	}
+	key, err := c.encryptionKey()
+	if err != nil {
+		return err
+	}
+	if _, err = tdb.Open(args[0], &tdb.Options{EncryptionKey: key}); err != nil {
+		return err
+	}
+	if err = (tdb.DbConfig{DatabaseName: args[0], EncryptionKey: key}).RemoveEncryption(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "database decrypted")
+	return nil
+}
+
+// runMigrate applies the SQL migrations of a directory, or reports what they would do with -plan
+func runMigrate(c *cli, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	migrations, err := tdb.LoadSqlMigrationsDir(args[1])
+	if err != nil {
+		return err
+	}
+	db, err := c.open(args[0], c.plan)
+	if err != nil {
+		return err
+	}
+	version := c.to
+	if version == "none" {
+		version = ""
+	}
+	if c.plan {
+		var plan tdb.MigrationPlan
+		if c.to == "" {
+			plan, err = tdb.PlanMigrations(db, migrations...)
+		} else {
+			plan, err = db.PlanMigrateTo(version, migrations...)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(c.stdout, plan)
+		return plan.Err()
+	}
+	if c.to == "" {
+		err = tdb.Migrate(db, migrations...)
+	} else {
+		err = db.MigrateTo(version, migrations...)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "database at version %q\n", db.MigrationVersion())
+	return nil
+}
+
+// runCheck validates the database and lists the problems found, -repair rewrites the database without them
+func runCheck(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	opts, err := c.options(args[0], !c.repair)
+	if err != nil {
+		return err
+	}
+	var report tdb.CheckReport
+	if c.repair {
+		report, err = tdb.Repair(args[0], opts)
+	} else {
+		report, err = tdb.Check(args[0], opts)
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Issues) == 0 {
+		fmt.Fprintln(c.stdout, "ok")
+		return nil
+	}
+	records := make([][]string, len(report.Issues))
+	for i, issue := range report.Issues {
+		records[i] = []string{string(issue.Problem), issue.Table, strconv.Itoa(issue.Line), issue.Message, strconv.FormatBool(issue.Repaired)}
+	}
+	if err = c.write([]string{"problem", "table", "line", "message", "repaired"}, records); err != nil {
+		return err
+	}
+	if c.repair {
+		fmt.Fprintf(c.stderr, "%d rows quarantined in __tdb_quarantine\n", report.Quarantined)
+	}
+	if !report.OK() {
+		return fmt.Errorf("%d problems found", len(report.Issues))
+	}
+	return nil
+}
+
+// schemaRecords returns the columns of a table with the foreign keys they take part in
+func schemaRecords(db tdb.Db, name string) ([]string, [][]string, error) {
+	tb, err := db.GetTableByName(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, _ := db.ForeignKeysOf(name)
+	var records [][]string
+	for i, column := range tableColumns(tb) {
+		var references, referencedBy []string
+		for _, key := range keys {
+			if key.ForeignTableName == name && key.ForeignColumnName == column {
+				references = append(references, fmt.Sprintf("%s.%s (on delete %s, on update %s)", key.TableName, key.ColumnName, key.OnDelete, key.OnUpdate))
+			}
+			if key.TableName == name && key.ColumnName == column {
+				referencedBy = append(referencedBy, key.ForeignTableName+"."+key.ForeignColumnName)
+			}
+		}
+		records = append(records, []string{strconv.Itoa(i + 1), column, strings.Join(references, ", "), strings.Join(referencedBy, ", ")})
+	}
+	return []string{"position", "column", "references", "referenced_by"}, records, nil
+}
+
+// write writes the records in the format of the command to its output
+func (c *cli) write(header []string, records [][]string) error {
+	w, closeOutput, err := c.writer()
+	if err != nil {
+		return err
+	}
+	if err = writeRecords(w, c.format, header, records); err != nil {
+		_ = closeOutput()
+		return err
+	}
+	return closeOutput()
+}
+
+// importJSONArray inserts the objects of a JSON array into the table by converting them to NDJSON
+func importJSONArray(db tdb.Db, tableName string, r io.Reader) (int, error) {
+	var objects []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return 0, err
+	}
+	var buffer bytes.Buffer
+	for _, object := range objects {
+		if err := json.Compact(&buffer, object); err != nil {
+			return 0, err
+		}
+		buffer.WriteByte('\n')
+	}
+	return db.ImportNDJSON(tableName, &buffer, nil)
+}
+
+// insertRecord adds the record to the table in the order of its columns, the missing columns are null
+// The id is always generated
+func insertRecord(tb tdb.Table, record map[string]string) error {
+	columns := tableColumns(tb)
+	for column := range record {
+		if !slices.Contains(columns, column) {
+			return fmt.Errorf("column %q not found in table %s", column, tableName(tb))
+		}
+	}
+	values := make([]string, 0, len(columns)-1)
+	for _, column := range columns[1:] {
+		value, ok := record[column]
+		if !ok || value == "" {
+			value = "null"
+		}
+		values = append(values, value)
+	}
+	return tb.AddValues(values...)
+}
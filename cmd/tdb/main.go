@@ -0,0 +1,239 @@
+// Command tdb inspects and edits text-database files from the command line.
+//
+// Usage:
+//
+//	tdb <command> [flags] <database> [arguments]
+//
+// Run tdb help to list the commands.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// command is a subcommand of the CLI.
+type command struct {
+	usage         string
+	description   string
+	defaultFormat string
+	run           func(c *cli, args []string) error
+}
+
+// cli holds the streams and the parsed flags of a single run.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	key     string
+	keyEnv  string
+	columns string
+	format  string
+	output  string
+	to      string
+	plan    bool
+
+	keep         int
+	backupKey    string
+	backupKeyEnv string
+	repair       bool
+}
+
+// errUsage is returned when the arguments of a command are wrong.
+var errUsage = errors.New("invalid arguments")
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"tables":  {"tables <database>", "List the tables with their columns and rows", "table", runTables},
+		"schema":  {"schema <database> <table>", "Show the columns and foreign keys of a table", "table", runSchema},
+		"query":   {"query <database> <sql>", "Run an SQL statement", "table", runQuery},
+		"insert":  {"insert <database> <table> column=value...", "Insert a row", "table", runInsert},
+		"export":  {"export <database> [table]", "Write the rows of a table, or the whole database as JSON", "csv", runExport},
+		"import":  {"import <database> [table] <file>", "Insert the rows of a CSV, JSON or NDJSON file, or a whole database as JSON", "csv", runImport},
+		"encrypt": {"encrypt <database>", "Encrypt the database with the key", "table", runEncrypt},
+		"decrypt": {"decrypt <database>", "Remove the encryption of the database", "table", runDecrypt},
+		"migrate": {"migrate <database> <directory>", "Apply the SQL migrations of the directory", "table", runMigrate},
+		"dump":    {"dump <database>", "Write the database as an SQL script", "table", runDump},
+		"restore": {"restore <database> <file>", "Run an SQL script written by dump", "table", runRestore},
+		"backup":  {"backup <database> <destination>", "Copy the database to a file, or to a directory of rotating backups", "table", runBackup},
+		"upgrade": {"upgrade <database>", "Rewrite a database written by an older version in the current format", "table", runUpgrade},
+		"check":   {"check <database>", "Validate the structure, references and checksums of the database", "table", runCheck},
+		"shell":   {"shell <database>", "Open an interactive SQL shell", "table", runShell},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code: 0 on success, 1 on error and 2 on wrong usage
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		c.usage()
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "tdb: unknown command %q\n", args[0])
+		c.usage()
+		return 2
+	}
+	flags := c.flagSet(args[0], cmd)
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	err := cmd.run(c, flags.Args())
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(stderr, "usage: tdb %s\n", cmd.usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "tdb %s: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// flagSet returns the flags shared by every command
+func (c *cli) flagSet(name string, cmd command) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.StringVar(&c.key, "key", "", "encryption key, read from -key-env or prompted when empty")
+	flags.StringVar(&c.keyEnv, "key-env", "TDB_KEY", "environment variable that holds the encryption key")
+	flags.StringVar(&c.columns, "columns", "", "encrypted columns of the database as Table.column separated by commas, :det marks the deterministic ones")
+	flags.StringVar(&c.format, "format", cmd.defaultFormat, "output format: table, csv, json or ndjson")
+	flags.StringVar(&c.output, "o", "", "write the output to a file instead of the standard output")
+	if name == "migrate" {
+		flags.StringVar(&c.to, "to", "", "migrate or roll back to this migration ID, \"none\" rolls back every migration")
+		flags.BoolVar(&c.plan, "plan", false, "report what the migrations would do without writing the database")
+	}
+	if name == "check" {
+		flags.BoolVar(&c.repair, "repair", false, "rewrite the database without the problems found, quarantining the unreadable rows")
+	}
+	if name == "backup" {
+		flags.IntVar(&c.keep, "keep", 0, "write a timestamped backup in the destination directory and keep only this many")
+		flags.StringVar(&c.backupKey, "backup-key", "", "encrypt the backup with this key, read from -backup-key-env when empty")
+		flags.StringVar(&c.backupKeyEnv, "backup-key-env", "", "environment variable that holds the key of the backup")
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: tdb %s\n\n%s\n\nflags:\n", cmd.usage, cmd.description)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// usage prints the list of commands
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "usage: tdb <command> [flags] <database> [arguments]")
+	fmt.Fprintln(c.stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(c.stderr, "  %-45s %s\n", commands[name].usage, commands[name].description)
+	}
+	fmt.Fprintln(c.stderr, "\nRun tdb <command> -h to see the flags of a command.")
+}
+
+// open opens an existing database, the key is only used when the database is encrypted
+// so a key in the environment never encrypts a plain database by accident
+func (c *cli) open(path string, readOnly bool) (tdb.Db, error) {
+	opts, err := c.options(path, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	return tdb.Open(path, opts)
+}
+
+// options returns the options to open the database, with the key only if the database is encrypted
+// or has encrypted columns, which must be listed with -columns
+func (c *cli) options(path string, readOnly bool) (*tdb.Options, error) {
+	storage := tdb.NewFileStorage(path)
+	encrypted, err := tdb.IsEncrypted(storage)
+	if err != nil {
+		return nil, err
+	}
+	hasColumns, err := tdb.HasEncryptedColumns(storage)
+	if err != nil {
+		return nil, err
+	}
+	opts := &tdb.Options{ReadOnly: readOnly}
+	if hasColumns {
+		if opts.EncryptedColumns, err = c.encryptedColumns(); err != nil {
+			return nil, err
+		}
+	}
+	if encrypted || hasColumns {
+		if opts.EncryptionKey, err = c.encryptionKey(); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// encryptedColumns parses the -columns flag, a list of Table.column separated by commas
+// where a :det suffix marks a deterministic column
+func (c *cli) encryptedColumns() ([]tdb.EncryptedColumn, error) {
+	if strings.TrimSpace(c.columns) == "" {
+		return nil, errors.New("the database has encrypted columns, list them with -columns Table.column,...")
+	}
+	var columns []tdb.EncryptedColumn
+	for _, item := range strings.Split(c.columns, ",") {
+		item, deterministic := strings.CutSuffix(strings.TrimSpace(item), ":det")
+		tableName, columnName, ok := strings.Cut(item, ".")
+		if !ok || tableName == "" || columnName == "" {
+			return nil, fmt.Errorf("invalid encrypted column %q, expected Table.column", item)
+		}
+		columns = append(columns, tdb.EncryptedColumn{TableName: tableName, ColumnName: columnName, Deterministic: deterministic})
+	}
+	return columns, nil
+}
+
+// encryptionKey returns the key of the -key flag, the environment variable or the terminal, in that order
+func (c *cli) encryptionKey() (string, error) {
+	if c.key != "" {
+		return c.key, nil
+	}
+	if key := os.Getenv(c.keyEnv); c.keyEnv != "" && key != "" {
+		return key, nil
+	}
+	file, ok := c.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return "", fmt.Errorf("an encryption key is required, set it with -key or $%s", c.keyEnv)
+	}
+	fmt.Fprint(c.stderr, "Encryption key: ")
+	key, err := term.ReadPassword(int(file.Fd()))
+	fmt.Fprintln(c.stderr)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(string(key)) == "" {
+		return "", errors.New("the encryption key is empty")
+	}
+	return string(key), nil
+}
+
+// writer returns the output of the command and the function that closes it
+func (c *cli) writer() (io.Writer, func() error, error) {
+	if c.output == "" {
+		return c.stdout, func() error { return nil }, nil
+	}
+	file, err := os.Create(c.output)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type cliSuite struct {
+	suite.Suite
+	dir  string
+	path string
+}
+
+func (s *cliSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.path = filepath.Join(s.dir, "testDbCli.txt")
+	_, err := tdb.Create(s.path, &tdb.Options{Seed: []tdb.DataConfig{
+		{TableName: "Users", Columns: []string{"name", "age"}, Values: []tdb.Values{{"1", "pedro perez", "20"}, {"2", "juan", "30"}}},
+	}})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+}
+
+// run runs the command line and returns its exit code, output and errors
+func (s *cliSuite) run(args ...string) (int, string, string) {
+	return s.runWithInput("", args...)
+}
+
+// runWithInput runs the command line reading the input from stdin
+func (s *cliSuite) runWithInput(input string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(input), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func (s *cliSuite) TestTables() {
+	code, out, _ := s.run("tables", s.path)
+	if code != 0 || !strings.Contains(out, "Users") || !strings.Contains(out, "id, name, age") {
+		s.Fail("Expected the Users table", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+}
+
+func (s *cliSuite) TestQuery_Json() {
+	code, out, errOut := s.run("query", "-format", "json", s.path, "SELECT name , age FROM Users WHERE age = 30")
+	if code != 0 || out != "[\n  {\"name\": \"juan\", \"age\": \"30\"}\n]\n" {
+		s.Fail("Expected juan as json", fmt.Sprintf("Recibe: %d %q %s", code, out, errOut))
+	}
+}
+
+func (s *cliSuite) TestInsert_Export() {
+	if code, _, errOut := s.run("insert", s.path, "Users", "name=maria lopez", "age=41"); code != 0 {
+		s.Fail("Expected exit code 0", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	code, out, _ := s.run("export", s.path, "Users")
+	if code != 0 || !strings.HasPrefix(out, "id,name,age\n") || !strings.Contains(out, ",maria lopez,41\n") || !strings.Contains(out, ",pedro perez,20\n") {
+		s.Fail("Expected the rows as csv", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+}
+
+func (s *cliSuite) TestImport_CreateTable() {
+	file := filepath.Join(s.dir, "houses.csv")
+	_ = os.WriteFile(file, []byte("direction,owner\n\"juan avenue, 5\",pedro\nmain street,juan\n"), 0644)
+	if code, _, errOut := s.run("import", s.path, "Houses", file); code != 0 {
+		s.Fail("Expected exit code 0", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	code, out, _ := s.run("export", s.path, "Houses")
+	if code != 0 || !strings.Contains(out, ",\"juan avenue, 5\",pedro\n") || !strings.Contains(out, ",main street,juan\n") {
+		s.Fail("Expected the imported rows", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+}
+
+func (s *cliSuite) TestExport_ImportJson() {
+	code, out, _ := s.run("export", "-format", "ndjson", s.path, "Users")
+	if code != 0 || out != "{\"id\":\"1\",\"name\":\"pedro perez\",\"age\":\"20\"}\n{\"id\":\"2\",\"name\":\"juan\",\"age\":\"30\"}\n" {
+		s.Fail("Expected the rows as ndjson", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+	if code, _, errOut := s.run("export", s.path); code != 1 || !strings.Contains(errOut, "-format json") {
+		s.Fail("Expected the whole database to require json", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	document := filepath.Join(s.dir, "database.json")
+	if code, _, errOut := s.run("export", "-format", "json", "-o", document, s.path); code != 0 {
+		s.Fail("Expected exit code 0", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	copyPath := filepath.Join(s.dir, "testDbCliCopy.txt")
+	_, _ = tdb.Create(copyPath, nil)
+	if code, out, errOut := s.run("import", "-format", "json", copyPath, document); code != 0 || out != "database imported\n" {
+		s.Fail("Expected the database to be imported", fmt.Sprintf("Recibe: %d %s %s", code, out, errOut))
+	}
+	if code, out, _ = s.run("export", copyPath, "Users"); code != 0 || out != "id,name,age\n1,pedro perez,20\n2,juan,30\n" {
+		s.Fail("Expected the imported rows", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+}
+
+func (s *cliSuite) TestDump_Restore() {
+	code, script, _ := s.run("dump", s.path)
+	if code != 0 || !strings.Contains(script, "INSERT INTO \"Users\" (\"id\", \"name\", \"age\") VALUES ('1', 'pedro perez', '20');") {
+		s.Fail("Expected the SQL script", fmt.Sprintf("Recibe: %d %s", code, script))
+	}
+	copyPath := filepath.Join(s.dir, "testDbCliRestore.txt")
+	_, _ = tdb.Create(copyPath, nil)
+	if code, out, errOut := s.runWithInput(script, "restore", copyPath, "-"); code != 0 || out != "database restored\n" {
+		s.Fail("Expected the database to be restored", fmt.Sprintf("Recibe: %d %s %s", code, out, errOut))
+	}
+	if code, out, _ := s.run("export", copyPath, "Users"); code != 0 || out != "id,name,age\n1,pedro perez,20\n2,juan,30\n" {
+		s.Fail("Expected the restored rows", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+}
+
+func (s *cliSuite) TestBackup() {
+	backupPath := filepath.Join(s.dir, "backup.txt")
+	if code, out, errOut := s.run("backup", s.path, backupPath); code != 0 || out != "backup written to "+backupPath+"\n" {
+		s.Fail("Expected the backup to be written", fmt.Sprintf("Recibe: %d %s %s", code, out, errOut))
+	}
+	if code, out, _ := s.run("export", backupPath, "Users"); code != 0 || out != "id,name,age\n1,pedro perez,20\n2,juan,30\n" {
+		s.Fail("Expected the rows of the backup", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+	backupDir := filepath.Join(s.dir, "backups")
+	for range 3 {
+		if code, _, errOut := s.run("backup", "-keep", "2", s.path, backupDir); code != 0 {
+			s.Fail("Expected exit code 0", fmt.Sprintf("Recibe: %d %s", code, errOut))
+		}
+	}
+	if entries, _ := os.ReadDir(backupDir); len(entries) != 2 {
+		s.Fail("Expected 2 backups", fmt.Sprintf("Recibe: %d", len(entries)))
+	}
+}
+
+func (s *cliSuite) TestEncrypt_Decrypt() {
+	if code, _, errOut := s.run("encrypt", "-key", "secret", s.path); code != 0 {
+		s.Fail("Expected exit code 0", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	if encrypted, _ := tdb.IsEncrypted(tdb.NewFileStorage(s.path)); !encrypted {
+		s.Fail("Expected the database to be encrypted")
+	}
+	if code, _, _ := s.run("tables", "-key-env", "TDB_TEST_MISSING_KEY", s.path); code != 1 {
+		s.Fail("Expected exit code 1 without key", fmt.Sprintf("Recibe: %d", code))
+	}
+	if code, _, errOut := s.run("tables", "-key", "wrong", s.path); code != 1 || !strings.Contains(errOut, "invalid encryption key") {
+		s.Fail("Expected exit code 1 with a wrong key", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	s.T().Setenv("TDB_KEY", "secret")
+	if code, out, _ := s.run("tables", s.path); code != 0 || !strings.Contains(out, "Users") {
+		s.Fail("Expected the key from the environment", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+	if code, _, errOut := s.run("decrypt", s.path); code != 0 {
+		s.Fail("Expected exit code 0", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	if encrypted, _ := tdb.IsEncrypted(tdb.NewFileStorage(s.path)); encrypted {
+		s.Fail("Expected the database to be decrypted")
+	}
+}
+
+func (s *cliSuite) TestEncryptedColumns() {
+	path := filepath.Join(s.dir, "testDbCliColumns.txt")
+	_, err := tdb.Create(path, &tdb.Options{
+		EncryptionKey:    "secret",
+		KeyDerivation:    &tdb.KeyDerivation{N: 1024, R: 8, P: 1},
+		EncryptedColumns: []tdb.EncryptedColumn{{TableName: "Users", ColumnName: "ssn"}},
+		Seed:             []tdb.DataConfig{{TableName: "Users", Columns: []string{"name", "ssn"}, Values: []tdb.Values{{"1", "pedro", "111"}}}},
+	})
+	if err != nil {
+		s.FailNow("Expected nil", fmt.Sprintf("Recibe: %s", err))
+	}
+	if code, _, errOut := s.run("tables", "-key", "secret", path); code != 1 || !strings.Contains(errOut, "-columns") {
+		s.Fail("Expected exit code 1 without the columns", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	if code, _, errOut := s.run("encrypt", "-key", "secret", path); code != 1 || !strings.Contains(errOut, "encrypted columns") {
+		s.Fail("Expected encrypt to refuse the database", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	if code, _, errOut := s.run("insert", "-key", "secret", "-columns", "Users.ssn", path, "Users", "name=juan", "ssn=222"); code != 0 {
+		s.Fail("Expected exit code 0", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	code, out, errOut := s.run("query", "-key", "secret", "-columns", "Users.ssn", "-format", "csv", path, "SELECT name , ssn FROM Users")
+	if code != 0 || out != "name,ssn\npedro,111\njuan,222\n" {
+		s.Fail("Expected the decrypted values", fmt.Sprintf("Recibe: %d %q %s", code, out, errOut))
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "222") || strings.Count(string(data), "ENC:") != 2 {
+		s.Fail("Expected the inserted value to be encrypted", fmt.Sprintf("Recibe: %s", data))
+	}
+}
+
+func (s *cliSuite) TestMigrate() {
+	migrations := filepath.Join(s.dir, "migrations")
+	_ = os.Mkdir(migrations, 0755)
+	_ = os.WriteFile(filepath.Join(migrations, "0001_add_email.up.sql"), []byte("ALTER TABLE Users ADD COLUMN email;"), 0644)
+	_ = os.WriteFile(filepath.Join(migrations, "0001_add_email.down.sql"), []byte("ALTER TABLE Users DROP COLUMN email;"), 0644)
+	code, out, _ := s.run("migrate", "-plan", s.path, migrations)
+	if code != 0 || !strings.Contains(out, "[up] 0001_add_email") || !strings.Contains(out, "(2 rows)") {
+		s.Fail("Expected the plan of 0001_add_email", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+	if code, out, _ = s.run("migrate", s.path, migrations); code != 0 || !strings.Contains(out, "0001_add_email") {
+		s.Fail("Expected the migration to be applied", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+	if code, out, _ = s.run("migrate", "-to", "none", s.path, migrations); code != 0 || out != "database at version \"\"\n" {
+		s.Fail("Expected the migration to be rolled back", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+}
+
+func (s *cliSuite) TestUpgrade() {
+	data, _ := os.ReadFile(s.path)
+	_ = os.WriteFile(s.path, []byte(strings.TrimPrefix(string(data), "FMT 1\n")), 0644)
+	if code, out, errOut := s.run("upgrade", s.path); code != 0 || out != "database upgraded from format version 0 to 1\n" {
+		s.Fail("Expected the database to be upgraded", fmt.Sprintf("Recibe: %d %s %s", code, out, errOut))
+	}
+	if data, _ = os.ReadFile(s.path); !strings.HasPrefix(string(data), "FMT 1\n") {
+		s.Fail("Expected the format header", fmt.Sprintf("Recibe: %s", data))
+	}
+}
+
+func (s *cliSuite) TestCheck() {
+	if code, out, _ := s.run("check", s.path); code != 0 || out != "ok\n" {
+		s.Fail("Expected ok", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+}
+
+func (s *cliSuite) TestCheck_Repair() {
+	data, _ := os.ReadFile(s.path)
+	_ = os.WriteFile(s.path, []byte(strings.Replace(string(data), " |3| 30\n", "\n", 1)), 0644)
+	code, out, errOut := s.run("check", "-format", "csv", s.path)
+	if code != 1 || out != "problem,table,line,message,repaired\nfield count,Users,6,\"row has 2 fields, expected 3\",false\n" {
+		s.Fail("Expected the problem", fmt.Sprintf("Recibe: %d %s %s", code, out, errOut))
+	}
+	if code, _, errOut = s.run("check", "-repair", s.path); code != 0 || errOut != "1 rows quarantined in __tdb_quarantine\n" {
+		s.Fail("Expected the database to be repaired", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+	if code, out, _ = s.run("check", s.path); code != 0 || out != "ok\n" {
+		s.Fail("Expected ok", fmt.Sprintf("Recibe: %d %s", code, out))
+	}
+}
+
+func (s *cliSuite) TestShell() {
+	input := "SELECT name , age\nFROM Users\nWHERE age = 30;\n.mode csv\nSELECT name , age FROM Users WHERE age = 20;\n.tables\n.schema Users\nDELETE FROM Users WHERE age = 30;\n.quit\nSELECT name FROM Users;\n"
+	code, out, errOut := s.runWithInput(input, "shell", s.path)
+	expected := "name  age\n----  ---\njuan  30\n" +
+		"name,age\npedro perez,20\n" +
+		"Users\n" +
+		"Users\nposition  column  references  referenced_by\n--------  ------  ----------  -------------\n1         id                  \n2         name                \n3         age                 \n" +
+		"1 rows affected\n"
+	if code != 0 || out != expected || errOut != "" {
+		s.Fail("Expected the output of the statements", fmt.Sprintf("Recibe: %d %q %s", code, out, errOut))
+	}
+}
+
+func (s *cliSuite) TestShell_QuotedValues() {
+	input := "INSERT INTO Users (id, name, age) VALUES ('3', 'a;  b', '40');\nINSERT INTO Users (id, name, age) VALUES ('4', 'c;\n', '50');\nSELECT name FROM Users WHERE name = 'a;  b';\n"
+	code, out, errOut := s.runWithInput(input, "shell", "-format", "csv", s.path)
+	if code != 0 || out != "1 rows affected\n1 rows affected\nname\na;  b\n" || errOut != "" {
+		s.Fail("Expected the quoted values to be kept", fmt.Sprintf("Recibe: %d %q %s", code, out, errOut))
+	}
+}
+
+func (s *cliSuite) TestShell_ErrorsDontEndTheSession() {
+	input := "SELECT name FROM Nope;\n.bogus\n.timer on\nSELECT name , age FROM Users WHERE age = 20\n"
+	code, out, errOut := s.runWithInput(input, "shell", s.path)
+	if code != 0 || strings.Count(errOut, "Error: ") != 2 || !strings.Contains(out, "pedro perez  20\nRun Time: ") {
+		s.Fail("Expected the errors and the last statement", fmt.Sprintf("Recibe: %d %q %s", code, out, errOut))
+	}
+}
+
+func (s *cliSuite) TestUsage() {
+	if code, _, _ := s.run("unknown"); code != 2 {
+		s.Fail("Expected exit code 2", fmt.Sprintf("Recibe: %d", code))
+	}
+	if code, _, errOut := s.run("schema", s.path); code != 2 || !strings.Contains(errOut, "usage: tdb schema") {
+		s.Fail("Expected the usage of schema", fmt.Sprintf("Recibe: %d %s", code, errOut))
+	}
+}
+
+func TestCli(t *testing.T) {
+	suite.Run(t, new(cliSuite))
+}
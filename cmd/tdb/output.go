@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// writeRecords writes the records under the header in the format: table, csv, json or ndjson
+func writeRecords(w io.Writer, format string, header []string, records [][]string) error {
+	switch format {
+	case "table":
+		return writeTable(w, header, records)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(records); err != nil {
+			return err
+		}
+		return writer.Error()
+	case "json":
+		return writeJSON(w, header, records)
+	case "ndjson":
+		var buffer bytes.Buffer
+		for _, record := range records {
+			writeObject(&buffer, header, record, ",")
+			buffer.WriteString("\n")
+		}
+		_, err := w.Write(buffer.Bytes())
+		return err
+	default:
+		return fmt.Errorf("unknown format %q, use table, csv, json or ndjson", format)
+	}
+}
+
+// writeTable writes the records as aligned columns
+func writeTable(w io.Writer, header []string, records [][]string) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	separator := make([]string, len(header))
+	for i, h := range header {
+		separator[i] = strings.Repeat("-", len(h))
+	}
+	fmt.Fprintln(writer, strings.Join(header, "\t"))
+	fmt.Fprintln(writer, strings.Join(separator, "\t"))
+	for _, record := range records {
+		fmt.Fprintln(writer, strings.Join(record, "\t"))
+	}
+	return writer.Flush()
+}
+
+// writeJSON writes the records as an array of objects keyed by the header, in the header order
+func writeJSON(w io.Writer, header []string, records [][]string) error {
+	var buffer bytes.Buffer
+	buffer.WriteString("[")
+	for i, record := range records {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString("\n  ")
+		writeObject(&buffer, header, record, ", ")
+	}
+	if len(records) > 0 {
+		buffer.WriteString("\n")
+	}
+	buffer.WriteString("]\n")
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+// writeObject writes the record as an object keyed by the header, in the header order
+func writeObject(buffer *bytes.Buffer, header []string, record []string, separator string) {
+	buffer.WriteString("{")
+	for i, value := range record {
+		if i > 0 {
+			buffer.WriteString(separator)
+		}
+		key, _ := json.Marshal(header[i])
+		encoded, _ := json.Marshal(value)
+		buffer.Write(key)
+		buffer.WriteString(": ")
+		buffer.Write(encoded)
+	}
+	buffer.WriteString("}")
+}
+
+// rowRecords returns the values of the rows by column, the columns are used when there is no row
+func rowRecords(rows tdb.Rows, columns []string) ([]string, [][]string) {
+	if len(rows) > 0 {
+		columns = rows[0].Columns()
+	}
+	records := make([][]string, len(rows))
+	for i, row := range rows {
+		records[i] = make([]string, len(columns))
+		for j, column := range columns {
+			records[i][j] = row.SearchValue(column)
+		}
+	}
+	return columns, records
+}
+
+// tableColumns returns the column names of the table without the positions
+func tableColumns(tb tdb.Table) []string {
+	var columns []string
+	header := tb.GetColumns()
+	for i := 1; i < len(header); i += 2 {
+		columns = append(columns, strings.TrimSpace(header[i]))
+	}
+	return columns
+}
+
+// tableName returns the name of the table without the markers
+func tableName(tb tdb.Table) string {
+	return strings.Trim(tb.GetName(), "-")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	shellPrompt         = "tdb> "
+	shellContinuePrompt = "...> "
+)
+
+// shell is an interactive session of SQL statements and dot-commands on a database.
+type shell struct {
+	db     tdb.Db
+	out    io.Writer
+	errOut io.Writer
+	mode   string
+	timer  bool
+	// readLine returns the next line of input, io.EOF ends the session
+	readLine func() (string, error)
+	// setPrompt changes the prompt, it does nothing when the input is not a terminal
+	setPrompt func(prompt string)
+}
+
+// errQuit is returned by the dot-commands that end the session.
+var errQuit = errors.New("quit")
+
+// runShell reads statements terminated by ';' and dot-commands until the end of the input
+func runShell(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	s := &shell{db: db, out: c.stdout, errOut: c.stderr, mode: c.format, setPrompt: func(string) {}}
+	file, ok := c.stdin.(*os.File)
+	if ok && term.IsTerminal(int(file.Fd())) {
+		state, err := term.MakeRaw(int(file.Fd()))
+		if err != nil {
+			return err
+		}
+		defer term.Restore(int(file.Fd()), state)
+		terminal := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{file, c.stdout}, shellPrompt)
+		if width, height, err := term.GetSize(int(file.Fd())); err == nil {
+			_ = terminal.SetSize(width, height)
+		}
+		s.out, s.errOut = terminal, terminal
+		s.readLine, s.setPrompt = terminal.ReadLine, terminal.SetPrompt
+		fmt.Fprintln(terminal, "Enter SQL statements terminated with a \";\" or .help for the dot-commands")
+	} else {
+		scanner := bufio.NewScanner(c.stdin)
+		s.readLine = func() (string, error) {
+			if !scanner.Scan() {
+				if scanner.Err() != nil {
+					return "", scanner.Err()
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+	return s.run()
+}
+
+// run executes the input until the end or a .quit, the errors of the statements are printed and don't end the session
+func (s *shell) run() error {
+	var statement strings.Builder
+	for {
+		line, err := s.readLine()
+		if errors.Is(err, io.EOF) {
+			if strings.TrimSpace(statement.String()) != "" {
+				s.execute(statement.String())
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		trimmed := strings.TrimSpace(line)
+		if statement.Len() == 0 && strings.HasPrefix(trimmed, ".") {
+			if err = s.dotCommand(trimmed); errors.Is(err, errQuit) {
+				return nil
+			} else if err != nil {
+				fmt.Fprintf(s.errOut, "Error: %s\n", err)
+			}
+			continue
+		}
+		if statement.Len() == 0 && trimmed == "" {
+			continue
+		}
+		statement.WriteString(line)
+		statement.WriteString("\n")
+		if !strings.HasSuffix(trimmed, ";") || openQuote(statement.String()) {
+			s.setPrompt(shellContinuePrompt)
+			continue
+		}
+		s.execute(statement.String())
+		statement.Reset()
+		s.setPrompt(shellPrompt)
+	}
+}
+
+// openQuote reports whether the input ends inside a quoted string, where a ';' doesn't end the statement
+func openQuote(input string) bool {
+	var quote byte
+	for i := 0; i < len(input); i++ {
+		switch c := input[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '-' && strings.HasPrefix(input[i:], "--"):
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		}
+	}
+	return quote != 0
+}
+
+// execute runs the SQL statements, separated by ';', and writes their rows or the number of affected rows
+// The statements are split with tdb.SplitSqlStatements, so quoted values keep their ';' and spaces
+func (s *shell) execute(input string) {
+	for _, sql := range tdb.SplitSqlStatements(input) {
+		start := time.Now()
+		result, err := s.db.FromSql(sql)
+		elapsed := time.Since(start)
+		if err != nil {
+			fmt.Fprintf(s.errOut, "Error: %s\n", err)
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(sql), "SELECT") {
+			header, records := rowRecords(result.Rows, nil)
+			if err = writeRecords(s.out, s.mode, header, records); err != nil {
+				fmt.Fprintf(s.errOut, "Error: %s\n", err)
+			}
+		} else {
+			fmt.Fprintf(s.out, "%d rows affected\n", result.AffectRows)
+		}
+		if s.timer {
+			fmt.Fprintf(s.out, "Run Time: %s\n", elapsed)
+		}
+	}
+}
+
+// dotCommand runs a command of the shell: .tables, .schema, .mode, .timer, .help and .quit
+func (s *shell) dotCommand(line string) error {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ".tables":
+		for _, tb := range s.db.GetTables() {
+			fmt.Fprintln(s.out, tableName(tb))
+		}
+	case ".schema":
+		names := fields[1:]
+		if len(names) == 0 {
+			for _, tb := range s.db.GetTables() {
+				names = append(names, tableName(tb))
+			}
+		}
+		for i, name := range names {
+			header, records, err := schemaRecords(s.db, name)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Fprintln(s.out)
+			}
+			fmt.Fprintf(s.out, "%s\n", name)
+			if err = writeTable(s.out, header, records); err != nil {
+				return err
+			}
+		}
+	case ".mode":
+		if len(fields) != 2 || !slices.Contains([]string{"table", "csv", "json", "ndjson"}, fields[1]) {
+			return errors.New("usage: .mode table|csv|json|ndjson")
+		}
+		s.mode = fields[1]
+	case ".timer":
+		if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+			return errors.New("usage: .timer on|off")
+		}
+		s.timer = fields[1] == "on"
+	case ".help":
+		fmt.Fprint(s.out, `.tables                      List the tables
+.schema [table...]           Show the columns and foreign keys of the tables
+.mode table|csv|json|ndjson  Set the output format of the rows
+.timer on|off                Show the time each statement takes
+.help                        Show this message
+.quit                        Exit the shell
+`)
+	case ".quit", ".exit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %s, use .help to list the commands", fields[0])
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package tdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BackupOptions configures the copies written by Snapshot, Backup and RotatingBackup
+type BackupOptions struct {
+	EncryptionKey string         // Optional key to re-encrypt the copy with, the copy is stored as the database is when empty
+	KeyDerivation *KeyDerivation // Optional scrypt work factor of the re-encrypted copy
+}
+
+// backupTimeFormat is the UTC timestamp in the names of the rotating backups, it sorts chronologically
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// Snapshot writes a consistent point-in-time copy of the database. The content is read at once under the lock of the
+// storage, so a concurrent write is never half copied, and during a transaction the copy is the database as it was
+// before the transaction started. The copy can be opened like the database, with the same key unless
+// BackupOptions.EncryptionKey re-encrypts it with another one.
+//
+// Example:
+//
+//	var buffer bytes.Buffer
+//	err := db.Snapshot(&buffer, nil)
+func (d *db) Snapshot(w io.Writer, opts *BackupOptions) error {
+	data, err := backupData(opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Backup writes a consistent point-in-time copy of the database, see Snapshot, to the file dst.
+// The file is written to a temporary file first and renamed, so dst is never left half written.
+// The backup is only readable by its owner, even when it replaces a file with other permissions.
+//
+// Example:
+//
+//	err := db.Backup("backups/mydb.txt", &tdb.BackupOptions{EncryptionKey: "backup-secret"})
+func (d *db) Backup(dst string, opts *BackupOptions) error {
+	data, err := backupData(opts)
+	if err != nil {
+		return err
+	}
+	return (&FileStorage{path: dst, perm: 0600, fixedPerm: true}).Write(data)
+}
+
+// RotatingBackup writes a backup in the directory dir named after the database and the current UTC time,
+// such as mydb-20250718T101500.000000000Z.txt, then deletes the oldest backups of the database in dir
+// so only the latest retention remain. Returns the path of the new backup.
+//
+// Example:
+//
+//	// keep the backups of the last 7 days
+//	path, err := db.RotatingBackup("backups", 7, nil)
+func (d *db) RotatingBackup(dir string, retention int, opts *BackupOptions) (string, error) {
+	if retention < 1 {
+		return "", errors.New("backup retention must be at least 1")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	base := filepath.Base(d.GetName())
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	path := filepath.Join(dir, prefix+time.Now().UTC().Format(backupTimeFormat)+ext)
+	if err := d.Backup(path, opts); err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return path, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		if _, err = time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	slices.Sort(backups)
+	for len(backups) > retention {
+		if err = os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return path, err
+		}
+		backups = backups[1:]
+	}
+	return path, nil
+}
+
+// backupData returns the content of the database for a backup, re-encrypted when the options have a key
+func backupData(opts *BackupOptions) ([]byte, error) {
+	data, err := snapshotData()
+	if err != nil || opts == nil || opts.EncryptionKey == "" {
+		return data, err
+	}
+	if strings.TrimSpace(opts.EncryptionKey) == "" {
+		return nil, errors.New("backup encryption key is required")
+	}
+	if err = validateKeyDerivation(opts.KeyDerivation); err != nil {
+		return nil, err
+	}
+	plainText := string(data)
+	if isEncode(plainText) {
+		if !encryptionKeyExist {
+			return nil, ErrInvalidKey
+		}
+		if plainText, err = globalEncoderKey.Decode(plainText); err != nil {
+			return nil, ErrInvalidKey
+		}
+	}
+	_, body := splitFileHeader(plainText)
+	if globalColumnCipher != nil {
+		// databases with encrypted columns are not encrypted as a whole, only the columns are encrypted again
+		cipher, err := newColumnCipher(opts.EncryptionKey, opts.KeyDerivation, globalColumnCipher.columns, "")
+		if err != nil {
+			return nil, err
+		}
+		if body, err = decryptColumns(body); err != nil {
+			return nil, err
+		}
+		if body, err = cipher.encryptCells(body); err != nil {
+			return nil, err
+		}
+		return []byte(buildFileHeader(body, cipher, deriveChecksumKey(cipher.key)) + body), nil
+	}
+	encoder := newSecureTextEncoder(opts.EncryptionKey, opts.KeyDerivation)
+	key, err := encoder.encodeKey()
+	if err != nil {
+		return nil, fmt.Errorf("backup encryption failed: %w", err)
+	}
+	encoded, err := encoder.Encode(buildFileHeader(body, nil, deriveChecksumKey(key)) + body)
+	if err != nil {
+		return nil, fmt.Errorf("backup encryption failed: %w", err)
+	}
+	return []byte(encoded), nil
+}
+
+// snapshotData reads the content of the database as it is stored, under the lock of the storage.
+// During a transaction it returns the content from before the transaction started
+func snapshotData() ([]byte, error) {
+	if activeTransaction != nil {
+		return slices.Clone(activeTransaction.snapshot), nil
+	}
+	unlock := lockDatabase()
+	defer unlock()
+	return dbStorage.Read()
+}
@@ -0,0 +1,105 @@
+package tdb
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	systemTablePrefix = "__tdb_"
+	metaTableName     = systemTablePrefix + "meta"
+)
+
+// isSystemTable reports whether the table name is reserved for the system catalog
+func isSystemTable(tableName string) bool {
+	return strings.HasPrefix(tableName, systemTablePrefix)
+}
+
+// userTables returns the tables without the system tables
+func userTables(tables []table) []table {
+	return slices.DeleteFunc(tables, func(t table) bool { return isSystemTable(t.getSimpleName()) })
+}
+
+// setSystemTable replaces the content of the system table with the rows, the id of each row is its position
+// The table is added first if it doesn't exist, and removed if there are no rows
+// Returns the updated tables
+func setSystemTable(tables []table, name string, columns []string, rows [][]string) []table {
+	nameRaw := fmt.Sprintf("-----%s-----", name)
+	index := slices.IndexFunc(tables, func(t table) bool { return t.nameRaw == nameRaw })
+	if len(rows) == 0 {
+		if index != -1 {
+			tables = slices.Delete(tables, index, index+1)
+		}
+		return tables
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("\n%s\n%s\n", nameRaw, columnsBuilder(append([]string{"id"}, columns...))))
+	for i, row := range rows {
+		builder.WriteString(fmt.Sprintf("|1| %d", i+1))
+		for j, value := range row {
+			builder.WriteString(fmt.Sprintf(" |%d| %s", j+2, encodeSystemValue(value)))
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString(fmt.Sprintf("!*!\n-----%s_End-----\n", name))
+	t := table{nameRaw: nameRaw, rawTable: builder.String()}
+	if index == -1 {
+		return append([]table{t}, tables...)
+	}
+	tables[index] = t
+	return tables
+}
+
+// encodeSystemValue encodes a value stored in a system table, an empty value is stored as null
+func encodeSystemValue(value string) string {
+	if value == "" {
+		return "null"
+	}
+	return strings.ReplaceAll(value, " ", "U+0020")
+}
+
+// decodeSystemValue decodes a value stored in a system table, null and missing values are empty
+func decodeSystemValue(value string) string {
+	if value == "null" {
+		return ""
+	}
+	return strings.ReplaceAll(value, "U+0020", " ")
+}
+
+// SystemTables returns the names of the system tables of the database
+//
+// Example:
+//
+//	for _, name := range db.SystemTables() {
+//		fmt.Println(name)
+//	}
+func (d *db) SystemTables() []string {
+	var names []string
+	for _, t := range getTables(false) {
+		if isSystemTable(t.getSimpleName()) {
+			names = append(names, t.getSimpleName())
+		}
+	}
+	return names
+}
+
+// SystemTableRows returns a read-only copy of the rows of a system table
+// Returns a NotFoundError if the system table doesn't exist
+//
+// Example:
+//
+//	rows, err := db.SystemTableRows("__tdb_meta")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) SystemTableRows(name string) (Rows, error) {
+	if !isSystemTable(name) {
+		return nil, &NotFoundError{itemName: "System table: " + name}
+	}
+	tb, err := getTableByName(name, true)
+	if err != nil {
+		return nil, &NotFoundError{itemName: "System table: " + name}
+	}
+	return tb.GetRows(), nil
+}
@@ -0,0 +1,560 @@
+package tdb
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CheckProblem is the kind of damage found by Check.
+type CheckProblem string
+
+const (
+	ProblemVersion     CheckProblem = "version"      // The format header is invalid or newer than this version of the library
+	ProblemEncryption  CheckProblem = "encryption"   // The database or a cell can't be decrypted, or the file starts with unknown content
+	ProblemChecksum    CheckProblem = "checksum"     // A stored checksum does not match the content
+	ProblemMarker      CheckProblem = "marker"       // A table start, end or insertion marker is missing or misplaced
+	ProblemHeader      CheckProblem = "header"       // The column header is missing or its numbering does not match the rows
+	ProblemFieldCount  CheckProblem = "field count"  // A row does not have one field for every column
+	ProblemDuplicateId CheckProblem = "duplicate id" // Two rows of a table have the same id
+	ProblemForeignKey  CheckProblem = "foreign key"  // A value references a row or a table that does not exist
+)
+
+// quarantineTableName is the system table where Repair moves the rows it can't read
+const quarantineTableName = systemTablePrefix + "quarantine"
+
+var quarantineTableColumns = []string{"id", "table", "line", "problem", "content"}
+
+var tableStartRegex = regexp.MustCompile(`^-----(.+)-----$`)
+var tableEndRegex = regexp.MustCompile(`^-----(.+)_End-----$`)
+var headerMarkerRegex = regexp.MustCompile(`^\[(\d+)\]$`)
+
+// CheckIssue is a problem found in the database by Check or Repair.
+type CheckIssue struct {
+	Problem  CheckProblem // Kind of damage
+	Table    string       // Name of the damaged table, empty when the damage is not in a table
+	Line     int          // Line of the decrypted file, 0 when the damage is not in a line
+	Message  string       // Description of the damage
+	Repaired bool         // Repair fixed the damage, moving the row to the quarantine table if it was unreadable
+
+	repairable bool
+}
+
+// CheckReport is the result of Check and Repair.
+type CheckReport struct {
+	Tables      int          // Number of readable tables, without the system tables
+	Rows        int          // Number of readable rows of those tables
+	Issues      []CheckIssue // Problems found, in the order of the file
+	Quarantined int          // Number of lines moved to the quarantine table by Repair
+}
+
+// OK reports whether the database has no problem left, every issue was repaired or none was found.
+func (r CheckReport) OK() bool {
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+// checkedTable is a table read by the checker, with the rows it could read.
+type checkedTable struct {
+	name    string
+	numbers []int
+	columns []string
+	rows    []checkedRow
+}
+
+// checkedRow is a readable row, its values with the encrypted cells decrypted.
+type checkedRow struct {
+	line  int
+	plain []string
+}
+
+// checker validates the database content and keeps what is needed to repair it.
+type checker struct {
+	report     CheckReport
+	lineOffset int
+	tables     []*checkedTable
+	quarantine [][]string
+}
+
+// Check validates the stored database: the encryption, the checksums, the markers of every table, the numbering of
+// the column headers, the number of fields of every row, duplicate ids and foreign keys referencing missing rows.
+// Unlike Open, which stops at the first damaged table, every problem is reported.
+// Returns an error only if the database can't be read
+//
+// Example:
+//
+//	report, err := db.Check()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, issue := range report.Issues {
+//		fmt.Println(issue.Table, issue.Line, issue.Message)
+//	}
+func (d *db) Check() (CheckReport, error) {
+	return checkDatabase(false)
+}
+
+// Repair checks the database like Check and rewrites it without the damage it found. Missing markers are added back,
+// the column headers are numbered again and the checksums are computed again. The rows that can't be read, the
+// rows whose id is already used and the rows with cells that can't be decrypted are moved to the __tdb_quarantine
+// system table, with the table, the line and the problem, so no data is lost. Foreign keys referencing missing rows
+// are only reported.
+// Returns ErrReadOnly if the database is read-only
+//
+// Example:
+//
+//	report, err := db.Repair()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(report.Quarantined, "rows quarantined")
+func (d *db) Repair() (CheckReport, error) {
+	if readOnly {
+		return CheckReport{}, ErrReadOnly
+	}
+	return checkDatabase(true)
+}
+
+// Check validates a database that may be too damaged to be opened, see Db.Check. The database is never written.
+// Returns a NotFoundError if the database does not exist
+//
+// Example:
+//
+//	report, err := tdb.Check("mydb.txt", &tdb.Options{EncryptionKey: "secret"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(report.OK())
+func Check(path string, opts *Options) (CheckReport, error) {
+	if err := initCheck(path, opts, true); err != nil {
+		return CheckReport{}, err
+	}
+	return checkDatabase(false)
+}
+
+// Repair repairs a database that may be too damaged to be opened, see Db.Repair. Open it afterwards to use it.
+// Returns a NotFoundError if the database does not exist and ErrReadOnly if it can't be written
+//
+// Example:
+//
+//	report, err := tdb.Repair("mydb.txt", nil)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	db, err := tdb.Open("mydb.txt", nil)
+func Repair(path string, opts *Options) (CheckReport, error) {
+	if opts != nil && opts.ReadOnly {
+		return CheckReport{}, ErrReadOnly
+	}
+	if err := initCheck(path, opts, false); err != nil {
+		return CheckReport{}, err
+	}
+	if readOnly {
+		return CheckReport{}, ErrReadOnly
+	}
+	return checkDatabase(true)
+}
+
+// initCheck sets the database of the path as the current database without validating its content
+// The key is only used when the database is encrypted, so repairing a plain database never encrypts it
+func initCheck(path string, opts *Options, checkOnly bool) error {
+	c := opts.toConfig(path)
+	c.DataConfig = nil
+	c.ReadOnly = c.ReadOnly || checkOnly
+	if err := c.validate(); err != nil {
+		return err
+	}
+	if !c.getStorage().Exists() {
+		return &NotFoundError{itemName: "Database"}
+	}
+	if err := c.initDatabase(); err != nil {
+		return err
+	}
+	if encryptionKeyExist && !isEncode(string(readDatabase())) {
+		encryptionKeyExist = false
+	}
+	return nil
+}
+
+// checkDatabase validates the stored database and, if repair is set, rewrites it without the damage found
+func checkDatabase(repair bool) (CheckReport, error) {
+	c := &checker{}
+	data := string(readDatabase())
+	encrypted := isEncode(data)
+	if encrypted {
+		if !encryptionKeyExist {
+			c.issue(ProblemEncryption, "", 0, "database is encrypted, the encryption key is required", false)
+			return c.report, nil
+		}
+		decoded, err := globalEncoderKey.Decode(data)
+		if err != nil {
+			c.issue(ProblemEncryption, "", 0, "the encryption key does not decrypt the database or the encrypted content is damaged", false)
+			return c.report, nil
+		}
+		data = decoded
+	}
+	data = strings.ReplaceAll(data, "\r", "")
+	header, body := splitFileHeader(data)
+	c.lineOffset = strings.Count(header, "\n")
+	if err := checkFormatVersion(header); errors.Is(err, ErrUnsupportedFormat) {
+		c.issue(ProblemVersion, "", 0, err.Error(), false)
+		return c.report, nil
+	} else if err != nil {
+		c.issue(ProblemVersion, "", 0, err.Error(), true)
+	}
+	if err := verifyChecksums(header, body, encrypted); err != nil {
+		var table string
+		if corruption := (*CorruptionError)(nil); errors.As(err, &corruption) {
+			table = corruption.TableName()
+		}
+		c.issue(ProblemChecksum, table, 0, err.Error(), true)
+	}
+	c.checkTables(body)
+	c.checkForeignKeys()
+	if !repair || !slices.ContainsFunc(c.report.Issues, func(issue CheckIssue) bool { return issue.repairable }) {
+		return c.report, nil
+	}
+	saveDatabase(c.build())
+	for i := range c.report.Issues {
+		c.report.Issues[i].Repaired = c.report.Issues[i].repairable
+	}
+	c.report.Quarantined = len(c.quarantine)
+	return c.report, nil
+}
+
+// issue adds a problem to the report
+// repairable: Repair fixes the problem
+func (c *checker) issue(problem CheckProblem, table string, line int, message string, repairable bool) {
+	c.report.Issues = append(c.report.Issues, CheckIssue{Problem: problem, Table: table, Line: line, Message: message, repairable: repairable})
+}
+
+// quarantineLine adds a problem to the report and keeps the line for the quarantine table
+func (c *checker) quarantineLine(problem CheckProblem, table string, line int, content string, message string) {
+	c.issue(problem, table, line, message, true)
+	c.quarantine = append(c.quarantine, []string{table, strconv.Itoa(line), string(problem), content})
+}
+
+// checkTables reads every table of the body, the tables are separated by lines with the table frontier
+func (c *checker) checkTables(body string) {
+	if body == "" {
+		return
+	}
+	lines := strings.Split(body, "\n")
+	first := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && lines[i] != "////" {
+			continue
+		}
+		if first == 0 && i > 0 {
+			c.checkPrefix(lines[:i])
+		} else if first > 0 {
+			c.checkSegment(lines[first:i], c.lineOffset+first+1)
+		}
+		first = i + 1
+	}
+}
+
+// checkPrefix reports the content before the first table, which is usually a damaged encryption prefix
+func (c *checker) checkPrefix(lines []string) {
+	for i, line := range lines {
+		if line != "" {
+			c.quarantineLine(ProblemEncryption, "", c.lineOffset+i+1, line,
+				"unrecognized content at the start of the database, it is neither a table nor an encryption prefix")
+		}
+	}
+}
+
+// checkSegment reads the lines of a single table
+// first: line of the file of the first line
+func (c *checker) checkSegment(lines []string, first int) {
+	if !slices.ContainsFunc(lines, func(line string) bool { return line != "" }) {
+		if len(lines) > 0 {
+			c.issue(ProblemMarker, "", first, "blank lines between tables", true)
+		}
+		return
+	}
+	start, end, insertion := -1, -1, -1
+	var name, endName string
+	for i, line := range lines {
+		if match := tableEndRegex.FindStringSubmatch(line); match != nil && end == -1 {
+			end, endName = i, match[1]
+		} else if match = tableStartRegex.FindStringSubmatch(line); match != nil && start == -1 && end == -1 {
+			start, name = i, match[1]
+		} else if line == "!*!" {
+			insertion = i
+		}
+	}
+	if name == "" {
+		name = endName
+	}
+	if name == "" {
+		for i, line := range lines {
+			if line != "" {
+				c.quarantineLine(ProblemMarker, "", first+i, line, "line outside of a table, the table markers were not found")
+			}
+		}
+		return
+	}
+	switch {
+	case start == -1:
+		c.issue(ProblemMarker, name, first, "table start marker not found", true)
+	case end == -1:
+		c.issue(ProblemMarker, name, first+start, "table end marker not found", true)
+	case endName != name:
+		c.issue(ProblemMarker, name, first+end, "table end marker does not match", true)
+	}
+	if insertion == -1 {
+		c.issue(ProblemMarker, name, first, "insertion marker not found", true)
+	} else if end != -1 && insertion != end-1 {
+		c.issue(ProblemMarker, name, first+insertion, "insertion marker is not before the end marker", true)
+	}
+
+	var content []int
+	for i, line := range lines {
+		if i != start && i != end && line != "!*!" && !(line == "" && (i == 0 || i == len(lines)-1)) {
+			content = append(content, i)
+		}
+	}
+	if len(content) == 0 {
+		c.issue(ProblemHeader, name, first+max(start, 0), "column header not found", true)
+		return
+	}
+	t := &checkedTable{name: name}
+	headerLine := lines[content[0]]
+	numbers, columns, ok := parseColumnHeader(headerLine)
+	if !ok {
+		c.issue(ProblemHeader, name, first+content[0], "column header not found", true)
+		for _, i := range content {
+			c.quarantine = append(c.quarantine, []string{name, strconv.Itoa(first + i), string(ProblemHeader), lines[i]})
+		}
+		return
+	}
+	if columns[0] != "id" {
+		c.issue(ProblemHeader, name, first+content[0], fmt.Sprintf("first column is %s, expected id", columns[0]), false)
+	}
+	t.numbers, t.columns = slices.Clone(numbers), columns
+	if name == legacyLinkTableName && headerLine == legacyLinkHeader {
+		// older versions numbered two columns [3], Upgrade moves the table to the system catalog
+		c.issue(ProblemVersion, name, first+content[0], "legacy Links table, Upgrade moves it to the system catalog", false)
+	} else if !sequentialNumbers(numbers) {
+		c.issue(ProblemHeader, name, first+content[0], "columns are not numbered in order", true)
+		for i := range t.numbers {
+			t.numbers[i] = i + 1
+		}
+	}
+	c.tables = append(c.tables, t)
+
+	ids := map[string]int{}
+	for _, i := range content[1:] {
+		c.checkRow(t, numbers, lines[i], first+i, ids)
+	}
+	if !isSystemTable(name) {
+		c.report.Tables++
+		c.report.Rows += len(t.rows)
+	}
+}
+
+// checkRow reads a row of the table, the rows that can't be read are quarantined
+// numbers: column numbers of the header as stored
+// ids: line of every id of the table
+func (c *checker) checkRow(t *checkedTable, numbers []int, line string, number int, ids map[string]int) {
+	if line == "" {
+		c.issue(ProblemFieldCount, t.name, number, "empty row", true)
+		return
+	}
+	markers, values, ok := parseRow(line)
+	if !ok || len(values) != len(t.columns) {
+		c.quarantineLine(ProblemFieldCount, t.name, number, line, fmt.Sprintf("row has %d fields, expected %d", len(values), len(t.columns)))
+		return
+	}
+	if !slices.Equal(markers, numbers) {
+		c.issue(ProblemHeader, t.name, number, "row fields are not numbered like the column header", true)
+	}
+	if previous, found := ids[values[0]]; found {
+		c.quarantineLine(ProblemDuplicateId, t.name, number, line, fmt.Sprintf("id %s is already used by line %d", values[0], previous))
+		return
+	}
+	plain := slices.Clone(values)
+	if globalColumnCipher != nil {
+		for i, value := range values {
+			if _, ok := globalColumnCipher.column(t.name, t.columns[i]); !ok || !isEncryptedCell(value) {
+				continue
+			}
+			decrypted, err := globalColumnCipher.decrypt(value)
+			if err != nil {
+				c.quarantineLine(ProblemEncryption, t.name, number, line, fmt.Sprintf("column %s can't be decrypted", t.columns[i]))
+				return
+			}
+			plain[i] = decrypted
+		}
+	}
+	ids[values[0]] = number
+	t.rows = append(t.rows, checkedRow{line: number, plain: plain})
+}
+
+// checkForeignKeys reports the values that reference a missing row and the foreign keys of missing tables
+func (c *checker) checkForeignKeys() {
+	tables := map[string]*checkedTable{}
+	for _, t := range c.tables {
+		if _, found := tables[t.name]; !found {
+			tables[t.name] = t
+		}
+	}
+	link, found := tables[metaTableName]
+	if !found {
+		if link, found = tables[legacyLinkTableName]; !found || !slices.Contains(link.columns, "table1") {
+			return
+		}
+	}
+	for _, row := range link.rows {
+		key := ForeignKey{
+			TableName:         decodeSystemValue(row.value(link, "table1")),
+			ColumnName:        decodeSystemValue(row.value(link, "columnLink1")),
+			ForeignTableName:  decodeSystemValue(row.value(link, "table2")),
+			ForeignColumnName: decodeSystemValue(row.value(link, "columnLink2")),
+		}
+		parent, child := tables[key.TableName], tables[key.ForeignTableName]
+		parentColumn, childColumn := -1, -1
+		if parent != nil {
+			parentColumn = slices.Index(parent.columns, key.ColumnName)
+		}
+		if child != nil {
+			childColumn = slices.Index(child.columns, key.ForeignColumnName)
+		}
+		if parentColumn == -1 || childColumn == -1 {
+			c.issue(ProblemForeignKey, link.name, row.line, fmt.Sprintf("foreign key %s.%s references %s.%s, which does not exist",
+				key.ForeignTableName, key.ForeignColumnName, key.TableName, key.ColumnName), false)
+			continue
+		}
+		values := map[string]bool{}
+		for _, parentRow := range parent.rows {
+			values[parentRow.plain[parentColumn]] = true
+		}
+		for _, childRow := range child.rows {
+			value := childRow.plain[childColumn]
+			if value == "" || value == "null" || values[value] {
+				continue
+			}
+			err := &ForeignKeyViolationError{key: key, value: strings.ReplaceAll(value, "U+0020", " ")}
+			c.issue(ProblemForeignKey, child.name, childRow.line, err.Error(), false)
+		}
+	}
+}
+
+// value returns the decrypted value of the column, or an empty string if the table has no such column
+func (r checkedRow) value(t *checkedTable, column string) string {
+	if index := slices.Index(t.columns, column); index != -1 {
+		return r.plain[index]
+	}
+	return ""
+}
+
+// build returns the database body with the readable tables, written again, and the quarantined lines
+func (c *checker) build() string {
+	if len(c.quarantine) > 0 {
+		index := slices.IndexFunc(c.tables, func(t *checkedTable) bool { return t.name == quarantineTableName })
+		if index == -1 {
+			c.tables = append(c.tables, &checkedTable{name: quarantineTableName, numbers: []int{1, 2, 3, 4, 5}, columns: quarantineTableColumns})
+			index = len(c.tables) - 1
+		}
+		t := c.tables[index]
+		for _, line := range c.quarantine {
+			values := []string{strconv.Itoa(len(t.rows) + 1)}
+			for _, value := range line {
+				values = append(values, encodeQuarantinedValue(value))
+			}
+			t.rows = append(t.rows, checkedRow{plain: values})
+		}
+	}
+	if len(c.tables) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString("////")
+	for _, t := range c.tables {
+		header := make([]string, len(t.columns))
+		for i, column := range t.columns {
+			header[i] = fmt.Sprintf("[%d] %s", t.numbers[i], column)
+		}
+		builder.WriteString(fmt.Sprintf("\n-----%s-----\n%s\n", t.name, strings.Join(header, " ")))
+		for _, row := range t.rows {
+			fields := make([]string, len(row.plain))
+			for i, value := range row.plain {
+				fields[i] = fmt.Sprintf("|%d| %s", t.numbers[i], value)
+			}
+			builder.WriteString(strings.Join(fields, " ") + "\n")
+		}
+		builder.WriteString(fmt.Sprintf("!*!\n-----%s_End-----\n////", t.name))
+	}
+	return builder.String()
+}
+
+// encodeQuarantinedValue encodes a value of the quarantine table, the row markers and table frontiers
+// of a quarantined line are encoded too, so the line is stored as a single field
+func encodeQuarantinedValue(value string) string {
+	value = encodeSystemValue(value)
+	value = strings.ReplaceAll(value, "|", "U+007C")
+	return strings.ReplaceAll(value, "/", "U+002F")
+}
+
+// parseColumnHeader reads the numbers and the names of the columns of a header line
+// Returns false if the line is not a column header
+func parseColumnHeader(line string) ([]int, []string, bool) {
+	fields := strings.Split(line, " ")
+	if len(fields) < 2 || len(fields)%2 != 0 {
+		return nil, nil, false
+	}
+	var numbers []int
+	var columns []string
+	for i := 0; i < len(fields); i += 2 {
+		match := headerMarkerRegex.FindStringSubmatch(fields[i])
+		if match == nil || fields[i+1] == "" || headerMarkerRegex.MatchString(fields[i+1]) {
+			return nil, nil, false
+		}
+		number, _ := strconv.Atoi(match[1])
+		numbers = append(numbers, number)
+		columns = append(columns, fields[i+1])
+	}
+	return numbers, columns, true
+}
+
+// parseRow reads the field numbers and the values of a row line
+// Returns false if the line is not a row
+func parseRow(line string) ([]int, []string, bool) {
+	if !strings.HasPrefix(line, "|1| ") {
+		return nil, nil, false
+	}
+	matches := rowMarkerRegex.FindAllStringSubmatchIndex(line, -1)
+	numbers := make([]int, len(matches))
+	values := make([]string, len(matches))
+	for i, match := range matches {
+		numbers[i], _ = strconv.Atoi(line[match[2]:match[3]])
+		valueEnd := len(line)
+		if i+1 < len(matches) {
+			valueEnd = matches[i+1][0]
+		}
+		values[i] = strings.TrimSpace(line[match[1]:valueEnd])
+	}
+	return numbers, values, true
+}
+
+// sequentialNumbers reports whether the column numbers start at 1 and always increase,
+// deleted columns leave gaps in the numbering
+func sequentialNumbers(numbers []int) bool {
+	if len(numbers) == 0 || numbers[0] != 1 {
+		return false
+	}
+	for i := 1; i < len(numbers); i++ {
+		if numbers[i] <= numbers[i-1] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,377 @@
+package tdb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// EncryptedColumn marks a column whose values are stored encrypted while the rest of the table stays readable.
+//
+// Example:
+//
+//	config := tdb.DbConfig{
+//		DatabaseName:  "mydb.txt",
+//		EncryptionKey: "secret",
+//		EncryptedColumns: []tdb.EncryptedColumn{
+//			{TableName: "Users", ColumnName: "email", Deterministic: true},
+//			{TableName: "Users", ColumnName: "ssn"},
+//		},
+//	}
+type EncryptedColumn struct {
+	TableName     string // Name of the table
+	ColumnName    string // Name of the encrypted column
+	Deterministic bool   // Equal values produce equal ciphertexts, so the stored file can be searched by value
+}
+
+// columnCipher encrypts and decrypts the values of the encrypted columns.
+type columnCipher struct {
+	secret   []byte
+	salt     []byte
+	params   KeyDerivation
+	key      []byte
+	macKey   []byte
+	columns  []EncryptedColumn
+	cells    map[string]encryptedCell
+	provider KeyProvider
+}
+
+// encryptedCell remembers the ciphertext of a decrypted cell,
+// so unchanged values keep the same ciphertext when the table is saved again.
+type encryptedCell struct {
+	plainText  string
+	cipherText string
+}
+
+var globalColumnCipher *columnCipher
+
+const (
+	columnKeyHeaderPrefix = "CKD "
+	columnKeyHeaderFormat = "CKD scrypt N=%d r=%d p=%d salt=%s\n"
+	randomCellPrefix      = "ENC:"
+	deterministicPrefix   = "DET:"
+)
+
+var cellRegex = regexp.MustCompile(`\|(\d+)\| `)
+var columnRegex = regexp.MustCompile(`\[(\d+)\] (\S+)`)
+
+// newColumnCipher creates a column cipher for the columns, reading the salt from the database header if present
+// secretKey: passphrase used to derive the column key
+// kdf: key derivation parameters for new databases, nil uses the defaults
+// data: current content of the database
+func newColumnCipher(secretKey string, kdf *KeyDerivation, columns []EncryptedColumn, data string) (*columnCipher, error) {
+	c := &columnCipher{
+		secret:  []byte(secretKey),
+		params:  defaultKeyDerivation,
+		columns: columns,
+		cells:   map[string]encryptedCell{},
+	}
+	if kdf != nil {
+		c.params = *kdf
+	}
+	header, _ := splitFileHeader(data)
+	for _, line := range strings.Split(header, "\n") {
+		if !strings.HasPrefix(line, columnKeyHeaderPrefix) {
+			continue
+		}
+		var saltB64 string
+		_, err := fmt.Sscanf(line, strings.TrimSuffix(columnKeyHeaderFormat, "\n"), &c.params.N, &c.params.R, &c.params.P, &saltB64)
+		if err != nil {
+			return nil, errors.New("invalid column key header")
+		}
+		if err = validateKeyDerivation(&c.params); err != nil {
+			return nil, fmt.Errorf("invalid column key header: %w", err)
+		}
+		c.salt, err = base64.StdEncoding.DecodeString(saltB64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.salt == nil {
+		c.salt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, c.salt); err != nil {
+			return nil, err
+		}
+	}
+	key, err := scrypt.Key(c.secret, c.salt, c.params.N, c.params.R, c.params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	c.key = key
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("tdb column index"))
+	c.macKey = mac.Sum(nil)
+	return c, nil
+}
+
+// header returns the plaintext header line storing the column key derivation parameters
+func (c *columnCipher) header() string {
+	return fmt.Sprintf(columnKeyHeaderFormat, c.params.N, c.params.R, c.params.P, base64.StdEncoding.EncodeToString(c.salt))
+}
+
+// column returns the encrypted column configuration for the table column
+func (c *columnCipher) column(tableName string, columnName string) (EncryptedColumn, bool) {
+	for _, col := range c.columns {
+		if col.TableName == tableName && col.ColumnName == columnName {
+			return col, true
+		}
+	}
+	return EncryptedColumn{}, false
+}
+
+// encrypt encrypts a cell value with AES-GCM, encoded in unpadded URL-safe base64 so it never contains '/'
+// In deterministic mode the nonce is derived from the value, so equal values produce equal ciphertexts
+func (c *columnCipher) encrypt(col EncryptedColumn, value string) (string, error) {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	prefix := randomCellPrefix
+	nonce := make([]byte, gcm.NonceSize())
+	if col.Deterministic {
+		prefix = deterministicPrefix
+		mac := hmac.New(sha256.New, c.macKey)
+		mac.Write([]byte(col.TableName + "\x00" + col.ColumnName + "\x00" + value))
+		copy(nonce, mac.Sum(nil))
+	} else if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	cipherText := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return prefix + base64.RawURLEncoding.EncodeToString(cipherText), nil
+}
+
+// decrypt decrypts a cell value written by encrypt, cells written with standard base64 are still accepted
+func (c *columnCipher) decrypt(value string) (string, error) {
+	value = strings.TrimPrefix(strings.TrimPrefix(value, randomCellPrefix), deterministicPrefix)
+	cipherText, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		if cipherText, err = base64.StdEncoding.DecodeString(value); err != nil {
+			return "", err
+		}
+	}
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(cipherText) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	nonce, cipherText := cipherText[:gcm.NonceSize()], cipherText[gcm.NonceSize():]
+	plainText, err := gcm.Open(nil, nonce, cipherText, nil)
+	if err != nil {
+		return "", ErrInvalidKey
+	}
+	return string(plainText), nil
+}
+
+// validateEncryptedColumns checks if the encrypted columns are valid
+// columns: encrypted columns to validate
+// Returns an error if a column has no name or is the id column
+func validateEncryptedColumns(columns []EncryptedColumn) error {
+	for _, col := range columns {
+		if col.TableName == "" || col.ColumnName == "" {
+			return errors.New("encrypted columns require a table name and a column name")
+		}
+		if col.ColumnName == "id" {
+			return errors.New("the id column can't be encrypted")
+		}
+	}
+	return nil
+}
+
+// hasEncryptedColumn reports whether the table has an encrypted column, or the column is encrypted if columnName is not empty
+func hasEncryptedColumn(tableName string, columnName string) bool {
+	if globalColumnCipher == nil {
+		return false
+	}
+	for _, col := range globalColumnCipher.columns {
+		if col.TableName == tableName && (columnName == "" || col.ColumnName == columnName) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEncryptedColumns reports whether the database stored in storage has encrypted columns,
+// the storage is only read so the key is not needed
+//
+// Example:
+//
+//	hasColumns, err := tdb.HasEncryptedColumns(tdb.NewFileStorage("mydb.txt"))
+func HasEncryptedColumns(storage Storage) (bool, error) {
+	if !storage.Exists() {
+		return false, &NotFoundError{itemName: "Database"}
+	}
+	data, err := storage.Read()
+	if err != nil {
+		return false, err
+	}
+	header, _ := splitFileHeader(string(data))
+	return hasColumnKeyHeader(header), nil
+}
+
+// hasColumnKeyHeader reports whether the header lines store a column key, so the database has encrypted columns
+func hasColumnKeyHeader(header string) bool {
+	return strings.HasPrefix(header, columnKeyHeaderPrefix) || strings.Contains(header, "\n"+columnKeyHeaderPrefix)
+}
+
+// isEncryptedCell reports whether the value was written by a column cipher
+func isEncryptedCell(value string) bool {
+	return strings.HasPrefix(value, randomCellPrefix) || strings.HasPrefix(value, deterministicPrefix)
+}
+
+// decryptColumns decrypts the cells of the encrypted columns in the database content
+// Returns the data unchanged if column encryption is not enabled, and the first error found
+func decryptColumns(data string) (string, error) {
+	if globalColumnCipher == nil {
+		return data, nil
+	}
+	return globalColumnCipher.decryptCells(data)
+}
+
+// decryptCells decrypts the cells of the encrypted columns in the database content
+// Cells that can't be decrypted are kept as they are stored, so saving the database again doesn't lose them
+// The remembered ciphertexts are replaced by the ones of the data, so cells of deleted rows are forgotten
+// Returns the first error found
+func (c *columnCipher) decryptCells(data string) (string, error) {
+	var firstErr error
+	c.cells = map[string]encryptedCell{}
+	data = transformCells(data, func(tableName string, id string, column string, value string) string {
+		if _, ok := c.column(tableName, column); !ok || !isEncryptedCell(value) {
+			return value
+		}
+		plainText, err := c.decrypt(value)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = cellDecryptionError(tableName, column, err)
+			}
+			plainText = value
+		}
+		c.cells[cellKey(tableName, id, column)] = encryptedCell{plainText: plainText, cipherText: value}
+		return plainText
+	})
+	return data, firstErr
+}
+
+// cellDecryptionError returns ErrInvalidKey if the cell was encrypted with another key,
+// and a CorruptionError if the stored ciphertext is damaged
+func cellDecryptionError(tableName string, column string, err error) error {
+	if errors.Is(err, ErrInvalidKey) {
+		return err
+	}
+	return &CorruptionError{tableName: tableName, reason: fmt.Sprintf("column %s can't be decrypted: %s", column, err)}
+}
+
+// encryptColumns encrypts the cells of the encrypted columns in the database content
+// data: database content with every cell in plaintext
+// Returns the data unchanged if column encryption is not enabled
+func encryptColumns(data string) (string, error) {
+	if globalColumnCipher == nil {
+		return data, nil
+	}
+	return globalColumnCipher.encryptCells(data)
+}
+
+// encryptCells encrypts the cells of the encrypted columns in the database content
+// data: database content with every cell in plaintext
+// Cells whose value did not change since they were read keep their ciphertext
+func (c *columnCipher) encryptCells(data string) (string, error) {
+	var firstErr error
+	data = transformCells(data, func(tableName string, id string, column string, value string) string {
+		col, ok := c.column(tableName, column)
+		if !ok || value == "null" {
+			return value
+		}
+		if cell, found := c.cells[cellKey(tableName, id, column)]; found && cell.plainText == value {
+			return cell.cipherText
+		}
+		cipherText, err := c.encrypt(col, value)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return cipherText
+	})
+	return data, firstErr
+}
+
+// transformCells applies fn to every cell value of every table in the database content
+func transformCells(data string, fn func(tableName string, id string, column string, value string) string) string {
+	segments := strings.Split(data, "////")
+	for i, segment := range segments {
+		if !strings.Contains(segment, "-----") {
+			continue
+		}
+		lines := strings.Split(segment, "\n")
+		if len(lines) < 3 {
+			continue
+		}
+		tableName := strings.Trim(getTableName(segment), "-")
+		columns := map[string]string{}
+		for _, match := range columnRegex.FindAllStringSubmatch(lines[2], -1) {
+			columns[match[1]] = match[2]
+		}
+		for j := 3; j < len(lines); j++ {
+			if !strings.HasPrefix(lines[j], "|") {
+				continue
+			}
+			positions, values := splitCells(lines[j])
+			if len(values) == 0 {
+				continue
+			}
+			id := values[0]
+			for k := range values {
+				values[k] = fn(tableName, id, columns[positions[k]], values[k])
+			}
+			lines[j] = joinCells(positions, values)
+		}
+		segments[i] = strings.Join(lines, "\n")
+	}
+	return strings.Join(segments, "////")
+}
+
+// splitCells splits a raw row into its column positions and values
+func splitCells(row string) ([]string, []string) {
+	markers := cellRegex.FindAllStringSubmatchIndex(row, -1)
+	positions := make([]string, len(markers))
+	values := make([]string, len(markers))
+	for i, m := range markers {
+		positions[i] = row[m[2]:m[3]]
+		end := len(row)
+		if i+1 < len(markers) {
+			end = markers[i+1][0]
+		}
+		values[i] = strings.TrimSpace(row[m[1]:end])
+	}
+	return positions, values
+}
+
+// joinCells builds a raw row from its column positions and values
+func joinCells(positions []string, values []string) string {
+	cells := make([]string, len(values))
+	for i := range values {
+		cells[i] = fmt.Sprintf("|%s| %s", positions[i], values[i])
+	}
+	return strings.Join(cells, " ")
+}
+
+// cellKey identifies a cell of the database
+func cellKey(tableName string, id string, column string) string {
+	return tableName + "\x00" + id + "\x00" + column
+}
@@ -0,0 +1,112 @@
+package tdb
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVOptions configures how ImportCSV reads a CSV file
+type CSVOptions struct {
+	Comma       rune              // Field delimiter, ',' by default
+	Columns     map[string]string // Maps a header of the file to a column of the table, headers named like their column need no entry
+	GenerateIds bool              // Generate new ids even when the file has an id column
+}
+
+// ExportCSV writes the table as CSV: a header with the column names, id included, and a record per row.
+// Values with commas, quotes or line breaks are quoted.
+//
+// Example usage:
+//
+//	file, _ := os.Create("users.csv")
+//	defer file.Close()
+//	err := table.ExportCSV(file)
+func (t *table) ExportCSV(w io.Writer) error {
+	columns := tableColumnNames(*t)
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range t.GetRows() {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = strings.ReplaceAll(row.SearchValue(column), "U+0020", " ")
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ImportCSV inserts the records of a CSV file with a header into the table and returns the number of rows imported.
+// The table is created from the header when it doesn't exist. The headers are mapped to the columns by name or through
+// CSVOptions.Columns, the columns missing from the file and the empty fields are null. The ids of an id column are
+// preserved unless CSVOptions.GenerateIds is set, an empty id is generated.
+// The lines that can't be imported are skipped and returned in an ImportError, the other lines are imported.
+//
+// Example:
+//
+//	file, _ := os.Open("users.csv")
+//	defer file.Close()
+//	imported, err := db.ImportCSV("Users", file, nil)
+//	var lineErrors *ImportError
+//	if errors.As(err, &lineErrors) {
+//		for _, lineError := range lineErrors.Lines() {
+//			fmt.Println(lineError)
+//		}
+//	}
+func (d *db) ImportCSV(tableName string, r io.Reader, opts *CSVOptions) (int, error) {
+	if readOnly {
+		return 0, ErrReadOnly
+	}
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, errors.New("the CSV file has no header")
+	}
+	if err != nil {
+		return 0, err
+	}
+	columns, err := mapColumns(header, opts.Columns)
+	if err != nil {
+		return 0, err
+	}
+	im := &importer{d: d, tableName: tableName, generateIds: opts.GenerateIds}
+	if err = im.prepare(columns, true); err != nil {
+		return 0, err
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			im.fail(parseError.StartLine, parseError.Err)
+			continue
+		}
+		if err != nil {
+			return im.stop(err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(columns) {
+			im.fail(line, fmt.Errorf("expected %d fields, found %d", len(columns), len(record)))
+			continue
+		}
+		if err = im.insert(line, columns, record); err != nil {
+			return im.stop(err)
+		}
+	}
+	return im.result()
+}
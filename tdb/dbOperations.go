@@ -1,9 +1,10 @@
 package tdb
 
 import (
+	"crypto/hmac"
 	"errors"
 	"fmt"
-	"os"
+	"io"
 	"slices"
 	"strings"
 	"sync"
@@ -45,12 +46,12 @@ type Db interface {
 	PrintTables()
 
 	// NewTable creates a new table with specified name and columns
-	// Returns the newly created table
+	// Returns the newly created table, or ErrReadOnly if the database is read-only
 	//
 	// Example:
 	//  columns := []string{"id", "name", "email"}
-	//  usersTable := db.NewTable("users", columns)
-	NewTable(name string, columns []string) Table
+	//  usersTable, err := db.NewTable("users", columns)
+	NewTable(name string, columns []string) (Table, error)
 
 	// DeleteTable removes a table from the database
 	// Returns error if table doesn't exist
@@ -67,10 +68,10 @@ type Db interface {
 	//
 	// Example:
 	//  fk := ForeignKey{
-	//      TableName: "orders",
-	//      ColumnName: "user_id",
-	//      ForeignTableName: "users",
-	//      ForeignColumnName: "id",
+	//      TableName: "users",
+	//      ColumnName: "id",
+	//      ForeignTableName: "orders",
+	//      ForeignColumnName: "user_id",
 	//  }
 	//  err := db.AddForeignKey(fk)
 	AddForeignKey(key ForeignKey) error
@@ -80,12 +81,73 @@ type Db interface {
 	//
 	// Example:
 	//  fks := []ForeignKey{
-	//      {TableName: "orders", ColumnName: "user_id", ForeignTableName: "users", ForeignColumnName: "id"},
-	//      {TableName: "orders", ColumnName: "product_id", ForeignTableName: "products", ForeignColumnName: "id"},
+	//      {TableName: "users", ColumnName: "id", ForeignTableName: "orders", ForeignColumnName: "user_id"},
+	//      {TableName: "products", ColumnName: "id", ForeignTableName: "orders", ForeignColumnName: "product_id"},
 	//  }
 	//  err := db.AddForeignKeys(fks)
 	AddForeignKeys(keys []ForeignKey) error
 
+	// ForeignKeys returns every foreign key relationship of the database
+	//
+	// Example:
+	//  for _, fk := range db.ForeignKeys() {
+	//      fmt.Printf("%s.%s -> %s.%s\n", fk.ForeignTableName, fk.ForeignColumnName, fk.TableName, fk.ColumnName)
+	//  }
+	ForeignKeys() []ForeignKey
+
+	// ForeignKeysOf returns the foreign key relationships that reference or are referenced by the table
+	// Returns error if table doesn't exist
+	//
+	// Example:
+	//  fks, err := db.ForeignKeysOf("orders")
+	ForeignKeysOf(tableName string) ([]ForeignKey, error)
+
+	// DropForeignKey removes a foreign key relationship, the referential actions of the key are ignored
+	// Returns error if the relationship doesn't exist
+	//
+	// Example:
+	//  err := db.DropForeignKey(ForeignKey{
+	//      TableName: "users",
+	//      ColumnName: "id",
+	//      ForeignTableName: "orders",
+	//      ForeignColumnName: "user_id",
+	//  })
+	DropForeignKey(key ForeignKey) error
+
+	// SystemTables returns the names of the system tables, which are hidden from GetTables and FromSql
+	//
+	// Example:
+	//  names := db.SystemTables()
+	SystemTables() []string
+
+	// SystemTableRows returns a read-only copy of the rows of a system table
+	// Returns error if the system table doesn't exist
+	//
+	// Example:
+	//  rows, err := db.SystemTableRows("__tdb_meta")
+	SystemTableRows(name string) (Rows, error)
+
+	// MigrateTo applies or rolls back the migrations until version is the last applied migration
+	// Returns error if a migration to roll back has no Down function
+	//
+	// Example:
+	//  err := db.MigrateTo("0001_create_users", migrations...)
+	MigrateTo(version string, migrations ...Migration) error
+
+	// PlanMigrateTo reports what MigrateTo would do without writing the database
+	// Returns error if the migrations can't be run, the errors of the migrations are part of the plan
+	// Returns ErrTransactionInProgress if a transaction is in progress
+	//
+	// Example:
+	//  plan, err := db.PlanMigrateTo("0001_create_users", migrations...)
+	PlanMigrateTo(version string, migrations ...Migration) (MigrationPlan, error)
+
+	// MigrationVersion returns the ID of the last applied migration
+	//
+	// Example:
+	//  version := db.MigrationVersion()
+	MigrationVersion() string
+
 	// FromSql executes an SQL query and returns the results
 	// Returns error if query is invalid or execution fails
 	//
@@ -95,6 +157,129 @@ type Db interface {
 	//      log.Fatal("Query failed:", err)
 	//  }
 	FromSql(sql string) (SqlRows, error)
+
+	// ImportCSV inserts the records of a CSV file into the table, creating it from the header when it doesn't exist
+	// Returns the number of rows imported and a ImportError with the lines that were skipped
+	//
+	// Example:
+	//  file, _ := os.Open("users.csv")
+	//  imported, err := db.ImportCSV("users", file, &CSVOptions{GenerateIds: true})
+	ImportCSV(tableName string, r io.Reader, opts *CSVOptions) (int, error)
+
+	// ExportJSON writes the tables with their columns and rows, and the foreign keys, as a JSON document
+	//
+	// Example:
+	//  err := db.ExportJSON(file)
+	ExportJSON(w io.Writer) error
+
+	// ImportJSON reads a document written by ExportJSON, creating the missing tables and foreign keys
+	// Nothing is imported if a row fails
+	//
+	// Example:
+	//  err := db.ImportJSON(file)
+	ImportJSON(r io.Reader) error
+
+	// ImportNDJSON inserts the objects of a newline-delimited JSON file into the table, creating it when it doesn't exist
+	// Returns the number of rows imported and an ImportError with the lines that were skipped
+	//
+	// Example:
+	//  imported, err := db.ImportNDJSON("users", file, nil)
+	ImportNDJSON(tableName string, r io.Reader, opts *JSONOptions) (int, error)
+
+	// Dump writes the database as an SQL script of CREATE TABLE and INSERT statements
+	//
+	// Example:
+	//  err := db.Dump(file)
+	Dump(w io.Writer) error
+
+	// Restore replays an SQL script written by Dump, nothing is restored if a statement fails
+	//
+	// Example:
+	//  err := db.Restore(file)
+	Restore(r io.Reader) error
+
+	// FormatVersion returns the version of the file format of the database, 0 for databases without format header
+	//
+	// Example:
+	//  version := db.FormatVersion()
+	FormatVersion() int
+
+	// Upgrade rewrites a database written by an older version of the library in the current format
+	// Returns ErrReadOnly if the database is read-only
+	//
+	// Example:
+	//  err := db.Upgrade()
+	Upgrade() error
+
+	// Check validates the markers, column headers, fields, ids, foreign keys, encryption and checksums of the database
+	// Returns a report with every problem found
+	//
+	// Example:
+	//  report, err := db.Check()
+	Check() (CheckReport, error)
+
+	// Repair rewrites the database without the problems found by Check, the unreadable rows are quarantined
+	// Returns ErrReadOnly if the database is read-only
+	//
+	// Example:
+	//  report, err := db.Repair()
+	Repair() (CheckReport, error)
+
+	// Snapshot writes a consistent point-in-time copy of the database, optionally re-encrypted with another key
+	//
+	// Example:
+	//  err := db.Snapshot(&buffer, nil)
+	Snapshot(w io.Writer, opts *BackupOptions) error
+
+	// Backup writes a consistent point-in-time copy of the database to the file dst
+	//
+	// Example:
+	//  err := db.Backup("backups/mydb.txt", &BackupOptions{EncryptionKey: "backup-secret"})
+	Backup(dst string, opts *BackupOptions) error
+
+	// RotatingBackup writes a timestamped backup in dir and keeps only the latest retention backups
+	// Returns the path of the new backup
+	//
+	// Example:
+	//  path, err := db.RotatingBackup("backups", 7, nil)
+	RotatingBackup(dir string, retention int, opts *BackupOptions) (string, error)
+
+	// RotateKey re-encrypts the database with a new encryption key
+	// Returns ErrInvalidKey if oldKey does not decrypt the database
+	//
+	// Example:
+	//  err := db.RotateKey("old-secret", "new-secret")
+	//  if err != nil {
+	//      log.Fatal("Key rotation failed:", err)
+	//  }
+	RotateKey(oldKey string, newKey string) error
+
+	// Begin starts a transaction, the changes made until Commit can be undone with Rollback
+	//
+	// Example:
+	//  err := db.Begin()
+	Begin() error
+
+	// Commit ends the transaction and keeps its changes,
+	// deferred foreign key checks run before the transaction ends
+	//
+	// Example:
+	//  err := db.Commit()
+	Commit() error
+
+	// Rollback ends the transaction and restores the database as it was when it started
+	//
+	// Example:
+	//  err := db.Rollback()
+	Rollback() error
+
+	// RefreshKey obtains the key again from the configured KeyProvider
+	// and rotates the database to it when it changed
+	//
+	// Example:
+	//  // after updating the secret read by the key provider
+	//  err := db.RefreshKey()
+	RefreshKey() error
 }
 type db struct {
 	name   string
@@ -113,25 +298,55 @@ type Values []string
 
 // DbConfig defines the configuration for creating a new database
 type DbConfig struct {
-	EncryptionKey string       // Optional encryption key for database content
-	DatabaseName  string       // Name of the database file
-	DataConfig    []DataConfig // Initial data configuration for tables
+	EncryptionKey    string            // Optional encryption key for database content
+	DatabaseName     string            // Name of the database file
+	DataConfig       []DataConfig      // Initial data configuration for tables
+	Storage          Storage           // Optional storage backend, defaults to a file named DatabaseName
+	ReadOnly         bool              // Open an existing database without ever writing to it
+	KeyDerivation    *KeyDerivation    // Optional scrypt work factor for new encrypted files
+	KeyProvider      KeyProvider       // Optional source of the encryption key, used when EncryptionKey is empty
+	EncryptedColumns []EncryptedColumn // Optional columns encrypted individually instead of the whole file
+	Checksums        bool              // Store per-table and whole-file checksums, verified when the database is opened
+	DeferForeignKeys bool              // Check foreign keys when a transaction is committed instead of on every write
+}
+
+// Options defines the configuration used by Open, Create and OpenOrCreate
+type Options struct {
+	EncryptionKey    string            // Optional encryption key for database content
+	Storage          Storage           // Optional storage backend, defaults to a file named after the path
+	ReadOnly         bool              // Open an existing database without ever writing to it
+	Seed             []DataConfig      // Optional fixture written only when the database is created
+	KeyDerivation    *KeyDerivation    // Optional scrypt work factor for new encrypted files
+	KeyProvider      KeyProvider       // Optional source of the encryption key, used when EncryptionKey is empty
+	EncryptedColumns []EncryptedColumn // Optional columns encrypted individually instead of the whole file
+	Checksums        bool              // Store per-table and whole-file checksums, verified when the database is opened
+	DeferForeignKeys bool              // Check foreign keys when a transaction is committed instead of on every write
 }
 
 // ForeignKey defines a relationship between two tables through their columns
 type ForeignKey struct {
-	TableName         string // Name of the source table
-	ColumnName        string // Name of the source column
-	ForeignTableName  string // Name of the referenced table
-	ForeignColumnName string // Name of the referenced column
+	TableName         string            // Name of the referenced table
+	ColumnName        string            // Name of the referenced column
+	ForeignTableName  string            // Name of the table that holds the reference
+	ForeignColumnName string            // Name of the column that holds the reference
+	OnDelete          ReferentialAction // Action applied to the referencing rows when the referenced row is deleted, NoAction by default
+	OnUpdate          ReferentialAction // Action applied to the referencing rows when the referenced value is updated, NoAction by default
+	DefaultValue      string            // Value written by SetDefault, null if empty
 }
 
 var encryptionKeyExist bool
 var dbName string
+var readOnly bool
 
 // CreateDatabase creates a new database instance with the specified configuration
 // Returns a database interface and any error encountered during creation
 //
+// When ReadOnly is set, the database must already exist and is never written,
+// every mutating operation returns ErrReadOnly
+//
+// A brand-new database without DataConfig is filled with a sample Users table,
+// use Create or OpenOrCreate to start from an empty database
+//
 // Example:
 //
 //	config := DbConfig{
@@ -143,41 +358,235 @@ var dbName string
 //		log.Fatal(err)
 //	}
 func (c DbConfig) CreateDatabase() (Db, error) {
-	checkDataErr := checkDataConfig(c.DataConfig)
-	if checkDataErr != nil {
-		return nil, checkDataErr
+	if err := c.validate(); err != nil {
+		return nil, err
 	}
-	validationErr := validateDatabaseName(c.DatabaseName)
-	if validationErr != nil {
-		return nil, validationErr
+	if err := c.initDatabase(); err != nil {
+		return nil, err
 	}
-
-	dbName = c.DatabaseName
-	if strings.TrimSpace(c.EncryptionKey) != "" {
-		globalEncoderKey = *newSecureTextEncoder(c.EncryptionKey)
-		encryptionKeyExist = true
+	if readOnly {
+		return openExisting(c)
 	}
-	if !isFileExist(c.DatabaseName) {
-		errorHandler(os.WriteFile(c.DatabaseName, []byte{}, 0644))
+	if !dbStorage.Exists() {
+		saveDatabase("")
 		if c.DataConfig == nil {
 			setDefaultData(c)
 		}
 
 	} else {
-		data := string(must(os.ReadFile(c.DatabaseName)))
+		if err := verifyDatabase(); err != nil {
+			return nil, err
+		}
+		data := string(readDatabase())
 		if !isEncode(data) && encryptionKeyExist {
-			encodeAndSave(data)
+			saveDatabase(data)
+		} else if data = readPlainDatabase(); globalColumnCipher != nil {
+			saveDatabase(data)
 		}
+		upgradeLinkTable()
+	}
+
+	if c.DataConfig != nil {
+		newDb := setDatabaseData(c)
+		return &newDb, nil
+	}
+
+	return &db{name: c.DatabaseName, tables: getTables(true)}, nil
+}
+
+// Open opens an existing database, it never creates the database nor adds any data to it
+// Opening never writes the database: a plain database stays plain even with an EncryptionKey, and files
+// in an older format are only converted by Upgrade or by the next write
+// Returns a NotFoundError if the database does not exist
+//
+// Example:
+//
+//	db, err := tdb.Open("mydb.txt", &tdb.Options{EncryptionKey: "secret"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func Open(path string, opts *Options) (Db, error) {
+	c := opts.toConfig(path)
+	c.DataConfig = nil
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if err := c.initDatabase(); err != nil {
+		return nil, err
 	}
+	return openExisting(c)
+}
 
+// Create creates a new empty database, seeded only with the data in Options.Seed
+// Returns ErrAlreadyExists if the database already exists
+//
+// Example:
+//
+//	db, err := tdb.Create("mydb.txt", &tdb.Options{
+//		Seed: []tdb.DataConfig{{TableName: "Users", Columns: []string{"name"}}},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func Create(path string, opts *Options) (Db, error) {
+	c := opts.toConfig(path)
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if c.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if c.getStorage().Exists() {
+		return nil, ErrAlreadyExists
+	}
+	if err := c.initDatabase(); err != nil {
+		return nil, err
+	}
+	if readOnly {
+		return nil, ErrReadOnly
+	}
+	saveDatabase("")
 	if c.DataConfig != nil {
 		newDb := setDatabaseData(c)
 		return &newDb, nil
 	}
+	return &db{name: c.DatabaseName, tables: getTables(true)}, nil
+}
+
+// OpenOrCreate opens the database if it exists, otherwise creates it like Create
+//
+// Example:
+//
+//	db, err := tdb.OpenOrCreate("mydb.txt", nil)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func OpenOrCreate(path string, opts *Options) (Db, error) {
+	c := opts.toConfig(path)
+	if c.getStorage().Exists() {
+		return Open(path, opts)
+	}
+	return Create(path, opts)
+}
+
+// toConfig converts the options into a database configuration for the given path
+func (o *Options) toConfig(path string) DbConfig {
+	if o == nil {
+		return DbConfig{DatabaseName: path}
+	}
+	return DbConfig{
+		EncryptionKey:    o.EncryptionKey,
+		DatabaseName:     path,
+		DataConfig:       o.Seed,
+		Storage:          o.Storage,
+		ReadOnly:         o.ReadOnly,
+		KeyDerivation:    o.KeyDerivation,
+		KeyProvider:      o.KeyProvider,
+		EncryptedColumns: o.EncryptedColumns,
+		Checksums:        o.Checksums,
+		DeferForeignKeys: o.DeferForeignKeys,
+	}
+}
+
+// validate checks the database name and the initial data configuration
+func (c DbConfig) validate() error {
+	checkDataErr := checkDataConfig(c.DataConfig)
+	if checkDataErr != nil {
+		return checkDataErr
+	}
+	if err := validateKeyDerivation(c.KeyDerivation); err != nil {
+		return err
+	}
+	if err := validateEncryptedColumns(c.EncryptedColumns); err != nil {
+		return err
+	}
+	return validateDatabaseName(c.DatabaseName)
+}
+
+// initDatabase sets the configuration as the current database
+// Returns an error if the key provider can't obtain the encryption key
+func (c DbConfig) initDatabase() error {
+	key, err := c.getEncryptionKey()
+	if err != nil {
+		return err
+	}
+	dbName = c.DatabaseName
+	dbStorage = c.getStorage()
+	encryptionKeyExist = false
+	globalColumnCipher = nil
+	checksumsEnabled = c.Checksums
+	deferForeignKeys = c.DeferForeignKeys
+	activeTransaction = nil
+	readOnly = c.ReadOnly || isReadOnlyStorage(dbStorage)
+	if len(c.EncryptedColumns) > 0 {
+		return initColumnEncryption(c, key)
+	}
+	if strings.TrimSpace(key) != "" {
+		globalEncoderKey = *newSecureTextEncoder(key, c.KeyDerivation)
+		globalEncoderKey.provider = c.KeyProvider
+		encryptionKeyExist = true
+	}
+	return nil
+}
+
+// initColumnEncryption enables the encryption of individual columns instead of the whole file
+// Returns an error if there is no key or the database already uses whole-file encryption
+func initColumnEncryption(c DbConfig, key string) error {
+	if strings.TrimSpace(key) == "" {
+		return &NotFoundError{itemName: "EncryptionKey"}
+	}
+	var data string
+	if dbStorage.Exists() {
+		data = string(readDatabase())
+	}
+	if isEncode(data) {
+		return errors.New("database uses whole-file encryption, remove it before encrypting columns")
+	}
+	columnCipher, err := newColumnCipher(key, c.KeyDerivation, c.EncryptedColumns, data)
+	if err != nil {
+		return err
+	}
+	columnCipher.provider = c.KeyProvider
+	globalColumnCipher = columnCipher
+	return nil
+}
+
+// getEncryptionKey returns the configured encryption key, obtaining it from the key provider if needed
+func (c DbConfig) getEncryptionKey() (string, error) {
+	if strings.TrimSpace(c.EncryptionKey) != "" || c.KeyProvider == nil {
+		return c.EncryptionKey, nil
+	}
+	return c.KeyProvider.Key()
+}
 
+// openExisting opens an existing database without writing to it,
+// the key is ignored if the database is not encrypted
+// c: database configuration
+// Returns the database and an error if the database does not exist
+func openExisting(c DbConfig) (Db, error) {
+	if !dbStorage.Exists() {
+		return nil, &NotFoundError{itemName: "Database"}
+	}
+	if c.DataConfig != nil {
+		return nil, ErrReadOnly
+	}
+	if encryptionKeyExist && !isEncode(string(readDatabase())) {
+		encryptionKeyExist = false
+	}
+	if err := verifyDatabase(); err != nil {
+		return nil, err
+	}
 	return &db{name: c.DatabaseName, tables: getTables(true)}, nil
 }
 
+// getStorage returns the configured storage or a file storage named after the database
+func (c DbConfig) getStorage() Storage {
+	if c.Storage != nil {
+		return c.Storage
+	}
+	return NewFileStorage(c.DatabaseName)
+}
+
 // RemoveEncryption removes encryption from an encrypted database using the provided encryption key
 // Returns an error if the database is not found or encryption key is invalid
 //
@@ -195,8 +604,11 @@ func (c DbConfig) RemoveEncryption() error {
 	if dbName == "" {
 		return &NotFoundError{itemName: "Database"}
 	}
-	if strings.TrimSpace(c.EncryptionKey) != "" {
-		data := string(must(os.ReadFile(c.DatabaseName)))
+	if readOnly {
+		return ErrReadOnly
+	}
+	if strings.TrimSpace(c.EncryptionKey) != "" || c.KeyProvider != nil {
+		data := string(readDatabase())
 		if isEncode(data) {
 			decodeAndSave(data)
 			return nil
@@ -205,6 +617,40 @@ func (c DbConfig) RemoveEncryption() error {
 	return &NotFoundError{itemName: "EncryptionKey"}
 }
 
+// UpgradeEncryption re-encrypts the database with a new random salt and the configured key derivation,
+// upgrading files written with the legacy unsalted SHA-256 key
+// Returns an error if the database is not found, not encrypted or read-only
+//
+// Example:
+//
+//	config := DbConfig{
+//		DatabaseName: "mydb.txt",
+//		EncryptionKey: "secret",
+//	}
+//	db, err := config.CreateDatabase()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = config.UpgradeEncryption()
+func (c DbConfig) UpgradeEncryption() error {
+	if dbName == "" {
+		return &NotFoundError{itemName: "Database"}
+	}
+	if readOnly {
+		return ErrReadOnly
+	}
+	if !encryptionKeyExist || !isEncode(string(readDatabase())) {
+		return &NotFoundError{itemName: "EncryptionKey"}
+	}
+	if err := validateKeyDerivation(c.KeyDerivation); err != nil {
+		return err
+	}
+	data := globalEncoderKey.readAndDecode()
+	globalEncoderKey.upgrade(c.KeyDerivation)
+	encodeAndSave(data)
+	return nil
+}
+
 // GetName returns the name of the database
 //
 // Example:
@@ -224,7 +670,7 @@ func (d *db) GetName() string {
 //		fmt.Println("Table:", table.GetName())
 //	}
 func (d *db) GetTables() []Table {
-	tables := getTables(true)
+	tables := userTables(getTables(true))
 	iTables := make([]Table, len(tables))
 	for i, t := range tables {
 		iTables[i] = &t
@@ -238,23 +684,29 @@ func (d *db) GetTables() []Table {
 //
 //	db.PrintTables()
 func (d *db) PrintTables() {
-	tables := getTables(true)
+	tables := userTables(getTables(true))
 	for _, t := range tables {
 		fmt.Println(t.rawTable)
 	}
 }
 
 // NewTable creates a new table with the specified name and columns
-// Returns the created table interface
+// Returns the created table interface and ErrReadOnly if the database is read-only
 //
 // Example:
 //
 //	columns := []string{"id", "name", "age"}
-//	table := db.NewTable("users", columns)
-func (d *db) NewTable(name string, columns []string) Table {
+//	table, err := db.NewTable("users", columns)
+func (d *db) NewTable(name string, columns []string) (Table, error) {
+	if readOnly {
+		return nil, ErrReadOnly
+	}
+	if isSystemTable(name) {
+		return nil, ErrReservedName
+	}
 	t := &table{name, columns, nil, ""}
 	tb := d.addTable(*t)
-	return tb
+	return tb, nil
 }
 
 // GetTableByName retrieves a table by its name
@@ -267,6 +719,9 @@ func (d *db) NewTable(name string, columns []string) Table {
 //		log.Fatal(err)
 //	}
 func (d *db) GetTableByName(name string) (Table, error) {
+	if isSystemTable(name) {
+		return &table{}, &NotFoundError{itemName: "Table"}
+	}
 	tb, err := getTableByName(name, true)
 	return &tb, err
 }
@@ -277,16 +732,22 @@ func (d *db) GetTableByName(name string) (Table, error) {
 // Example:
 //
 //	key := ForeignKey{
-//		TableName: "orders",
-//		ColumnName: "user_id",
-//		ForeignTableName: "users",
-//		ForeignColumnName: "id",
+//		TableName: "users",
+//		ColumnName: "id",
+//		ForeignTableName: "orders",
+//		ForeignColumnName: "user_id",
 //	}
 //	err := db.AddForeignKey(key)
 //	if err != nil {
 //		log.Fatal(err)
 //	}
 func (d *db) AddForeignKey(key ForeignKey) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	if isSystemTable(key.TableName) || isSystemTable(key.ForeignTableName) {
+		return ErrReservedName
+	}
 	tb, errTb := getTableByName(key.TableName, false)
 	if errTb != nil {
 		return &NotFoundError{itemName: "Table: " + key.TableName}
@@ -296,29 +757,30 @@ func (d *db) AddForeignKey(key ForeignKey) error {
 		return &NotFoundError{itemName: "Table: " + key.ForeignTableName}
 	}
 
-	tbRows := getRows(tb.rawTable)
-	tbfRows := getRows(tbf.rawTable)
-
-	if !slices.Contains(tbRows[0].columns, key.ColumnName) {
+	if !slices.Contains(tb.columns, key.ColumnName) {
 		msg := fmt.Sprintf("Column: %s does not exist in table: %s", key.ColumnName, key.TableName)
 		return &NotFoundError{itemName: msg}
 	}
-	if !slices.Contains(tbfRows[0].columns, key.ForeignColumnName) {
+	if !slices.Contains(tbf.columns, key.ForeignColumnName) {
 		msg := fmt.Sprintf("Column: %s does not exist in table: %s", key.ForeignColumnName, key.ForeignTableName)
 		return &NotFoundError{itemName: msg}
 	}
-	if !isTableInDatabase("Links") {
-		data := string(must(os.ReadFile(dbName)))
-		linkAdded := string(linkTableLayout()) + data
-		errorHandler(os.WriteFile(dbName, []byte(linkAdded), 0666))
-	}
-
-	linkTb, _ := getTableByName("Links", false)
-	err := validateForeignKey(linkTb, key)
-	if err != nil {
+	if err := validateReferentialActions(key); err != nil {
 		return err
 	}
-	linkTb.AddValues(key.TableName, key.ColumnName, key.ForeignTableName, key.ForeignColumnName)
+	keys := getForeignKeys()
+	for _, k := range keys {
+		if sameForeignKey(k, key) {
+			return errors.New("foreign Key already exist")
+		}
+	}
+	if key.OnDelete == "" {
+		key.OnDelete = NoAction
+	}
+	if key.OnUpdate == "" {
+		key.OnUpdate = NoAction
+	}
+	saveForeignKeys(append(keys, key))
 	return nil
 }
 
@@ -328,8 +790,8 @@ func (d *db) AddForeignKey(key ForeignKey) error {
 // Example:
 //
 //	keys := []ForeignKey{
-//		{TableName: "orders", ColumnName: "user_id", ForeignTableName: "users", ForeignColumnName: "id"},
-//		{TableName: "items", ColumnName: "order_id", ForeignTableName: "orders", ForeignColumnName: "id"},
+//		{TableName: "users", ColumnName: "id", ForeignTableName: "orders", ForeignColumnName: "user_id"},
+//		{TableName: "orders", ColumnName: "id", ForeignTableName: "items", ForeignColumnName: "order_id"},
 //	}
 //	err := db.AddForeignKeys(keys)
 //	if err != nil {
@@ -344,17 +806,65 @@ func (d *db) AddForeignKeys(keys []ForeignKey) error {
 	}
 	return nil
 }
+
+// ForeignKeys returns every foreign key relationship of the database
+//
+// Example:
+//
+//	for _, fk := range db.ForeignKeys() {
+//		fmt.Println(fk.TableName, fk.ColumnName, fk.ForeignTableName, fk.ForeignColumnName)
+//	}
+func (d *db) ForeignKeys() []ForeignKey {
+	return getForeignKeys()
+}
+
+// ForeignKeysOf returns the foreign key relationships where the table is the referenced or the referencing table
+// Returns a NotFoundError if the table doesn't exist
+//
+// Example:
+//
+//	keys, err := db.ForeignKeysOf("orders")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) ForeignKeysOf(tableName string) ([]ForeignKey, error) {
+	if !isTableInDatabase(tableName) {
+		return nil, &NotFoundError{itemName: "Table: " + tableName}
+	}
+	var keys []ForeignKey
+	for _, key := range getForeignKeys() {
+		if key.TableName == tableName || key.ForeignTableName == tableName {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// DropForeignKey removes the foreign key relationship between the tables and columns of the key
+// Returns a NotFoundError if the relationship doesn't exist
+//
+// Example:
+//
+//	err := db.DropForeignKey(key)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) DropForeignKey(key ForeignKey) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	keys := getForeignKeys()
+	index := slices.IndexFunc(keys, func(k ForeignKey) bool { return sameForeignKey(k, key) })
+	if index == -1 {
+		return &NotFoundError{itemName: "ForeignKey"}
+	}
+	saveForeignKeys(slices.Delete(keys, index, index+1))
+	return nil
+}
 func (d *db) addTable(table table) Table {
-	data := globalEncoderKey.readAndDecode(dbName)
-	dataByte := []byte(data)
+	data := readPlainDatabase()
 	raw := tableBuilder(table)
-	dataByte = append(dataByte, []byte(raw)...)
-	if encryptionKeyExist {
-		dataEncode := must(globalEncoderKey.Encode(string(dataByte)))
-		errorHandler(os.WriteFile(dbName, []byte(dataEncode), 0666))
-		return must(d.GetTableByName(table.nameRaw))
-	}
-	errorHandler(os.WriteFile(dbName, dataByte, 0666))
+	saveDatabase(data + raw)
 	return must(d.GetTableByName(table.nameRaw))
 
 }
@@ -369,6 +879,12 @@ func (d *db) addTable(table table) Table {
 //		log.Fatal(err)
 //	}
 func (d *db) DeleteTable(tableName string) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	if isSystemTable(tableName) {
+		return &NotFoundError{itemName: tableName}
+	}
 	tables := getTables(true)
 	tableNameRaw := fmt.Sprintf("-----%s-----", tableName)
 	deleted := false
@@ -383,6 +899,9 @@ func (d *db) DeleteTable(tableName string) error {
 		return &NotFoundError{itemName: tableName}
 	}
 	saveTables(tables)
+	updateForeignKeys(func(key ForeignKey) (ForeignKey, bool) {
+		return key, key.TableName != tableName && key.ForeignTableName != tableName
+	})
 	return nil
 }
 
@@ -399,6 +918,125 @@ func (d *db) FromSql(sql string) (SqlRows, error) {
 	return validateSql(*d, sql)
 }
 
+// RotateKey verifies the old key and re-encrypts the database with the new key in a single write,
+// the decrypted content is never written to the storage
+// Encrypted columns are re-encrypted with a key derived from the new key and a new salt
+// Returns an error if the database is not encrypted, read-only or the old key is invalid,
+// and ErrTransactionInProgress during a transaction, since its snapshot is encrypted with the old key
+//
+// Example:
+//
+//	err := db.RotateKey("old-secret", "new-secret")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) RotateKey(oldKey string, newKey string) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	if activeTransaction != nil {
+		return ErrTransactionInProgress
+	}
+	if !encryptionKeyExist && globalColumnCipher == nil {
+		return &NotFoundError{itemName: "EncryptionKey"}
+	}
+	if strings.TrimSpace(newKey) == "" {
+		return errors.New("new encryption key is required")
+	}
+	unlock := lockDatabase()
+	defer unlock()
+	if globalColumnCipher != nil {
+		return rotateColumnKey(oldKey, newKey)
+	}
+	data, err := dbStorage.Read()
+	if err != nil {
+		return err
+	}
+	oldEncoder := newSecureTextEncoder(oldKey, &globalEncoderKey.params)
+	plainText, err := oldEncoder.Decode(string(data))
+	if err != nil {
+		return ErrInvalidKey
+	}
+	previous := globalEncoderKey
+	globalEncoderKey = *newSecureTextEncoder(newKey, &oldEncoder.params)
+	globalEncoderKey.provider = previous.provider
+	_, body := splitFileHeader(plainText)
+	encoded, err := globalEncoderKey.Encode(fileHeader(body) + body)
+	if err == nil {
+		err = dbStorage.Write([]byte(encoded))
+	}
+	if err != nil {
+		globalEncoderKey = previous
+		return err
+	}
+	return nil
+}
+
+// rotateColumnKey re-encrypts the encrypted columns with a new column cipher derived from the new key,
+// the caller holds the storage lock
+// Returns ErrInvalidKey if the old key is not the key of the columns
+func rotateColumnKey(oldKey string, newKey string) error {
+	current := globalColumnCipher
+	if !hmac.Equal([]byte(oldKey), current.secret) {
+		return ErrInvalidKey
+	}
+	data, err := dbStorage.Read()
+	if err != nil {
+		return err
+	}
+	_, body := splitFileHeader(string(data))
+	if body, err = current.decryptCells(body); err != nil {
+		return err
+	}
+	next, err := newColumnCipher(newKey, &current.params, current.columns, "")
+	if err != nil {
+		return err
+	}
+	next.provider = current.provider
+	if body, err = next.encryptCells(body); err != nil {
+		return err
+	}
+	if err = dbStorage.Write([]byte(buildFileHeader(body, next, deriveChecksumKey(next.key)) + body)); err != nil {
+		return err
+	}
+	globalColumnCipher = next
+	return nil
+}
+
+// RefreshKey obtains the key again from the KeyProvider used to open the database
+// and re-encrypts the database with it if it is different from the current key
+// Returns an error if the database has no key provider or the key can't be obtained,
+// and ErrTransactionInProgress during a transaction
+//
+// Example:
+//
+//	err := db.RefreshKey()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) RefreshKey() error {
+	if activeTransaction != nil {
+		return ErrTransactionInProgress
+	}
+	provider, oldKey := globalEncoderKey.provider, string(globalEncoderKey.secret)
+	if globalColumnCipher != nil {
+		provider, oldKey = globalColumnCipher.provider, string(globalColumnCipher.secret)
+	} else if !encryptionKeyExist {
+		provider = nil
+	}
+	if provider == nil {
+		return &NotFoundError{itemName: "KeyProvider"}
+	}
+	newKey, err := provider.Key()
+	if err != nil {
+		return err
+	}
+	if newKey == oldKey {
+		return nil
+	}
+	return d.RotateKey(oldKey, newKey)
+}
+
 // getTableByName retrieves a table by its name from the database
 // tableName: name of the table to retrieve
 // strConv: flag to indicate if string conversion should be applied
@@ -420,8 +1058,11 @@ func getTableByName(tableName string, strConv bool) (table, error) {
 // strConv: flag to indicate if string conversion should be applied
 // Returns a slice of all tables in the database
 func getTables(strConv bool) []table {
-	data := globalEncoderKey.readAndDecode(dbName)
+	data := globalEncoderKey.readAndDecode()
 	data = strings.ReplaceAll(data, "\r", "")
+	_, data = splitFileHeader(data)
+	// cells that can't be decrypted were reported when the database was opened, they are read as stored
+	data, _ = decryptColumns(data)
 	if strConv {
 		data = strings.ReplaceAll(data, "U+0020", " ")
 	}
@@ -436,6 +1077,40 @@ func getTables(strConv bool) []table {
 	return tables
 }
 
+// splitFileHeader separates the plaintext header lines at the beginning of the database content
+// data: database content
+// Returns the header and the content without it
+func splitFileHeader(data string) (string, string) {
+	var header strings.Builder
+	for strings.HasPrefix(data, formatHeaderPrefix) || strings.HasPrefix(data, columnKeyHeaderPrefix) ||
+		strings.HasPrefix(data, checksumHeaderPrefix) {
+		line, rest, _ := strings.Cut(data, "\n")
+		header.WriteString(line + "\n")
+		data = rest
+	}
+	return header.String(), data
+}
+
+// fileHeader builds the header of the current database for the given body
+// body: database content without header, as it is stored
+// Returns the header lines
+func fileHeader(body string) string {
+	return buildFileHeader(body, globalColumnCipher, checksumKey())
+}
+
+// buildFileHeader builds the header lines for the given body
+// body: database content without header, as it is stored
+// columnCipher: cipher of the encrypted columns, nil if the columns are not encrypted
+// checksumKey: HMAC key of the checksums, nil for plain SHA-256 checksums
+// Returns the header lines
+func buildFileHeader(body string, columnCipher *columnCipher, checksumKey []byte) string {
+	header := formatHeader()
+	if columnCipher != nil {
+		header += columnCipher.header()
+	}
+	return header + checksumHeader(body, checksumKey)
+}
+
 // tableBuilder constructs a string representation of a table
 // table: the table structure to build
 // Returns the string representation of the table
@@ -541,7 +1216,7 @@ func checkDataConfig(d []DataConfig) error {
 			ch <- err
 		}(v)
 		if err := <-ch; err != nil {
-			*errs = append(*errs, <-ch)
+			*errs = append(*errs, err)
 		}
 	}
 	close(ch)
@@ -562,6 +1237,9 @@ func checkDataConfig(d []DataConfig) error {
 // d: data configuration to validate
 // Returns an error if requirements are not met
 func validateDataRequirement(d DataConfig) error {
+	if isSystemTable(d.TableName) {
+		return ErrReservedName
+	}
 	for _, v := range d.Values {
 
 		if d.TableName == "" {
@@ -599,7 +1277,7 @@ func addStaticData(db db, v DataConfig) {
 	tb, _ := db.GetTableByName(v.TableName)
 	for _, iv := range v.Values {
 		if !areValuesInDatabase(v.TableName, iv[0]) {
-			tb.addValuesIdGenerationOff(iv)
+			_ = tb.addValuesIdGenerationOff(iv)
 		}
 	}
 }
@@ -608,10 +1286,10 @@ func addStaticData(db db, v DataConfig) {
 // db: database instance
 // v: data configuration for table creation and data
 func generateStaticData(db db, v DataConfig) {
-	tb := db.NewTable(v.TableName, v.Columns)
+	tb := must(db.NewTable(v.TableName, v.Columns))
 	if v.Values != nil || len(v.Values) != 0 {
 		for _, iv := range v.Values {
-			tb.addValuesIdGenerationOff(iv)
+			_ = tb.addValuesIdGenerationOff(iv)
 		}
 	}
 }
@@ -619,11 +1297,7 @@ func generateStaticData(db db, v DataConfig) {
 // setDefaultData initializes the database with default data structure
 // c: database configuration
 func setDefaultData(c DbConfig) {
-	if encryptionKeyExist {
-		encodeAndSave(string(getLayout()))
-	} else {
-		errorHandler(os.WriteFile(c.DatabaseName, getLayout(), 0644))
-	}
+	saveDatabase(string(getLayout()))
 }
 
 // isTableInDatabase checks if a table exists in the database
@@ -675,31 +1349,3 @@ func getLayout() []byte {
 ////`
 	return []byte(layout)
 }
-
-// validateForeignKey checks if a foreign key relationship is valid
-// linkTb: link table containing relationships
-// key: foreign key relationship to validate
-// Returns error if relationship is invalid or already exists
-func validateForeignKey(linkTb table, key ForeignKey) error {
-	linkRows := getRows(linkTb.rawTable)
-	for i := 1; i < len(linkRows); i++ {
-		s := strings.Split(linkRows[i].value, "|")
-		s = removeEmptyIndex(s)
-		if strings.TrimSpace(s[3]) == key.TableName &&
-			strings.TrimSpace(s[5]) == key.ColumnName &&
-			strings.TrimSpace(s[7]) == key.ForeignTableName &&
-			strings.TrimSpace(s[9]) == key.ForeignColumnName {
-			return errors.New("foreign Key already exist")
-		}
-	}
-	return nil
-}
-func linkTableLayout() []byte {
-	layout := `////
------Links-----
-[1] id [2] table1 [3] columnLink1 [3] table2 [4] columnLink2
-!*!
------Links_End-----
-////`
-	return []byte(layout)
-}
@@ -1,40 +1,82 @@
 package tdb
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
 	"io"
-	"os"
 	"strings"
 )
 
+// KeyDerivation defines the scrypt work factor used to derive the encryption key from the passphrase.
+type KeyDerivation struct {
+	N int // CPU/memory cost, must be a power of two greater than 1
+	R int // Block size
+	P int // Parallelization
+}
+
 type secureTextEncoder struct {
-	key []byte
+	secret    []byte
+	legacyKey []byte
+	key       []byte
+	salt      []byte
+	params    KeyDerivation
+	legacy    bool
+	provider  KeyProvider
 }
 
 var globalEncoderKey secureTextEncoder
 
+// defaultKeyDerivation is the work factor used when DbConfig.KeyDerivation is not set
+var defaultKeyDerivation = KeyDerivation{N: 32768, R: 8, P: 1}
+
+const (
+	kdfHeaderPrefix = "KDF "
+	kdfHeaderFormat = "KDF scrypt N=%d r=%d p=%d salt=%s\n"
+	saltSize        = 16
+)
+
+// Upper limits of the work factor, they keep a crafted header from making the key derivation
+// allocate more than 256 MiB or run for minutes. scrypt uses 128·N·r bytes and mixes N·r·p blocks.
+const (
+	maxKeyDerivationMemory = 256 << 20
+	maxKeyDerivationWork   = 1 << 24
+)
+
 // newSecureTextEncoder creates a new secureTextEncoder instance with the provided secret key.
-// The secret key is hashed using SHA-256 to create the encryption key.
-func newSecureTextEncoder(secretKey string) *secureTextEncoder {
+// The encryption key is derived with scrypt and a random per-file salt, the legacy SHA-256 key
+// is kept to read databases written by older versions.
+func newSecureTextEncoder(secretKey string, kdf *KeyDerivation) *secureTextEncoder {
 	hasher := sha256.New()
 	hasher.Write([]byte(secretKey))
-	key := hasher.Sum(nil)
+	legacyKey := hasher.Sum(nil)
 
+	params := defaultKeyDerivation
+	if kdf != nil {
+		params = *kdf
+	}
 	return &secureTextEncoder{
-		key: key,
+		secret:    []byte(secretKey),
+		legacyKey: legacyKey,
+		params:    params,
 	}
 }
 
 // Encode encrypts the plain text using AES-GCM encryption and returns the base64-encoded result
-// with "ENG" prefix. Returns error if encryption fails.
+// with "ENG" prefix, preceded by the key derivation header. Returns error if encryption fails.
 func (e *secureTextEncoder) Encode(plainText string) (string, error) {
+	key, err := e.encodeKey()
+	if err != nil {
+		return "", err
+	}
 	// Create cipher block
-	block, err := aes.NewCipher(e.key)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
@@ -59,20 +101,30 @@ func (e *secureTextEncoder) Encode(plainText string) (string, error) {
 	b64 := base64.StdEncoding.EncodeToString(ciphertext)
 	//Add Prefix
 	prefix := "ENG" + b64
-	return prefix, nil
+	if e.legacy {
+		return prefix, nil
+	}
+	header := fmt.Sprintf(kdfHeaderFormat, e.params.N, e.params.R, e.params.P, base64.StdEncoding.EncodeToString(e.salt))
+	return header + prefix, nil
 
 }
 
 // Decode decrypts the encoded text (with "ENG" prefix removed) using AES-GCM decryption.
-// Returns the original plain text or error if decryption fails.
+// The key is derived from the header parameters, text without header is decoded with the legacy key.
+// Returns the original plain text, ErrInvalidKey if the key does not decrypt the text,
+// or a CorruptionError if the encrypted content is damaged.
 func (e *secureTextEncoder) Decode(encodedText string) (string, error) {
+	encodedText, key, err := e.decodeKey(encodedText)
+	if err != nil {
+		return "", &CorruptionError{reason: err.Error()}
+	}
 	encodedText = strings.Replace(encodedText, "ENG", "", 1)
 	ciphertext, err := base64.StdEncoding.DecodeString(encodedText)
 	if err != nil {
-		return "", err
+		return "", &CorruptionError{reason: "invalid encrypted content"}
 	}
 
-	block, err := aes.NewCipher(e.key)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
@@ -83,48 +135,175 @@ func (e *secureTextEncoder) Decode(encodedText string) (string, error) {
 	}
 
 	if len(ciphertext) < gcm.NonceSize() {
-		return "", errors.New("ciphertext too short")
+		return "", &CorruptionError{reason: "ciphertext too short"}
 	}
 
 	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
 
 	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return "", err
+		return "", ErrInvalidKey
 	}
 
 	return string(plaintext), nil
 }
 
+// encodeKey returns the key used to encode, generating the salt of a new file if needed.
+func (e *secureTextEncoder) encodeKey() ([]byte, error) {
+	if e.legacy {
+		return e.legacyKey, nil
+	}
+	if e.salt == nil {
+		e.salt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, e.salt); err != nil {
+			return nil, err
+		}
+		e.key = nil
+	}
+	if e.key == nil {
+		key, err := scrypt.Key(e.secret, e.salt, e.params.N, e.params.R, e.params.P, 32)
+		if err != nil {
+			return nil, err
+		}
+		e.key = key
+	}
+	return e.key, nil
+}
+
+// decodeKey strips the key derivation header from the encoded text and returns the key to decode it.
+// The salt and parameters of the file are kept so that following writes reuse them.
+func (e *secureTextEncoder) decodeKey(encodedText string) (string, []byte, error) {
+	if !strings.HasPrefix(encodedText, kdfHeaderPrefix) {
+		e.legacy = true
+		return encodedText, e.legacyKey, nil
+	}
+	header, payload, found := strings.Cut(encodedText, "\n")
+	if !found {
+		return "", nil, errors.New("invalid key derivation header")
+	}
+	var params KeyDerivation
+	var saltB64 string
+	_, err := fmt.Sscanf(header, strings.TrimSuffix(kdfHeaderFormat, "\n"), &params.N, &params.R, &params.P, &saltB64)
+	if err != nil {
+		return "", nil, errors.New("invalid key derivation header")
+	}
+	if err = validateKeyDerivation(&params); err != nil {
+		return "", nil, fmt.Errorf("invalid key derivation header: %w", err)
+	}
+	salt, err := base64.StdEncoding.DecodeString(saltB64)
+	if err != nil {
+		return "", nil, err
+	}
+	e.legacy = false
+	if !bytes.Equal(salt, e.salt) || params != e.params || e.key == nil {
+		e.salt = salt
+		e.params = params
+		e.key = nil
+	}
+	key, err := e.encodeKey()
+	return payload, key, err
+}
+
+// upgrade discards the current salt so the next Encode uses a fresh salt and the given work factor.
+func (e *secureTextEncoder) upgrade(kdf *KeyDerivation) {
+	e.legacy = false
+	e.salt = nil
+	e.key = nil
+	e.params = defaultKeyDerivation
+	if kdf != nil {
+		e.params = *kdf
+	}
+}
+
+// readPlainDatabase reads the content of the database with the file and the encrypted columns decrypted,
+// the way saveDatabase expects it
+func readPlainDatabase() string {
+	data, _ := decryptColumns(globalEncoderKey.readAndDecode())
+	return data
+}
+
 // readAndDecode reads the content of the database file and decodes it if encryption is enabled.
 // Returns the decoded content as a string.
-func (e *secureTextEncoder) readAndDecode(dbName string) string {
-	data := string(must(os.ReadFile(dbName)))
+func (e *secureTextEncoder) readAndDecode() string {
+	data := string(readDatabase())
 	if encryptionKeyExist {
 		data = must(e.Decode(data))
 	}
 	return data
 }
 
-// isEncode checks if the given text is encoded by verifying if it starts with "ENG" prefix.
+// IsEncrypted reports whether the database stored in storage is encrypted as a whole file,
+// the storage is only read so the key is not needed
+//
+// Example:
+//
+//	encrypted, err := tdb.IsEncrypted(tdb.NewFileStorage("mydb.txt"))
+func IsEncrypted(storage Storage) (bool, error) {
+	if !storage.Exists() {
+		return false, &NotFoundError{itemName: "Database"}
+	}
+	data, err := storage.Read()
+	if err != nil {
+		return false, err
+	}
+	return isEncode(string(data)), nil
+}
+
+// isEncode checks if the given text is encoded by verifying if it starts with "ENG" prefix
+// or with the key derivation header.
 // Returns true if the text is encoded, false otherwise.
 func isEncode(text string) bool {
-	if strings.HasPrefix(text, "ENG") {
+	if strings.HasPrefix(text, "ENG") || strings.HasPrefix(text, kdfHeaderPrefix) {
 		return true
 	}
 	return false
 }
 
+// validateKeyDerivation checks if the scrypt parameters are valid
+// kdf: key derivation parameters to validate, nil uses the defaults
+// Returns an error if the parameters are invalid
+func validateKeyDerivation(kdf *KeyDerivation) error {
+	if kdf == nil {
+		return nil
+	}
+	if kdf.N <= 1 || kdf.N&(kdf.N-1) != 0 {
+		return errors.New("key derivation N must be a power of two greater than 1")
+	}
+	if kdf.R <= 0 || kdf.P <= 0 {
+		return errors.New("key derivation R and P must be greater than 0")
+	}
+	if kdf.R > maxKeyDerivationMemory/128 || kdf.N > maxKeyDerivationMemory/(128*kdf.R) {
+		return fmt.Errorf("key derivation exceeds the memory limit, 128·N·r must be at most %d bytes", maxKeyDerivationMemory)
+	}
+	if kdf.P > maxKeyDerivationWork/(kdf.N*kdf.R) {
+		return fmt.Errorf("key derivation exceeds the work limit, N·r·p must be at most %d", maxKeyDerivationWork)
+	}
+	return nil
+}
+
 // encodeAndSave encrypts the provided data using the global encoder key and saves it to the database file.
 // Panics if encryption or file writing fails.
 func encodeAndSave(data string) {
 	encodeData := must(globalEncoderKey.Encode(data))
-	errorHandler(os.WriteFile(dbName, []byte(encodeData), 0644))
+	writeDatabase([]byte(encodeData))
+}
+
+// saveDatabase writes the database content with the current header, encrypting the encrypted columns
+// and the whole file if encryption is enabled. The checksums are computed on the stored content.
+func saveDatabase(data string) {
+	_, body := splitFileHeader(data)
+	body = must(encryptColumns(body))
+	data = fileHeader(body) + body
+	if encryptionKeyExist {
+		encodeAndSave(data)
+		return
+	}
+	writeDatabase([]byte(data))
 }
 
 // decodeAndSave decrypts the provided data using the global encoder key and saves it to the database file.
 // Panics if decryption or file writing fails.
 func decodeAndSave(data string) {
 	decodeData := must(globalEncoderKey.Decode(data))
-	errorHandler(os.WriteFile(dbName, []byte(decodeData), 0644))
+	writeDatabase([]byte(decodeData))
 }
@@ -1,9 +1,47 @@
 package tdb
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
+// ErrReadOnly is returned when trying to modify a database that can't be written.
+var ErrReadOnly = errors.New("database is read-only")
+
+// ErrAlreadyExists is returned when creating a database that already exists.
+var ErrAlreadyExists = errors.New("database already exists")
+
+// ErrInvalidKey is returned when an encryption key can't decrypt the database.
+var ErrInvalidKey = errors.New("invalid encryption key")
+
+// ErrUnsupportedFormat is returned when opening a database written in a newer format than this version can read.
+var ErrUnsupportedFormat = errors.New("database format is not supported")
+
+// ErrTableExists is returned when creating a table with the name of an existing table.
+var ErrTableExists = errors.New("table already exists")
+
+// ErrReservedName is returned when a table name uses the prefix reserved for the system catalog.
+var ErrReservedName = errors.New("table names starting with __tdb_ are reserved")
+
+// ErrIrreversibleMigration is returned when rolling back a migration that has no Down function.
+var ErrIrreversibleMigration = errors.New("migration is irreversible")
+
+// ErrMigrationChecksumMismatch is returned when an applied migration was modified after it was applied.
+var ErrMigrationChecksumMismatch = errors.New("applied migration was modified")
+
+// ErrTransactionInProgress is returned when starting a transaction while another one is in progress.
+var ErrTransactionInProgress = errors.New("transaction already in progress")
+
+// ErrNoTransaction is returned when committing or rolling back without a transaction in progress.
+var ErrNoTransaction = errors.New("no transaction in progress")
+
+// ErrStreamNotTruncatable is returned when a StreamStorage without a Truncate method would leave old content after the new one.
+var ErrStreamNotTruncatable = errors.New("stream can't be truncated")
+
+// ErrEncryptedColumn is returned when renaming an encrypted column or a table with encrypted columns.
+var ErrEncryptedColumn = errors.New("encrypted columns can't be renamed")
+
 // NotFoundError represents an error when a requested item cannot be found in the database.
 type NotFoundError struct {
 	itemName string
@@ -23,3 +61,85 @@ type SqlSyntaxError struct {
 func (e *SqlSyntaxError) Error() string {
 	return fmt.Sprintf("Sql Syntax Error, not found %s", e.itemName)
 }
+
+// CorruptionError represents a damaged database, such as a table with mismatched markers or a checksum mismatch.
+type CorruptionError struct {
+	tableName string
+	reason    string
+}
+
+// Error returns a formatted error message indicating the damaged table and the reason.
+func (e *CorruptionError) Error() string {
+	if e.tableName == "" {
+		return fmt.Sprintf("database corrupted: %s", e.reason)
+	}
+	return fmt.Sprintf("table %s corrupted: %s", e.tableName, e.reason)
+}
+
+// TableName returns the name of the damaged table, or an empty string if the damage is not in a table.
+func (e *CorruptionError) TableName() string {
+	return e.tableName
+}
+
+// ForeignKeyViolationError represents a write that would break a foreign key relationship,
+// such as a value referencing a missing row or a deleted row that is still referenced.
+type ForeignKeyViolationError struct {
+	key        ForeignKey
+	value      string
+	referenced bool
+}
+
+// Error returns a formatted error message indicating the foreign key and the value that breaks it.
+func (e *ForeignKeyViolationError) Error() string {
+	if e.referenced {
+		return fmt.Sprintf("foreign key violation: %s.%s value %s is referenced by %s.%s",
+			e.key.TableName, e.key.ColumnName, e.value, e.key.ForeignTableName, e.key.ForeignColumnName)
+	}
+	return fmt.Sprintf("foreign key violation: %s.%s value %s not found in %s.%s",
+		e.key.ForeignTableName, e.key.ForeignColumnName, e.value, e.key.TableName, e.key.ColumnName)
+}
+
+// ForeignKey returns the foreign key relationship that was violated.
+func (e *ForeignKeyViolationError) ForeignKey() ForeignKey {
+	return e.key
+}
+
+// Value returns the value that breaks the relationship.
+func (e *ForeignKeyViolationError) Value() string {
+	return e.value
+}
+
+// ImportError lists the lines of a file that ImportCSV or ImportNDJSON skipped, the other lines were imported.
+type ImportError struct {
+	lines []LineError
+}
+
+// Error returns a formatted error message with the number of skipped lines and their errors.
+func (e *ImportError) Error() string {
+	messages := make([]string, len(e.lines))
+	for i, line := range e.lines {
+		messages[i] = line.Error()
+	}
+	return fmt.Sprintf("%d lines not imported: %s", len(e.lines), strings.Join(messages, "; "))
+}
+
+// Lines returns the error of every skipped line, in the order of the file.
+func (e *ImportError) Lines() []LineError {
+	return e.lines
+}
+
+// LineError is the reason a line of an imported file was not imported.
+type LineError struct {
+	Line int   // Line of the file where the record starts
+	Err  error // Reason the record was not imported
+}
+
+// Error returns a formatted error message with the line and the reason.
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Unwrap returns the reason the record was not imported.
+func (e LineError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,398 @@
+package tdb
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ReferentialAction defines what happens to the referencing rows when a referenced row is deleted or updated.
+type ReferentialAction string
+
+const (
+	NoAction   ReferentialAction = "NO ACTION"   // Refuse the change, the check is deferred with DeferForeignKeys
+	Restrict   ReferentialAction = "RESTRICT"    // Refuse the change, the check is never deferred
+	Cascade    ReferentialAction = "CASCADE"     // Delete or update the referencing rows too
+	SetNull    ReferentialAction = "SET NULL"    // Set the referencing column to null
+	SetDefault ReferentialAction = "SET DEFAULT" // Set the referencing column to ForeignKey.DefaultValue
+)
+
+var deferForeignKeys bool
+
+const legacyLinkTableName = "Links"
+
+// legacyLinkHeader is the column header of the Links table written by older versions, it numbers two columns [3]
+const legacyLinkHeader = "[1] id [2] table1 [3] columnLink1 [3] table2 [4] columnLink2"
+
+var linkTableColumns = []string{"table1", "columnLink1", "table2", "columnLink2", "onDelete", "onUpdate", "defaultValue"}
+
+// referentialChange applies the referential actions of a delete or update,
+// it remembers the visited rows so cyclic relationships are applied only once.
+type referentialChange struct {
+	visited map[string]bool
+	cascade bool
+	changed bool
+}
+
+// getForeignKeys returns every foreign key stored in the system catalog
+func getForeignKeys() []ForeignKey {
+	link, ok := linkTable(getTables(false))
+	if !ok {
+		return nil
+	}
+	keys := make([]ForeignKey, 0, len(link.values))
+	for _, row := range link.values {
+		keys = append(keys, ForeignKey{
+			TableName:         row.SearchValue("table1"),
+			ColumnName:        row.SearchValue("columnLink1"),
+			ForeignTableName:  row.SearchValue("table2"),
+			ForeignColumnName: row.SearchValue("columnLink2"),
+			OnDelete:          parseReferentialAction(row.SearchValue("onDelete")),
+			OnUpdate:          parseReferentialAction(row.SearchValue("onUpdate")),
+			DefaultValue:      decodeSystemValue(row.SearchValue("defaultValue")),
+		})
+	}
+	return keys
+}
+
+// linkTable returns the table that stores the foreign keys,
+// the Links table of databases written before the system catalog existed is used when there is no catalog
+func linkTable(tables []table) (table, bool) {
+	var legacy table
+	found := false
+	for _, t := range tables {
+		switch t.getSimpleName() {
+		case metaTableName:
+			return t, true
+		case legacyLinkTableName:
+			if slices.Contains(t.columns, "table1") && slices.Contains(t.columns, "columnLink2") {
+				legacy, found = t, true
+			}
+		}
+	}
+	return legacy, found
+}
+
+// saveForeignKeys writes the foreign keys as the content of the system catalog,
+// the catalog is removed when there is no foreign key left
+// A legacy Links table is replaced by the catalog
+func saveForeignKeys(keys []ForeignKey) {
+	tables := getTables(false)
+	if legacy, ok := linkTable(tables); ok && legacy.getSimpleName() == legacyLinkTableName {
+		tables = slices.DeleteFunc(tables, func(t table) bool { return t.nameRaw == legacy.nameRaw })
+	}
+	rows := make([][]string, len(keys))
+	for i, key := range keys {
+		rows[i] = []string{key.TableName, key.ColumnName, key.ForeignTableName, key.ForeignColumnName,
+			string(key.OnDelete), string(key.OnUpdate), key.DefaultValue}
+	}
+	saveTables(setSystemTable(tables, metaTableName, linkTableColumns, rows))
+}
+
+// upgradeLinkTable moves the foreign keys of a legacy Links table into the system catalog
+func upgradeLinkTable() {
+	if link, ok := linkTable(getTables(false)); ok && link.getSimpleName() == legacyLinkTableName {
+		saveForeignKeys(getForeignKeys())
+	}
+}
+
+// updateForeignKeys applies fn to every foreign key and saves the result when it changed
+// fn returns the updated key and false if the key must be removed
+func updateForeignKeys(fn func(key ForeignKey) (ForeignKey, bool)) {
+	keys := getForeignKeys()
+	updated := make([]ForeignKey, 0, len(keys))
+	for _, key := range keys {
+		if newKey, keep := fn(key); keep {
+			updated = append(updated, newKey)
+		}
+	}
+	if !slices.Equal(keys, updated) {
+		saveForeignKeys(updated)
+	}
+}
+
+// sameForeignKey reports whether both keys link the same tables and columns
+func sameForeignKey(a ForeignKey, b ForeignKey) bool {
+	return a.TableName == b.TableName && a.ColumnName == b.ColumnName &&
+		a.ForeignTableName == b.ForeignTableName && a.ForeignColumnName == b.ForeignColumnName
+}
+
+// foreignKeyChecksDeferred reports whether the checks are postponed until the transaction is committed
+func foreignKeyChecksDeferred() bool {
+	return deferForeignKeys && activeTransaction != nil
+}
+
+// checkForeignKeys verifies that every value of the row that references another table exists in it
+// tableName: name of the table that holds the row
+// row: row about to be written
+// Returns a ForeignKeyViolationError if a referenced value does not exist
+func checkForeignKeys(tableName string, row Row) error {
+	if foreignKeyChecksDeferred() {
+		return nil
+	}
+	for _, key := range getForeignKeys() {
+		if key.ForeignTableName != tableName {
+			continue
+		}
+		if err := checkReference(key, row.SearchValue(key.ForeignColumnName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkInsertedRow verifies the foreign keys of a row inserted by a multi-row statement,
+// a reference to a row inserted before it by the same statement is valid
+// keys: foreign keys of the database
+// previous: rows inserted before the row by the statement
+func checkInsertedRow(tableName string, keys []ForeignKey, row Row, previous []Row) error {
+	if foreignKeyChecksDeferred() {
+		return nil
+	}
+	for _, key := range keys {
+		if key.ForeignTableName != tableName {
+			continue
+		}
+		value := row.SearchValue(key.ForeignColumnName)
+		if key.TableName == tableName && slices.ContainsFunc(previous, func(r Row) bool { return r.SearchValue(key.ColumnName) == value }) {
+			continue
+		}
+		if err := checkReference(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReference verifies that the value exists in the referenced column of the key
+// Null and empty values don't reference any row
+func checkReference(key ForeignKey, value string) error {
+	if value == "" || value == "null" {
+		return nil
+	}
+	value = strings.ReplaceAll(value, " ", "U+0020")
+	parent, err := getTableByName(key.TableName, false)
+	if err != nil || len(searchAll(parent, key.ColumnName, value)) == 0 {
+		return &ForeignKeyViolationError{key: key, value: value}
+	}
+	return nil
+}
+
+// checkAllForeignKeys verifies every reference stored in the database
+// Returns the first ForeignKeyViolationError found
+func checkAllForeignKeys() error {
+	for _, key := range getForeignKeys() {
+		child, err := getTableByName(key.ForeignTableName, false)
+		if err != nil {
+			continue
+		}
+		for _, row := range child.values {
+			if err := checkReference(key, row.SearchValue(key.ForeignColumnName)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newReferentialChange creates the change of a single delete or update
+// cascade: every relationship is applied as Cascade, whatever its action
+func newReferentialChange(cascade bool) *referentialChange {
+	return &referentialChange{visited: map[string]bool{}, cascade: cascade}
+}
+
+// visit marks the row column as visited, it returns false if it was already visited
+func (c *referentialChange) visit(tableName string, id string, column string) bool {
+	key := tableName + "\x00" + id + "\x00" + column
+	if c.visited[key] {
+		return false
+	}
+	c.visited[key] = true
+	return true
+}
+
+// onDelete applies the ON DELETE action of every key that references the row, before the row is deleted
+// tableName: name of the table that holds the row
+// row: row about to be deleted
+// Returns a ForeignKeyViolationError if an action refuses the delete
+func (c *referentialChange) onDelete(tableName string, row Row) error {
+	c.visit(tableName, row.SearchValue("id"), "")
+	for _, key := range getForeignKeys() {
+		if key.TableName != tableName {
+			continue
+		}
+		action := key.OnDelete
+		if c.cascade {
+			action = Cascade
+		}
+		rows := referencingRows(key, row)
+		if len(rows) == 0 {
+			continue
+		}
+		switch action {
+		case Cascade:
+			for _, r := range rows {
+				id := r.SearchValue("id")
+				if !c.visit(key.ForeignTableName, id, "") {
+					continue
+				}
+				if err := c.onDelete(key.ForeignTableName, r); err != nil {
+					return err
+				}
+				child, _ := getTableByName(key.ForeignTableName, false)
+				if _, err := deleteRow(child, id); err != nil {
+					return err
+				}
+				c.changed = true
+			}
+		case SetNull, SetDefault:
+			if err := c.setReferences(key, action, rows, row.SearchValue(key.ColumnName)); err != nil {
+				return err
+			}
+		default:
+			if err := refuseChange(key, action, row.SearchValue(key.ColumnName)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// onUpdate applies the ON UPDATE action of every key that references the column of the row, before it is updated
+// tableName: name of the table that holds the row
+// row: row before the update
+// column: updated column
+// newValue: new value of the column
+// Returns a ForeignKeyViolationError if an action refuses the update
+func (c *referentialChange) onUpdate(tableName string, row Row, column string, newValue string) error {
+	c.visit(tableName, row.SearchValue("id"), column)
+	for _, key := range getForeignKeys() {
+		if key.TableName != tableName || key.ColumnName != column {
+			continue
+		}
+		rows := referencingRows(key, row)
+		if len(rows) == 0 {
+			continue
+		}
+		switch key.OnUpdate {
+		case Cascade:
+			for _, r := range rows {
+				if err := c.update(key.ForeignTableName, r, key.ForeignColumnName, newValue); err != nil {
+					return err
+				}
+			}
+		case SetNull, SetDefault:
+			if err := c.setReferences(key, key.OnUpdate, rows, row.SearchValue(key.ColumnName)); err != nil {
+				return err
+			}
+		default:
+			if err := refuseChange(key, key.OnUpdate, row.SearchValue(key.ColumnName)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setReferences writes null or the default value of the key in the referencing rows
+// oldValue: referenced value being deleted or updated, the default value can't be it
+func (c *referentialChange) setReferences(key ForeignKey, action ReferentialAction, rows Rows, oldValue string) error {
+	value := "null"
+	if action == SetDefault && key.DefaultValue != "" {
+		value = strings.ReplaceAll(key.DefaultValue, " ", "U+0020")
+		if value == oldValue {
+			return &ForeignKeyViolationError{key: key, value: value, referenced: true}
+		}
+		if err := checkReference(key, value); err != nil {
+			return err
+		}
+	}
+	for _, r := range rows {
+		if err := c.update(key.ForeignTableName, r, key.ForeignColumnName, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update writes the value in the column of the row, after applying the actions of the keys that reference it
+func (c *referentialChange) update(tableName string, row Row, column string, value string) error {
+	id := row.SearchValue("id")
+	if row.SearchValue(column) == value || c.visited[tableName+"\x00"+id+"\x00"] || !c.visit(tableName, id, column) {
+		return nil
+	}
+	if err := c.onUpdate(tableName, row, column, value); err != nil {
+		return err
+	}
+	tb, err := getTableByName(tableName, false)
+	if err != nil {
+		return err
+	}
+	if _, err = setRowValue(tb, column, id, value); err != nil {
+		return err
+	}
+	c.changed = true
+	return nil
+}
+
+// refuseChange returns the violation of a NO ACTION or RESTRICT key,
+// NO ACTION is not refused while the foreign key checks are deferred
+func refuseChange(key ForeignKey, action ReferentialAction, value string) error {
+	if action != Restrict && foreignKeyChecksDeferred() {
+		return nil
+	}
+	return &ForeignKeyViolationError{key: key, value: value, referenced: true}
+}
+
+// referencingRows returns the rows that reference the row through the key, without the row itself
+func referencingRows(key ForeignKey, row Row) Rows {
+	value := row.SearchValue(key.ColumnName)
+	if value == "" || value == "null" {
+		return nil
+	}
+	child, err := getTableByName(key.ForeignTableName, false)
+	if err != nil {
+		return nil
+	}
+	var rows Rows
+	for _, r := range searchAll(child, key.ForeignColumnName, value) {
+		if key.ForeignTableName == key.TableName && r.SearchValue("id") == row.SearchValue("id") {
+			continue
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// applyReferentialChange runs fn and restores the database if it fails,
+// so a refused action never leaves part of the change written
+// Multi-row statements run every row inside a single call, so the whole statement is restored
+func applyReferentialChange(fn func() error) error {
+	snapshot := readDatabase()
+	if err := fn(); err != nil {
+		writeDatabase(snapshot)
+		return err
+	}
+	return nil
+}
+
+// parseReferentialAction reads an action stored in the Links table, a missing action is NoAction
+func parseReferentialAction(value string) ReferentialAction {
+	action := ReferentialAction(decodeSystemValue(value))
+	if action == "" {
+		return NoAction
+	}
+	return action
+}
+
+// validateReferentialActions checks the actions of the key
+func validateReferentialActions(key ForeignKey) error {
+	for _, action := range []ReferentialAction{key.OnDelete, key.OnUpdate} {
+		switch action {
+		case "", NoAction, Restrict, Cascade, SetNull, SetDefault:
+		default:
+			return errors.New("invalid referential action: " + string(action))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package tdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CurrentFormatVersion is the version of the file format written by this version of the library.
+// Increase it when the format changes, and teach Upgrade how to convert the files of the previous versions.
+const CurrentFormatVersion = 1
+
+const (
+	formatHeaderPrefix = "FMT "
+	formatHeaderFormat = "FMT %d\n"
+)
+
+// formatHeader returns the header line with the current format version
+func formatHeader() string {
+	return fmt.Sprintf(formatHeaderFormat, CurrentFormatVersion)
+}
+
+// storedFormatVersion returns the format version of the header lines, databases written before
+// the format header existed are version 0
+// Returns a CorruptionError if the format header is invalid
+func storedFormatVersion(header string) (int, error) {
+	for _, line := range strings.Split(header, "\n") {
+		if !strings.HasPrefix(line, formatHeaderPrefix) {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(line, formatHeaderPrefix))
+		if err != nil || version < 1 {
+			return 0, &CorruptionError{reason: "invalid format header"}
+		}
+		return version, nil
+	}
+	return 0, nil
+}
+
+// checkFormatVersion verifies that the database can be read by this version of the library
+// Returns ErrUnsupportedFormat if the database was written in a newer format
+func checkFormatVersion(header string) error {
+	version, err := storedFormatVersion(header)
+	if err != nil {
+		return err
+	}
+	if version > CurrentFormatVersion {
+		return fmt.Errorf("%w: version %d, the latest supported version is %d", ErrUnsupportedFormat, version, CurrentFormatVersion)
+	}
+	return nil
+}
+
+// FormatVersion returns the version of the file format of the stored database,
+// 0 for databases written before the format header existed
+//
+// Example:
+//
+//	if db.FormatVersion() < tdb.CurrentFormatVersion {
+//		err := db.Upgrade()
+//	}
+func (d *db) FormatVersion() int {
+	header, _ := splitFileHeader(globalEncoderKey.readAndDecode())
+	version, _ := storedFormatVersion(header)
+	return version
+}
+
+// Upgrade rewrites a database written by an older version of the library in the current format.
+// Open reads old databases as they are and never writes them, they are upgraded the next time a change is saved,
+// Upgrade does it right away. It also moves the foreign keys of a legacy Links table to the system catalog and
+// re-encrypts files encrypted with the legacy unsalted key. A database in the current format, without a legacy Links
+// table or key, is not written.
+// Returns ErrReadOnly if the database is read-only and ErrUnsupportedFormat if it was written in a newer format
+//
+// Example:
+//
+//	err := db.Upgrade()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) Upgrade() error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	header, _ := splitFileHeader(readPlainDatabase())
+	if err := checkFormatVersion(header); err != nil {
+		return err
+	}
+	version, _ := storedFormatVersion(header)
+	link, ok := linkTable(getTables(false))
+	legacyLinks := ok && link.getSimpleName() == legacyLinkTableName
+	legacyEncryption := encryptionKeyExist && globalEncoderKey.legacy
+	if version == CurrentFormatVersion && !legacyLinks && !legacyEncryption {
+		return nil
+	}
+	upgradeLinkTable()
+	data := readPlainDatabase()
+	if legacyEncryption {
+		params := globalEncoderKey.params
+		globalEncoderKey.upgrade(&params)
+	}
+	saveDatabase(data)
+	return nil
+}
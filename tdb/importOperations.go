@@ -0,0 +1,204 @@
+package tdb
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// importBatchSize is the number of rows an import keeps in memory before saving them
+const importBatchSize = 1000
+
+// importer inserts the records read by ImportCSV, ImportNDJSON and ImportJSON into a table
+// and collects the errors of the records that can't be inserted.
+// The rows are checked as they are read and saved in batches, so the database is not written once per row.
+type importer struct {
+	d           *db
+	tableName   string
+	generateIds bool
+	ready       bool
+	tb          table
+	header      string
+	columns     []string
+	ids         map[string]bool
+	references  []importReference
+	pending     []string
+	imported    int
+	lineErrors  []LineError
+}
+
+// importReference is a foreign key of the imported table with the values of the referenced column
+type importReference struct {
+	key    ForeignKey
+	values map[string]bool
+}
+
+// prepare gets the table, creating it with the columns when it doesn't exist.
+// When strict is set every column must exist in the table
+func (im *importer) prepare(columns []string, strict bool) error {
+	tb, err := getTableByName(im.tableName, false)
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		created := slices.DeleteFunc(slices.Clone(columns), func(column string) bool { return column == "id" })
+		if _, err = im.d.NewTable(im.tableName, created); err != nil {
+			return err
+		}
+		tb, err = getTableByName(im.tableName, false)
+	}
+	if err != nil {
+		return err
+	}
+	im.tb, im.columns = tb, tableColumnNames(tb)
+	if strict {
+		for _, column := range columns {
+			if !slices.Contains(im.columns, column) {
+				return &NotFoundError{itemName: "Column: " + column}
+			}
+		}
+	}
+	im.header = tableHeader(tb.rawTable)
+	im.ids = map[string]bool{}
+	for _, row := range tb.GetRows() {
+		im.ids[row.SearchValue("id")] = true
+	}
+	im.references = nil
+	for _, key := range getForeignKeys() {
+		if key.ForeignTableName != im.tableName {
+			continue
+		}
+		reference := importReference{key: key, values: map[string]bool{}}
+		if parent, err := getTableByName(key.TableName, false); err == nil {
+			for _, row := range parent.GetRows() {
+				reference.values[row.SearchValue(key.ColumnName)] = true
+			}
+		}
+		im.references = append(im.references, reference)
+	}
+	im.ready = true
+	return nil
+}
+
+// insert adds a record of the line to the table, the table columns missing from the record and the empty values are null.
+// The id of the record is kept unless generateIds is set, an error of the record is kept as an error of the line
+func (im *importer) insert(line int, columns []string, values []string) error {
+	if !im.ready {
+		if err := im.prepare(columns, false); err != nil {
+			return err
+		}
+	}
+	for _, column := range columns {
+		if !slices.Contains(im.columns, column) {
+			im.fail(line, &NotFoundError{itemName: "Column: " + column})
+			return nil
+		}
+	}
+	row := make([]string, len(im.columns))
+	for i, column := range im.columns {
+		row[i] = "null"
+		if index := slices.Index(columns, column); index != -1 && values[index] != "" {
+			row[i] = values[index]
+		}
+	}
+	keepId := slices.Contains(columns, "id") && !im.generateIds && row[0] != "null"
+	if keepId && im.ids[row[0]] {
+		im.fail(line, fmt.Errorf("duplicated id %s", row[0]))
+		return nil
+	}
+	if !keepId {
+		row = row[1:]
+	}
+	rows := make([]Row, len(row))
+	for i, v := range row {
+		rows[i] = Row{columns: im.tb.columns, value: v}
+	}
+	built := builtRow(im.tb, valuesBuilder(im.header, rows, !keepId))
+	if err := im.checkReferences(built); err != nil {
+		im.fail(line, err)
+		return nil
+	}
+	for _, reference := range im.references {
+		if reference.key.TableName == im.tableName {
+			reference.values[built.SearchValue(reference.key.ColumnName)] = true
+		}
+	}
+	im.ids[built.SearchValue("id")] = true
+	im.pending = append(im.pending, built.value)
+	if len(im.pending) >= importBatchSize {
+		im.flush()
+	}
+	return nil
+}
+
+// checkReferences verifies the foreign keys of the row against the referenced values read by prepare
+// and the rows imported before it
+// Returns a ForeignKeyViolationError if a value references a missing row
+func (im *importer) checkReferences(row Row) error {
+	if foreignKeyChecksDeferred() {
+		return nil
+	}
+	for _, reference := range im.references {
+		value := row.SearchValue(reference.key.ForeignColumnName)
+		if value != "" && value != "null" && !reference.values[value] {
+			return &ForeignKeyViolationError{key: reference.key, value: value}
+		}
+	}
+	return nil
+}
+
+// flush saves the pending rows in a single write
+func (im *importer) flush() {
+	if len(im.pending) == 0 {
+		return
+	}
+	im.tb.rawTable = strings.Replace(im.tb.rawTable, "!*!", strings.Join(im.pending, "\n")+"\n!*!", 1)
+	im.tb.save()
+	im.imported += len(im.pending)
+	im.pending = nil
+}
+
+// fail keeps the error of a line that was not imported
+func (im *importer) fail(line int, err error) {
+	im.lineErrors = append(im.lineErrors, LineError{Line: line, Err: err})
+}
+
+// stop saves the pending rows and returns the number of rows imported with the error that stopped the import
+func (im *importer) stop(err error) (int, error) {
+	im.flush()
+	return im.imported, err
+}
+
+// result saves the pending rows and returns the number of rows imported and an ImportError with the lines that were not
+func (im *importer) result() (int, error) {
+	im.flush()
+	if len(im.lineErrors) > 0 {
+		return im.imported, &ImportError{lines: im.lineErrors}
+	}
+	return im.imported, nil
+}
+
+// mapColumns returns the names of the columns renamed with the mapping
+// Returns an error if two names are mapped to the same column
+func mapColumns(names []string, mapping map[string]string) ([]string, error) {
+	columns := make([]string, len(names))
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if column, ok := mapping[name]; ok {
+			name = column
+		}
+		if slices.Contains(columns[:i], name) {
+			return nil, fmt.Errorf("column %s is mapped twice", name)
+		}
+		columns[i] = name
+	}
+	return columns, nil
+}
+
+// tableColumnNames returns the column names of the table without the positions
+func tableColumnNames(t table) []string {
+	var columns []string
+	for i := 1; i < len(t.columns); i += 2 {
+		columns = append(columns, t.columns[i])
+	}
+	return columns
+}
@@ -0,0 +1,245 @@
+package tdb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	checksumHeaderPrefix = "CHK "
+	checksumSha256       = "sha256"
+	checksumHmacSha256   = "hmac-sha256"
+	fileChecksum         = "file"
+	tableChecksum        = "table"
+)
+
+var checksumsEnabled bool
+
+var columnMarkerRegex = regexp.MustCompile(`\[(\d+)\]`)
+var rowMarkerRegex = regexp.MustCompile(`\|(\d+)\|`)
+
+// checksumHeader builds the header lines with the checksum of the whole body and of each table
+// body: database content without header, as it is stored
+// key: HMAC key of the checksums, nil for plain SHA-256 checksums
+// Returns an empty string if checksums are not enabled
+func checksumHeader(body string, key []byte) string {
+	if !checksumsEnabled {
+		return ""
+	}
+	algorithm := checksumSha256
+	if key != nil {
+		algorithm = checksumHmacSha256
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("CHK %s %s %s\n", algorithm, fileChecksum, checksum(algorithm, key, body)))
+	for _, segment := range tableSegments(body) {
+		name := strings.Trim(getTableName(segment), "-")
+		builder.WriteString(fmt.Sprintf("CHK %s %s %s %s\n", algorithm, tableChecksum, checksum(algorithm, key, segment), name))
+	}
+	return builder.String()
+}
+
+// verifyDatabase validates the structure of every table and, if the database stores checksums, verifies them
+// Returns ErrInvalidKey if the key does not decrypt the database and a CorruptionError identifying the damaged table
+func verifyDatabase() error {
+	data := string(readDatabase())
+	encrypted := isEncode(data)
+	if encrypted {
+		if !encryptionKeyExist {
+			return &NotFoundError{itemName: "EncryptionKey"}
+		}
+		decoded, err := globalEncoderKey.Decode(data)
+		if err != nil {
+			return err
+		}
+		data = decoded
+	}
+	header, body := splitFileHeader(data)
+	if err := checkFormatVersion(header); err != nil {
+		return err
+	}
+	if err := validateStructure(body); err != nil {
+		return err
+	}
+	if err := verifyChecksums(header, body, encrypted); err != nil {
+		// a wrong column key also fails the HMAC checksums, the key is the problem to report
+		if _, decryptErr := decryptColumns(body); errors.Is(decryptErr, ErrInvalidKey) {
+			return ErrInvalidKey
+		}
+		return err
+	}
+	_, err := decryptColumns(body)
+	return err
+}
+
+// verifyChecksums compares the checksums stored in the header with the body
+// header: header lines of the database
+// body: database content without header
+// encrypted: the stored file is encrypted
+// Returns a CorruptionError if a checksum does not match, if checksums are enabled and the header has none,
+// or if an encrypted database stores plain SHA-256 checksums
+func verifyChecksums(header string, body string, encrypted bool) error {
+	tableSums := map[string]string{}
+	var fileSum, algorithm string
+	for _, line := range strings.Split(header, "\n") {
+		if !strings.HasPrefix(line, checksumHeaderPrefix) {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 5)
+		if len(fields) < 4 {
+			return &CorruptionError{reason: "invalid checksum header"}
+		}
+		algorithm = fields[1]
+		switch {
+		case fields[2] == fileChecksum:
+			fileSum = fields[3]
+		case fields[2] == tableChecksum && len(fields) == 5:
+			tableSums[fields[4]] = fields[3]
+		default:
+			return &CorruptionError{reason: "invalid checksum header"}
+		}
+	}
+	if algorithm == "" {
+		if checksumsEnabled {
+			// the checksum lines can't be told apart from a database that never had them, so they are required
+			return &CorruptionError{reason: "checksums are missing"}
+		}
+		return nil
+	}
+	checksumsEnabled = true
+	key := checksumKey()
+	if algorithm == checksumHmacSha256 && key == nil {
+		return &CorruptionError{reason: "checksum requires the encryption key"}
+	}
+	if algorithm != checksumSha256 && algorithm != checksumHmacSha256 {
+		return &CorruptionError{reason: "unknown checksum algorithm " + algorithm}
+	}
+	if algorithm == checksumSha256 && (encrypted || hasColumnKeyHeader(header)) {
+		// encrypted databases are always written with an HMAC, a plain checksum was put there to hide a change
+		return &CorruptionError{reason: "checksum of an encrypted database must be " + checksumHmacSha256}
+	}
+	for _, segment := range tableSegments(body) {
+		name := strings.Trim(getTableName(segment), "-")
+		sum, ok := tableSums[name]
+		if !ok {
+			return &CorruptionError{tableName: name, reason: "table has no checksum"}
+		}
+		if !hmac.Equal([]byte(sum), []byte(checksum(algorithm, key, segment))) {
+			return &CorruptionError{tableName: name, reason: "checksum mismatch"}
+		}
+		delete(tableSums, name)
+	}
+	for name := range tableSums {
+		return &CorruptionError{tableName: name, reason: "table is missing"}
+	}
+	if !hmac.Equal([]byte(fileSum), []byte(checksum(algorithm, key, body))) {
+		return &CorruptionError{reason: "checksum mismatch"}
+	}
+	return nil
+}
+
+// validateStructure checks that every table has matching markers and that every row
+// has the same number of fields as the table columns
+// body: database content without header
+// Returns a CorruptionError identifying the first damaged table
+func validateStructure(body string) error {
+	body = strings.ReplaceAll(body, "\r", "")
+	for _, segment := range strings.Split(body, "////") {
+		if segment == "" {
+			continue
+		}
+		if err := validateTableStructure(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTableStructure checks the structure of a single raw table
+// segment: raw table between two table frontiers
+// Returns a CorruptionError if the table is damaged
+func validateTableStructure(segment string) error {
+	lines := strings.Split(segment, "\n")
+	if len(lines) < 6 || !strings.HasPrefix(lines[1], "-----") || !strings.HasSuffix(lines[1], "-----") || len(lines[1]) <= 10 {
+		return &CorruptionError{reason: "table start marker not found"}
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(lines[1], "-----"), "-----")
+	if lines[len(lines)-2] != fmt.Sprintf("-----%s_End-----", name) {
+		return &CorruptionError{tableName: name, reason: "table end marker does not match"}
+	}
+	if lines[len(lines)-3] != "!*!" {
+		return &CorruptionError{tableName: name, reason: "insertion marker not found"}
+	}
+	columns := columnMarkerRegex.FindAllStringSubmatch(lines[2], -1)
+	if len(columns) == 0 || !strings.HasPrefix(lines[2], "[1] ") {
+		return &CorruptionError{tableName: name, reason: "column header not found"}
+	}
+	if !(name == legacyLinkTableName && lines[2] == legacyLinkHeader) {
+		// deleted columns leave gaps, the numbers only have to increase
+		previous := 0
+		for _, column := range columns {
+			number, _ := strconv.Atoi(column[1])
+			if number <= previous {
+				return &CorruptionError{tableName: name, reason: "column header numbering is not sequential"}
+			}
+			previous = number
+		}
+	}
+	for i := 3; i < len(lines)-3; i++ {
+		fields := rowMarkerRegex.FindAllStringSubmatch(lines[i], -1)
+		if !strings.HasPrefix(lines[i], "|1| ") || len(fields) != len(columns) {
+			return &CorruptionError{tableName: name, reason: fmt.Sprintf("row %d has %d fields, expected %d", i-2, len(fields), len(columns))}
+		}
+	}
+	return nil
+}
+
+// tableSegments returns the raw tables of the database body
+func tableSegments(body string) []string {
+	var segments []string
+	for _, segment := range strings.Split(body, "////") {
+		if strings.Contains(segment, "-----") {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// checksumKey returns the key used for HMAC checksums of the current database,
+// or nil if no encryption key is set
+func checksumKey() []byte {
+	if globalColumnCipher != nil {
+		return deriveChecksumKey(globalColumnCipher.key)
+	}
+	if encryptionKeyExist {
+		return deriveChecksumKey(must(globalEncoderKey.encodeKey()))
+	}
+	return nil
+}
+
+// deriveChecksumKey returns the HMAC key of the checksums for an encryption key
+func deriveChecksumKey(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("tdb checksum"))
+	return mac.Sum(nil)
+}
+
+// checksum returns the hexadecimal checksum of the data with the given algorithm
+// key: HMAC key, only used by the HMAC algorithm
+func checksum(algorithm string, key []byte, data string) string {
+	var h hash.Hash
+	if algorithm == checksumHmacSha256 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,300 @@
+package tdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// JSONOptions configures how ImportNDJSON reads the objects of a file
+type JSONOptions struct {
+	Columns     map[string]string // Maps a key of the objects to a column of the table, keys named like their column need no entry
+	GenerateIds bool              // Generate new ids even when the objects have an id
+}
+
+// jsonDocument is the JSON representation of a database written by ExportJSON.
+type jsonDocument struct {
+	Tables      []jsonTable  `json:"tables"`
+	ForeignKeys []ForeignKey `json:"foreignKeys"`
+}
+
+// jsonTable is a table of a jsonDocument.
+type jsonTable struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Rows    Rows     `json:"rows"`
+}
+
+// MarshalJSON encodes the row as an object keyed by column name, in the order of the columns.
+// Null values are encoded as null.
+func (r Row) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+	for i, column := range r.Columns() {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		key, err := json.Marshal(column)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteString(":")
+		value := strings.ReplaceAll(r.SearchValue(column), "U+0020", " ")
+		if value == "null" {
+			buffer.WriteString("null")
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encoded)
+	}
+	buffer.WriteString("}")
+	return buffer.Bytes(), nil
+}
+
+// MarshalJSON encodes the rows as an array of objects keyed by column name,
+// so the rows of FromSql can be written as the response of an HTTP handler.
+//
+// Example usage:
+//
+//	result, _ := db.FromSql("SELECT name , age FROM Users")
+//	_ = json.NewEncoder(w).Encode(result.Rows) // [{"name":"pedro","age":"20"}]
+func (r Rows) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString("[")
+	for i, row := range r {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		encoded, err := row.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encoded)
+	}
+	buffer.WriteString("]")
+	return buffer.Bytes(), nil
+}
+
+// ExportJSON writes the rows of the table as an array of objects keyed by column name, id included.
+//
+// Example usage:
+//
+//	err := table.ExportJSON(os.Stdout) // [{"id":"1","name":"pedro"}]
+func (t *table) ExportJSON(w io.Writer) error {
+	encoded, err := t.GetRows().MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
+
+// ExportNDJSON writes the rows of the table as newline-delimited JSON, an object per line,
+// so large tables can be streamed and read back with ImportNDJSON.
+//
+// Example usage:
+//
+//	err := table.ExportNDJSON(file)
+func (t *table) ExportNDJSON(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	for _, row := range t.GetRows() {
+		encoded, err := row.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		_, _ = writer.Write(encoded)
+		_ = writer.WriteByte('\n')
+	}
+	return writer.Flush()
+}
+
+// ExportJSON writes the whole database as a JSON document with its tables, their columns and rows,
+// and the foreign keys. The system tables are not exported. ImportJSON reads the document back.
+//
+// Example:
+//
+//	err := db.ExportJSON(file)
+//	// {"tables": [{"name": "Users", "columns": ["id", "name"], "rows": [{"id": "1", "name": "pedro"}]}], "foreignKeys": []}
+func (d *db) ExportJSON(w io.Writer) error {
+	document := jsonDocument{Tables: []jsonTable{}, ForeignKeys: d.ForeignKeys()}
+	for _, tb := range getTables(true) {
+		if isSystemTable(tb.getSimpleName()) {
+			continue
+		}
+		document.Tables = append(document.Tables, jsonTable{Name: tb.getSimpleName(), Columns: tableColumnNames(tb), Rows: tb.GetRows()})
+	}
+	if document.ForeignKeys == nil {
+		document.ForeignKeys = []ForeignKey{}
+	}
+	encoded, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
+
+// ImportJSON reads a document written by ExportJSON: it creates the missing tables, inserts the rows with their ids
+// and adds the missing foreign keys. The import runs in a transaction, so nothing is imported if a row fails;
+// inside a transaction started with Begin it is left to the caller to roll back.
+//
+// Example:
+//
+//	file, _ := os.Open("backup.json")
+//	defer file.Close()
+//	err := db.ImportJSON(file)
+func (d *db) ImportJSON(r io.Reader) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	var document struct {
+		Tables []struct {
+			Name    string            `json:"name"`
+			Columns []string          `json:"columns"`
+			Rows    []json.RawMessage `json:"rows"`
+		} `json:"tables"`
+		ForeignKeys []ForeignKey `json:"foreignKeys"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return err
+	}
+	ownTransaction := activeTransaction == nil
+	if ownTransaction {
+		if err := d.Begin(); err != nil {
+			return err
+		}
+	}
+	fail := func(err error) error {
+		if ownTransaction {
+			_ = d.Rollback()
+		}
+		return err
+	}
+	for _, t := range document.Tables {
+		im := &importer{d: d, tableName: t.Name}
+		if err := im.prepare(t.Columns, true); err != nil {
+			return fail(fmt.Errorf("table %s: %w", t.Name, err))
+		}
+		for i, raw := range t.Rows {
+			columns, values, err := decodeObject(raw)
+			if err == nil {
+				err = im.insert(i+1, columns, values)
+			}
+			if err != nil {
+				return fail(fmt.Errorf("table %s row %d: %w", t.Name, i+1, err))
+			}
+		}
+		im.flush()
+		if len(im.lineErrors) > 0 {
+			lineError := im.lineErrors[0]
+			return fail(fmt.Errorf("table %s row %d: %w", t.Name, lineError.Line, lineError.Err))
+		}
+	}
+	existing := d.ForeignKeys()
+	for _, key := range document.ForeignKeys {
+		if slices.ContainsFunc(existing, func(k ForeignKey) bool { return sameForeignKey(k, key) }) {
+			continue
+		}
+		if err := d.AddForeignKey(key); err != nil {
+			return fail(err)
+		}
+	}
+	if ownTransaction {
+		return d.Commit()
+	}
+	return nil
+}
+
+// ImportNDJSON inserts the objects of a newline-delimited JSON file into the table, one object per line, and returns
+// the number of rows imported. The table is created from the keys of the first object when it doesn't exist. The keys are
+// mapped to the columns by name or through JSONOptions.Columns, the columns missing from an object are null, and the
+// ids are preserved unless JSONOptions.GenerateIds is set. Strings are stored as they are and the other values as JSON.
+// The lines that can't be imported are skipped and returned in an ImportError, the other lines are imported.
+//
+// Example:
+//
+//	imported, err := db.ImportNDJSON("Users", file, nil)
+func (d *db) ImportNDJSON(tableName string, r io.Reader, opts *JSONOptions) (int, error) {
+	if readOnly {
+		return 0, ErrReadOnly
+	}
+	if opts == nil {
+		opts = &JSONOptions{}
+	}
+	im := &importer{d: d, tableName: tableName, generateIds: opts.GenerateIds}
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return im.stop(err)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			keys, values, decodeErr := decodeObject(data)
+			var columns []string
+			if decodeErr == nil {
+				columns, decodeErr = mapColumns(keys, opts.Columns)
+			}
+			if decodeErr != nil {
+				im.fail(line, decodeErr)
+			} else if insertErr := im.insert(line, columns, values); insertErr != nil {
+				return im.stop(insertErr)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	return im.result()
+}
+
+// decodeObject decodes a JSON object into its keys, in order, and their values.
+// Strings are returned as they are, null as null and the other values as JSON
+func decodeObject(data []byte) ([]string, []string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, nil, errors.New("expected a JSON object")
+	}
+	var keys, values []string
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := token.(string)
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		value := string(raw)
+		if raw[0] == '"' {
+			_ = json.Unmarshal(raw, &value)
+		} else if raw[0] == '{' || raw[0] == '[' {
+			var compact bytes.Buffer
+			_ = json.Compact(&compact, raw)
+			value = compact.String()
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	if _, err = decoder.Token(); err != nil {
+		return nil, nil, err
+	}
+	if decoder.More() {
+		return nil, nil, errors.New("unexpected data after the JSON object")
+	}
+	return keys, values, nil
+}
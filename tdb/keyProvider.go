@@ -0,0 +1,112 @@
+package tdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// KeyProvider defines the contract for obtaining the encryption key from outside the source code
+//
+// Example:
+//
+//	config := tdb.DbConfig{
+//		DatabaseName: "mydb.txt",
+//		KeyProvider:  tdb.NewEnvKeyProvider("TDB_KEY"),
+//	}
+//	db, err := config.CreateDatabase()
+type KeyProvider interface {
+
+	// Key returns the current encryption key
+	Key() (string, error)
+}
+
+// EnvKeyProvider reads the encryption key from an environment variable.
+type EnvKeyProvider struct {
+	name string
+}
+
+// FileKeyProvider reads the encryption key from a file that only its owner can access.
+type FileKeyProvider struct {
+	path string
+}
+
+// CommandKeyProvider reads the encryption key from the standard output of a command.
+type CommandKeyProvider struct {
+	name string
+	args []string
+}
+
+// NewEnvKeyProvider creates a KeyProvider that reads the environment variable name
+//
+// Example:
+//
+//	provider := tdb.NewEnvKeyProvider("TDB_KEY")
+func NewEnvKeyProvider(name string) *EnvKeyProvider {
+	return &EnvKeyProvider{name: name}
+}
+
+// NewFileKeyProvider creates a KeyProvider that reads the file at path
+// The file must not be accessible by group or others
+//
+// Example:
+//
+//	provider := tdb.NewFileKeyProvider("/run/secrets/tdb_key")
+func NewFileKeyProvider(path string) *FileKeyProvider {
+	return &FileKeyProvider{path: path}
+}
+
+// NewCommandKeyProvider creates a KeyProvider that runs the command and reads its output
+//
+// Example:
+//
+//	provider := tdb.NewCommandKeyProvider("pass", "show", "tdb/key")
+func NewCommandKeyProvider(name string, args ...string) *CommandKeyProvider {
+	return &CommandKeyProvider{name: name, args: args}
+}
+
+// Key returns the value of the environment variable.
+func (p *EnvKeyProvider) Key() (string, error) {
+	key, ok := os.LookupEnv(p.name)
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", &NotFoundError{itemName: "Environment variable: " + p.name}
+	}
+	return key, nil
+}
+
+// Key returns the content of the file without the trailing new line.
+// Returns an error if the file can be read by group or others.
+func (p *FileKeyProvider) Key() (string, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("key file %s must not be accessible by group or others, permissions: %s", p.path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimRight(string(data), "\r\n")
+	if strings.TrimSpace(key) == "" {
+		return "", errors.New("key file is empty")
+	}
+	return key, nil
+}
+
+// Key returns the standard output of the command without the trailing new line.
+func (p *CommandKeyProvider) Key() (string, error) {
+	output, err := exec.Command(p.name, p.args...).Output()
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimRight(string(output), "\r\n")
+	if strings.TrimSpace(key) == "" {
+		return "", errors.New("key command returned an empty key")
+	}
+	return key, nil
+}
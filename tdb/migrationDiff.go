@@ -0,0 +1,239 @@
+package tdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+const schemaSnapshotFile = "schema_snapshot.json"
+
+// schemaSnapshot is the schema of the database recorded when a migration is generated.
+type schemaSnapshot struct {
+	Tables      []schemaTable `json:"tables"`
+	ForeignKeys []ForeignKey  `json:"foreignKeys"`
+}
+
+// schemaTable is a table of a schema snapshot, the id column is not included.
+type schemaTable struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// schemaChange is a change between two schemas as generated Go code,
+// up applies the change and down undoes it.
+type schemaChange struct {
+	up   string
+	down string
+}
+
+// currentSchema returns the schema of the user tables and the foreign keys of the database
+func currentSchema() schemaSnapshot {
+	var snapshot schemaSnapshot
+	for _, t := range userTables(getTables(false)) {
+		snapshot.Tables = append(snapshot.Tables, schemaTable{Name: t.getSimpleName(), Columns: columnNames(t.columns)})
+	}
+	snapshot.ForeignKeys = getForeignKeys()
+	return snapshot
+}
+
+// columnNames returns the column names of a table header without the positions and the id column
+func columnNames(columns []string) []string {
+	var names []string
+	for i := 3; i < len(columns); i += 2 {
+		names = append(names, columns[i])
+	}
+	return names
+}
+
+// readSchemaSnapshot reads the last recorded schema from the migrations directory
+// Returns an empty schema if no snapshot was recorded yet
+func readSchemaSnapshot(migrationPath string) schemaSnapshot {
+	var snapshot schemaSnapshot
+	data, err := os.ReadFile(migrationPath + "/" + schemaSnapshotFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot
+	}
+	errorHandler(err)
+	errorHandler(json.Unmarshal(data, &snapshot))
+	return snapshot
+}
+
+// writeSchemaSnapshot records the schema in the migrations directory
+func writeSchemaSnapshot(migrationPath string, snapshot schemaSnapshot) {
+	data := must(json.MarshalIndent(snapshot, "", "  "))
+	errorHandler(os.WriteFile(migrationPath+"/"+schemaSnapshotFile, data, 0644))
+}
+
+// diffSchema returns the changes that turn the previous schema into the current one
+// A dropped and an added table with the same columns are a renamed table,
+// a dropped and an added column at the same position are a renamed column
+func diffSchema(previous schemaSnapshot, current schemaSnapshot) []schemaChange {
+	var changes []schemaChange
+	for _, key := range previous.ForeignKeys {
+		if !slices.ContainsFunc(current.ForeignKeys, func(k ForeignKey) bool { return sameForeignKey(k, key) }) {
+			changes = append(changes, schemaChange{
+				up:   fmt.Sprintf("func() error { return db.DropForeignKey(%s) }", foreignKeyLiteral(key)),
+				down: fmt.Sprintf("func() error { return db.AddForeignKey(%s) }", foreignKeyLiteral(key)),
+			})
+		}
+	}
+
+	dropped := slices.DeleteFunc(slices.Clone(previous.Tables), func(t schemaTable) bool { return containsTable(current.Tables, t.Name) })
+	added := slices.DeleteFunc(slices.Clone(current.Tables), func(t schemaTable) bool { return containsTable(previous.Tables, t.Name) })
+	renamed := map[string]string{}
+	for _, d := range dropped {
+		index := slices.IndexFunc(added, func(a schemaTable) bool { return slices.Equal(a.Columns, d.Columns) })
+		if index == -1 {
+			changes = append(changes, schemaChange{
+				up:   fmt.Sprintf("func() error { return db.DeleteTable(%q) }", d.Name),
+				down: fmt.Sprintf("func() error { _, err := db.NewTable(%q, %#v); return err }", d.Name, d.Columns),
+			})
+			continue
+		}
+		renamed[added[index].Name] = d.Name
+		changes = append(changes, schemaChange{
+			up:   fmt.Sprintf("func() error { return renameTable(db, %q, %q) }", d.Name, added[index].Name),
+			down: fmt.Sprintf("func() error { return renameTable(db, %q, %q) }", added[index].Name, d.Name),
+		})
+		added = slices.Delete(added, index, index+1)
+	}
+	for _, a := range added {
+		changes = append(changes, schemaChange{
+			up:   fmt.Sprintf("func() error { _, err := db.NewTable(%q, %#v); return err }", a.Name, a.Columns),
+			down: fmt.Sprintf("func() error { return db.DeleteTable(%q) }", a.Name),
+		})
+	}
+
+	for _, t := range current.Tables {
+		previousName := t.Name
+		if name, ok := renamed[t.Name]; ok {
+			previousName = name
+		}
+		index := slices.IndexFunc(previous.Tables, func(p schemaTable) bool { return p.Name == previousName })
+		if index == -1 {
+			continue
+		}
+		changes = append(changes, diffColumns(t.Name, previous.Tables[index].Columns, t.Columns)...)
+	}
+
+	for _, key := range current.ForeignKeys {
+		if !slices.ContainsFunc(previous.ForeignKeys, func(k ForeignKey) bool { return sameForeignKey(k, key) }) {
+			changes = append(changes, schemaChange{
+				up:   fmt.Sprintf("func() error { return db.AddForeignKey(%s) }", foreignKeyLiteral(key)),
+				down: fmt.Sprintf("func() error { return db.DropForeignKey(%s) }", foreignKeyLiteral(key)),
+			})
+		}
+	}
+	return changes
+}
+
+// diffColumns returns the changes that turn the previous columns of the table into the current ones
+func diffColumns(tableName string, previous []string, current []string) []schemaChange {
+	var changes []schemaChange
+	var added []string
+	for i, column := range current {
+		if slices.Contains(previous, column) {
+			continue
+		}
+		if i < len(previous) && !slices.Contains(current, previous[i]) {
+			changes = append(changes, schemaChange{
+				up:   fmt.Sprintf("func() error { return renameColumn(db, %q, %q, %q) }", tableName, previous[i], column),
+				down: fmt.Sprintf("func() error { return renameColumn(db, %q, %q, %q) }", tableName, column, previous[i]),
+			})
+			continue
+		}
+		added = append(added, column)
+	}
+	for i, column := range previous {
+		renamedColumn := i < len(current) && !slices.Contains(previous, current[i])
+		if slices.Contains(current, column) || renamedColumn {
+			continue
+		}
+		changes = append(changes, schemaChange{
+			up:   fmt.Sprintf("func() error { return dropColumn(db, %q, %q) }", tableName, column),
+			down: fmt.Sprintf("func() error { return addColumn(db, %q, %q) }", tableName, column),
+		})
+	}
+	for _, column := range added {
+		changes = append(changes, schemaChange{
+			up:   fmt.Sprintf("func() error { return addColumn(db, %q, %q) }", tableName, column),
+			down: fmt.Sprintf("func() error { return dropColumn(db, %q, %q) }", tableName, column),
+		})
+	}
+	return changes
+}
+
+// containsTable reports whether the schema tables contain a table with the name
+func containsTable(tables []schemaTable, name string) bool {
+	return slices.ContainsFunc(tables, func(t schemaTable) bool { return t.Name == name })
+}
+
+// foreignKeyLiteral returns the Go literal of the foreign key
+func foreignKeyLiteral(key ForeignKey) string {
+	return fmt.Sprintf("tdb.ForeignKey{TableName: %q, ColumnName: %q, ForeignTableName: %q, ForeignColumnName: %q, OnDelete: %q, OnUpdate: %q, DefaultValue: %q}",
+		key.TableName, key.ColumnName, key.ForeignTableName, key.ForeignColumnName, key.OnDelete, key.OnUpdate, key.DefaultValue)
+}
+
+// seedChanges returns an insert of the current rows of every user table and a delete of them as down,
+// the referenced tables are seeded before the tables that reference them
+// When DataConfig is set only its tables are seeded
+func seedChanges(c DbConfig) []schemaChange {
+	var changes []schemaChange
+	for _, t := range seedOrder(userTables(getTables(false))) {
+		tableName := t.getSimpleName()
+		if c.DataConfig != nil && !slices.ContainsFunc(c.DataConfig, func(d DataConfig) bool { return d.TableName == tableName }) {
+			continue
+		}
+		if len(t.values) == 0 {
+			continue
+		}
+		columns := append([]string{"id"}, columnNames(t.columns)...)
+		identifiers := make([]string, len(columns))
+		for i, column := range columns {
+			identifiers[i] = sqlIdentifier(column)
+		}
+		var rows []string
+		var deletes []string
+		for _, row := range t.values {
+			values := make([]string, len(columns))
+			for i, column := range columns {
+				values[i] = sqlLiteral(strings.ReplaceAll(row.SearchValue(column), "U+0020", " "))
+			}
+			rows = append(rows, "("+strings.Join(values, ", ")+")")
+			deletes = append(deletes, fmt.Sprintf("func() error { return execSql(db, %q) }",
+				fmt.Sprintf("DELETE FROM %s WHERE %s = %s", sqlIdentifier(tableName), sqlIdentifier("id"), values[0])))
+		}
+		slices.Reverse(deletes)
+		sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", sqlIdentifier(tableName), strings.Join(identifiers, ", "), strings.Join(rows, ", "))
+		changes = append(changes, schemaChange{
+			up:   fmt.Sprintf("func() error { return execSql(db, %q) }", sql),
+			down: strings.Join(deletes, ",\n\t\t\t"),
+		})
+	}
+	return changes
+}
+
+// seedOrder sorts the tables so every table comes after the tables it references,
+// the tables of a cyclic relationship keep their order
+func seedOrder(tables []table) []table {
+	keys := getForeignKeys()
+	var ordered []table
+	for len(tables) > 0 {
+		next := slices.IndexFunc(tables, func(t table) bool {
+			return !slices.ContainsFunc(keys, func(k ForeignKey) bool {
+				return k.ForeignTableName == t.getSimpleName() && k.TableName != k.ForeignTableName &&
+					slices.ContainsFunc(tables, func(p table) bool { return p.getSimpleName() == k.TableName })
+			})
+		})
+		if next == -1 {
+			next = 0
+		}
+		ordered = append(ordered, tables[next])
+		tables = slices.Delete(tables, next, next+1)
+	}
+	return ordered
+}
@@ -1,54 +1,692 @@
 package tdb
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Migration defines a versioned change of the database applied by Migrate.
+//
+// Example:
+//
+//	migration := tdb.Migration{
+//		ID: "0001_create_users",
+//		Up: func(db tdb.Db) error {
+//			_, err := db.NewTable("Users", []string{"name", "email"})
+//			return err
+//		},
+//		Down: func(db tdb.Db) error {
+//			return db.DeleteTable("Users")
+//		},
+//	}
+type Migration struct {
+	ID       string            // Unique identifier of the migration
+	Up       func(db Db) error // Applies the change
+	Down     func(db Db) error // Undoes the change, optional
+	Checksum string            // Checksum of the migration source, optional, recorded when the migration is applied
+}
+
+// appliedMigration is a migration recorded in the migrations system table.
+type appliedMigration struct {
+	id        string
+	appliedAt string
+	checksum  string
+}
+
+const migrationsTableName = systemTablePrefix + "migrations"
+
+var migrationsTableColumns = []string{"migration", "appliedAt", "checksum"}
+
+// Migrate applies the migrations that are not applied yet to the database, in the given order
+// The pending migrations run inside a single transaction, if one fails the database is rolled back
+// and none of them is recorded
+// The applied migrations are recorded in the __tdb_migrations system table
+// Returns ErrMigrationChecksumMismatch if the checksum of an applied migration changed
+//
+// Example:
+//
+//	err := tdb.Migrate(db, createUsers, addEmailToUsers)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func Migrate(db Db, migrations ...Migration) error {
+	steps, err := migrateSteps(migrations)
+	if err != nil {
+		return err
+	}
+	return runMigrationSteps(db, steps)
+}
+
+// migrateSteps returns the steps that apply the pending migrations
+func migrateSteps(migrations []Migration) ([]migrationStep, error) {
+	if err := validateMigrations(migrations); err != nil {
+		return nil, err
+	}
+	if err := verifyMigrationChecksums(migrations); err != nil {
+		return nil, err
+	}
+	steps := make([]migrationStep, 0, len(migrations))
+	for _, m := range pendingMigrations(migrations) {
+		steps = append(steps, migrationStep{migration: m})
+	}
+	return steps, nil
+}
+
+// MigrateTo applies or rolls back migrations until version is the last applied migration
+// version: ID of the target migration, an empty version rolls back every migration
+// migrations: every migration of the database, in order
+// The steps run inside a single transaction, if one fails the database is rolled back
+// Returns ErrIrreversibleMigration before changing anything if a migration to roll back has no Down function
+// Returns ErrMigrationChecksumMismatch if the checksum of an applied migration changed
+//
+// Example:
+//
+//	err := db.MigrateTo("0001_create_users", createUsers, addEmailToUsers)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) MigrateTo(version string, migrations ...Migration) error {
+	steps, err := migrateToSteps(version, migrations)
+	if err != nil {
+		return err
+	}
+	return runMigrationSteps(d, steps)
+}
+
+// migrateToSteps returns the steps that roll back and apply migrations until version is the last applied migration
+func migrateToSteps(version string, migrations []Migration) ([]migrationStep, error) {
+	if err := validateMigrations(migrations); err != nil {
+		return nil, err
+	}
+	if err := verifyMigrationChecksums(migrations); err != nil {
+		return nil, err
+	}
+	target := -1
+	if version != "" {
+		target = slices.IndexFunc(migrations, func(m Migration) bool { return m.ID == version })
+		if target == -1 {
+			return nil, &NotFoundError{itemName: "Migration: " + version}
+		}
+	}
+	var steps []migrationStep
+	applied := getAppliedMigrations()
+	for i := len(applied) - 1; i >= 0; i-- {
+		index := slices.IndexFunc(migrations, func(m Migration) bool { return m.ID == applied[i].id })
+		if index == -1 {
+			return nil, &NotFoundError{itemName: "Migration: " + applied[i].id}
+		}
+		if index <= target {
+			continue
+		}
+		if migrations[index].Down == nil {
+			return nil, fmt.Errorf("%w: %s", ErrIrreversibleMigration, applied[i].id)
+		}
+		steps = append(steps, migrationStep{migration: migrations[index], down: true})
+	}
+	for _, m := range pendingMigrations(migrations[:target+1]) {
+		steps = append(steps, migrationStep{migration: m})
+	}
+	return steps, nil
+}
+
+// MigrationVersion returns the ID of the last applied migration, or an empty string if no migration is applied
+//
+// Example:
+//
+//	fmt.Println("Schema version:", db.MigrationVersion())
+func (d *db) MigrationVersion() string {
+	applied := getAppliedMigrations()
+	if len(applied) == 0 {
+		return ""
+	}
+	return applied[len(applied)-1].id
+}
+
+// migrationStep is a migration to apply, or to roll back when down is set.
+type migrationStep struct {
+	migration Migration
+	down      bool
+}
+
+// runMigrationSteps runs the steps in order inside a transaction and records them
+// Returns the error of the first failing step, after rolling back the database
+func runMigrationSteps(db Db, steps []migrationStep) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	if err := db.Begin(); err != nil {
+		return err
+	}
+	for _, step := range steps {
+		m := step.migration
+		run, action := m.Up, "migration"
+		if step.down {
+			run, action = m.Down, "rollback of migration"
+		}
+		if err := run(db); err != nil {
+			_ = db.Rollback()
+			return fmt.Errorf("%s %s failed: %w", action, m.ID, err)
+		}
+		if step.down {
+			forgetMigration(m.ID)
+		} else {
+			recordMigration(m)
+		}
+	}
+	return db.Commit()
+}
+
+// validateMigrations checks that every migration has a unique ID and an Up function
+func validateMigrations(migrations []Migration) error {
+	ids := map[string]bool{}
+	for _, m := range migrations {
+		if strings.TrimSpace(m.ID) == "" {
+			return errors.New("migration ID is required")
+		}
+		if ids[m.ID] {
+			return fmt.Errorf("duplicate migration ID: %s", m.ID)
+		}
+		if m.Up == nil {
+			return fmt.Errorf("migration %s has no Up function", m.ID)
+		}
+		ids[m.ID] = true
+	}
+	return nil
+}
+
+// verifyMigrationChecksums checks that the applied migrations were not modified since they were applied,
+// a migration without checksum is not checked
+func verifyMigrationChecksums(migrations []Migration) error {
+	for _, applied := range getAppliedMigrations() {
+		index := slices.IndexFunc(migrations, func(m Migration) bool { return m.ID == applied.id })
+		if index == -1 || applied.checksum == "" || migrations[index].Checksum == "" {
+			continue
+		}
+		if migrations[index].Checksum != applied.checksum {
+			return fmt.Errorf("%w: %s", ErrMigrationChecksumMismatch, applied.id)
+		}
+	}
+	return nil
+}
+
+// pendingMigrations returns the migrations that are not recorded as applied
+func pendingMigrations(migrations []Migration) []Migration {
+	applied := map[string]bool{}
+	for _, m := range getAppliedMigrations() {
+		applied[m.id] = true
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if !applied[m.ID] {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// getAppliedMigrations returns the migrations recorded in the migrations system table, in the order they were applied
+func getAppliedMigrations() []appliedMigration {
+	tb, err := getTableByName(migrationsTableName, false)
+	if err != nil {
+		return nil
+	}
+	migrations := make([]appliedMigration, len(tb.values))
+	for i, row := range tb.values {
+		migrations[i] = appliedMigration{
+			id:        decodeSystemValue(row.SearchValue("migration")),
+			appliedAt: decodeSystemValue(row.SearchValue("appliedAt")),
+			checksum:  decodeSystemValue(row.SearchValue("checksum")),
+		}
+	}
+	return migrations
+}
+
+// saveAppliedMigrations writes the applied migrations in the migrations system table
+func saveAppliedMigrations(migrations []appliedMigration) {
+	rows := make([][]string, len(migrations))
+	for i, m := range migrations {
+		rows[i] = []string{m.id, m.appliedAt, m.checksum}
+	}
+	saveTables(setSystemTable(getTables(false), migrationsTableName, migrationsTableColumns, rows))
+}
+
+// forgetMigration removes the migration from the applied migrations
+func forgetMigration(id string) {
+	saveAppliedMigrations(slices.DeleteFunc(getAppliedMigrations(), func(m appliedMigration) bool { return m.id == id }))
+}
+
+// recordMigration records the migration as applied now, with its checksum
+func recordMigration(m Migration) {
+	applied := append(getAppliedMigrations(), appliedMigration{id: m.ID, appliedAt: time.Now().UTC().Format(time.RFC3339), checksum: m.Checksum})
+	saveAppliedMigrations(applied)
+}
+
+// MigrationPlan reports what Migrate or MigrateTo would do, computed on an in-memory copy of the database.
+type MigrationPlan struct {
+	Migrations []PlannedMigration // Migrations that would run, in order
+	Conflicts  []error            // Foreign key violations left by the migrations when the checks are deferred
+}
+
+// PlannedMigration is a migration of a plan with the operations it would perform.
+type PlannedMigration struct {
+	ID         string             // ID of the migration
+	Down       bool               // The migration would be rolled back
+	Operations []PlannedOperation // Operations performed by the migration, in order
+	Err        error              // Error the migration would fail with, the next migrations are not planned
+}
+
+// PlannedOperation is a change performed by a migration.
+type PlannedOperation struct {
+	Operation    string // Description of the operation, like "NewTable Users (name, age)"
+	AffectedRows int    // Rows inserted, updated or deleted by the operation, including the referential actions
+}
+
+// PlanMigrations reports what Migrate would do without writing the database:
+// the pending migrations, the operations each would perform, the rows they would affect
+// and the errors and foreign key conflicts that would make them fail
+// The migrations run on an in-memory copy of the database, so a read-only database can be planned too
+// Returns ErrTransactionInProgress if a transaction is in progress
+//
+// Example:
+//
+//	plan, err := tdb.PlanMigrations(db, migrations...)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(plan)
+//	if plan.Err() == nil {
+//		err = tdb.Migrate(db, migrations...)
+//	}
+func PlanMigrations(db Db, migrations ...Migration) (MigrationPlan, error) {
+	steps, err := migrateSteps(migrations)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	return planMigrationSteps(db, steps)
+}
+
+// PlanMigrateTo reports what MigrateTo would do without writing the database, see PlanMigrations
+// Returns ErrTransactionInProgress if a transaction is in progress
+//
+// Example:
+//
+//	plan, err := db.PlanMigrateTo("0001_create_users", migrations...)
+func (d *db) PlanMigrateTo(version string, migrations ...Migration) (MigrationPlan, error) {
+	steps, err := migrateToSteps(version, migrations)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	return planMigrationSteps(d, steps)
+}
+
+// Err returns the error of the failing migration or the first conflict, nil if the migrations would succeed
+func (p MigrationPlan) Err() error {
+	for _, m := range p.Migrations {
+		if m.Err != nil {
+			return fmt.Errorf("migration %s would fail: %w", m.ID, m.Err)
+		}
+	}
+	if len(p.Conflicts) > 0 {
+		return p.Conflicts[0]
+	}
+	return nil
+}
+
+// String returns the plan as a readable report
+func (p MigrationPlan) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Migrations to run: %d\n", len(p.Migrations)))
+	for _, m := range p.Migrations {
+		direction := "up"
+		if m.Down {
+			direction = "down"
+		}
+		builder.WriteString(fmt.Sprintf("[%s] %s\n", direction, m.ID))
+		for _, o := range m.Operations {
+			builder.WriteString(fmt.Sprintf("    %s (%d rows)\n", o.Operation, o.AffectedRows))
+		}
+		if m.Err != nil {
+			builder.WriteString(fmt.Sprintf("    FAILS: %s\n", m.Err))
+		}
+	}
+	for _, c := range p.Conflicts {
+		builder.WriteString(fmt.Sprintf("CONFLICT: %s\n", c))
+	}
+	return builder.String()
+}
+
+// planMigrationSteps runs the steps on an in-memory copy of the database and records their operations
+// Returns ErrTransactionInProgress if a transaction is in progress
+func planMigrationSteps(db Db, steps []migrationStep) (MigrationPlan, error) {
+	restore, err := simulateDatabase()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	defer restore()
+	var plan MigrationPlan
+	if len(steps) == 0 {
+		return plan, nil
+	}
+	activeTransaction = &transaction{snapshot: readDatabase()}
+	for _, step := range steps {
+		planned := PlannedMigration{ID: step.migration.ID, Down: step.down}
+		recorder := &planDb{Db: db, migration: &planned}
+		run := step.migration.Up
+		if step.down {
+			run = step.migration.Down
+		}
+		planned.Err = run(recorder)
+		plan.Migrations = append(plan.Migrations, planned)
+		if planned.Err != nil {
+			return plan, nil
+		}
+		if step.down {
+			forgetMigration(step.migration.ID)
+		} else {
+			recordMigration(step.migration)
+		}
+	}
+	if deferForeignKeys {
+		plan.Conflicts = foreignKeyViolations()
+	}
+	return plan, nil
+}
+
+// simulateDatabase replaces the storage with a writable in-memory copy of the database, so no change is written
+// The current database is swapped until the returned function restores it, like every operation on the
+// current database it must not run concurrently with other operations on it
+// Returns the function that restores the storage, and ErrTransactionInProgress if a transaction is in progress,
+// since its uncommitted changes would be planned as if they were committed
+func simulateDatabase() (func(), error) {
+	if activeTransaction != nil {
+		return nil, ErrTransactionInProgress
+	}
+	storage, wasReadOnly := dbStorage, readOnly
+	memory := NewMemoryStorage()
+	errorHandler(memory.Write(readDatabase()))
+	dbStorage, readOnly = memory, false
+	return func() {
+		dbStorage, activeTransaction, readOnly = storage, nil, wasReadOnly
+	}, nil
+}
+
+// foreignKeyViolations returns every reference of the database that is broken
+func foreignKeyViolations() []error {
+	var violations []error
+	for _, key := range getForeignKeys() {
+		child, err := getTableByName(key.ForeignTableName, false)
+		if err != nil {
+			continue
+		}
+		for _, row := range child.values {
+			if err := checkReference(key, row.SearchValue(key.ForeignColumnName)); err != nil {
+				violations = append(violations, err)
+			}
+		}
+	}
+	return violations
+}
+
+// rowsSnapshot returns every row of the user tables by table and id
+func rowsSnapshot() map[string]string {
+	rows := map[string]string{}
+	for _, t := range userTables(getTables(false)) {
+		for _, row := range t.values {
+			rows[t.getSimpleName()+"\x00"+row.SearchValue("id")] = row.value
+		}
+	}
+	return rows
+}
+
+// changedRows returns the number of rows inserted, updated or deleted between both snapshots
+func changedRows(before map[string]string, after map[string]string) int {
+	changed := 0
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || previous != value {
+			changed++
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changed++
+		}
+	}
+	return changed
+}
+
+// planDb records the operations a migration performs on the database.
+type planDb struct {
+	Db
+	migration *PlannedMigration
+}
+
+// record runs the operation and adds it to the planned migration with the rows it changed,
+// the rows of a renamed table are not counted as changed
+func (p *planDb) record(operation string, rename bool, fn func() error) error {
+	before := rowsSnapshot()
+	if err := fn(); err != nil {
+		return err
+	}
+	affected := 0
+	if !rename {
+		affected = changedRows(before, rowsSnapshot())
+	}
+	p.migration.Operations = append(p.migration.Operations, PlannedOperation{Operation: operation, AffectedRows: affected})
+	return nil
+}
+
+func (p *planDb) NewTable(name string, columns []string) (Table, error) {
+	var tb Table
+	err := p.record(fmt.Sprintf("NewTable %s (%s)", name, strings.Join(columns, ", ")), false, func() error {
+		var err error
+		tb, err = p.Db.NewTable(name, columns)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &planTable{Table: tb, db: p}, nil
+}
+
+func (p *planDb) GetTableByName(name string) (Table, error) {
+	tb, err := p.Db.GetTableByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return &planTable{Table: tb, db: p}, nil
+}
+
+func (p *planDb) GetTables() []Table {
+	tables := p.Db.GetTables()
+	for i, tb := range tables {
+		tables[i] = &planTable{Table: tb, db: p}
+	}
+	return tables
+}
+
+func (p *planDb) DeleteTable(name string) error {
+	return p.record("DeleteTable "+name, false, func() error { return p.Db.DeleteTable(name) })
+}
+
+func (p *planDb) AddForeignKey(key ForeignKey) error {
+	return p.record("AddForeignKey "+foreignKeyDescription(key), false, func() error { return p.Db.AddForeignKey(key) })
+}
+
+func (p *planDb) AddForeignKeys(keys []ForeignKey) error {
+	for _, key := range keys {
+		if err := p.AddForeignKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *planDb) DropForeignKey(key ForeignKey) error {
+	return p.record("DropForeignKey "+foreignKeyDescription(key), false, func() error { return p.Db.DropForeignKey(key) })
+}
+
+func (p *planDb) FromSql(sql string) (SqlRows, error) {
+	var result SqlRows
+	rename := strings.Contains(strings.ToUpper(sql), " RENAME TO ")
+	err := p.record("SQL "+sql, rename, func() error {
+		var err error
+		result, err = p.Db.FromSql(sql)
+		return err
+	})
+	return result, err
+}
+
+func (p *planDb) ImportCSV(tableName string, r io.Reader, opts *CSVOptions) (int, error) {
+	imported := 0
+	err := p.record("ImportCSV "+tableName, false, func() error {
+		var err error
+		imported, err = p.Db.ImportCSV(tableName, r, opts)
+		return err
+	})
+	return imported, err
+}
+
+func (p *planDb) ImportJSON(r io.Reader) error {
+	return p.record("ImportJSON", false, func() error { return p.Db.ImportJSON(r) })
+}
+
+func (p *planDb) ImportNDJSON(tableName string, r io.Reader, opts *JSONOptions) (int, error) {
+	imported := 0
+	err := p.record("ImportNDJSON "+tableName, false, func() error {
+		var err error
+		imported, err = p.Db.ImportNDJSON(tableName, r, opts)
+		return err
+	})
+	return imported, err
+}
+
+func (p *planDb) Restore(r io.Reader) error {
+	return p.record("Restore", false, func() error { return p.Db.Restore(r) })
+}
+
+// foreignKeyDescription returns the key as referenced -> referencing column
+func foreignKeyDescription(key ForeignKey) string {
+	return fmt.Sprintf("%s.%s -> %s.%s", key.TableName, key.ColumnName, key.ForeignTableName, key.ForeignColumnName)
+}
+
+// planTable records the operations a migration performs on a table.
+type planTable struct {
+	Table
+	db *planDb
+}
+
+func (p *planTable) name() string {
+	return strings.Trim(p.GetName(), "-")
+}
+
+func (p *planTable) AddValue(column string, value string) error {
+	return p.db.record(fmt.Sprintf("AddValue %s.%s", p.name(), column), false, func() error { return p.Table.AddValue(column, value) })
+}
+
+func (p *planTable) AddValues(values ...string) error {
+	return p.db.record("AddValues "+p.name(), false, func() error { return p.Table.AddValues(values...) })
+}
+
+func (p *planTable) UpdateTableName(newName string) error {
+	return p.db.record(fmt.Sprintf("UpdateTableName %s to %s", p.name(), newName), true, func() error { return p.Table.UpdateTableName(newName) })
+}
+
+func (p *planTable) UpdateColumnName(oldColumnName string, newColumnName string) error {
+	return p.db.record(fmt.Sprintf("UpdateColumnName %s.%s to %s", p.name(), oldColumnName, newColumnName), false, func() error {
+		return p.Table.UpdateColumnName(oldColumnName, newColumnName)
+	})
+}
+
+func (p *planTable) UpdateValue(columnName string, id string, newValue string) error {
+	return p.db.record(fmt.Sprintf("UpdateValue %s.%s id %s", p.name(), columnName, id), false, func() error {
+		return p.Table.UpdateValue(columnName, id, newValue)
+	})
+}
+
+func (p *planTable) DeleteRow(id string, cascade bool) error {
+	return p.db.record(fmt.Sprintf("DeleteRow %s id %s", p.name(), id), false, func() error { return p.Table.DeleteRow(id, cascade) })
+}
+
+func (p *planTable) AddColumn(columnName string) error {
+	return p.db.record(fmt.Sprintf("AddColumn %s.%s", p.name(), columnName), false, func() error { return p.Table.AddColumn(columnName) })
+}
+
+func (p *planTable) DeleteColumn(columnName string) error {
+	return p.db.record(fmt.Sprintf("DeleteColumn %s.%s", p.name(), columnName), false, func() error { return p.Table.DeleteColumn(columnName) })
+}
+
 // CreateMigration generates a new migration file with the specified name.
+// It compares the schema of the database with the schema recorded by the last generated migration
+// and the migration only contains the difference: added, dropped and renamed tables and columns and the foreign keys.
+// Nothing is generated when the schema didn't change.
 // It creates necessary directories and files for the migration, including a constructor file.
 // If a migration with the same name already exists, it panics.
 func (c DbConfig) CreateMigration(migrationName string) {
-	path := must(os.Getwd())
-	migrationPath := path + "/migrations"
-	constructorPath := migrationPath + "/constructor.go"
+	migrationPath := must(os.Getwd()) + "/migrations"
+	errorHandler(os.MkdirAll(migrationPath, 0755))
+	if isFileNameExist(migrationPath, migrationName) {
+		panic("Migration already exist")
+	}
+	current := currentSchema()
+	changes := diffSchema(readSchemaSnapshot(migrationPath), current)
+	if len(changes) == 0 {
+		return
+	}
+	writeMigration(c, migrationPath, migrationName, "Schema Changes", changes)
+	writeSchemaSnapshot(migrationPath, current)
+}
+
+// CreateSeedMigration generates a new migration file with the specified name that inserts the current rows of the database,
+// Down deletes them. When DataConfig is set only its tables are seeded.
+// The schema snapshot is not changed, so the seed can be generated at any moment.
+// If a migration with the same name already exists, it panics.
+func (c DbConfig) CreateSeedMigration(migrationName string) {
+	migrationPath := must(os.Getwd()) + "/migrations"
+	errorHandler(os.MkdirAll(migrationPath, 0755))
 	if isFileNameExist(migrationPath, migrationName) {
 		panic("Migration already exist")
 	}
-	fileRoute := fmt.Sprintf("%s/%s_Migration_%d.go", migrationPath, migrationName, time.Now().Unix())
+	changes := seedChanges(c)
+	if len(changes) == 0 {
+		return
+	}
+	writeMigration(c, migrationPath, migrationName, "Seed Data", changes)
+}
+
+// writeMigration writes the migration file of the changes and rebuilds the constructor file
+func writeMigration(c DbConfig, migrationPath string, migrationName string, description string, changes []schemaChange) {
+	constructorPath := migrationPath + "/constructor.go"
+	migrationId := fmt.Sprintf("%s_Migration_%d", migrationName, time.Now().UnixNano())
+	fileRoute := fmt.Sprintf("%s/%s.go", migrationPath, migrationId)
 	if !isFileExist(fileRoute) {
-		code := migrationBuilder(c, migrationName)
-		errorHandler(os.MkdirAll(migrationPath, 0755))
+		code := migrationBuilder(c, migrationName, migrationId, description, changes)
 		errorHandler(os.WriteFile(fileRoute, code, 0755))
-		if !isFileExist(constructorPath) {
-			constructorCode := constructorBuilder(migrationName)
-			errorHandler(os.WriteFile(constructorPath, constructorCode, 0755))
-		} else {
-			err := os.Remove(constructorPath)
-			if err != nil {
-				return
-			}
-			constructorCode := constructorBuilder(migrationName)
-			errorHandler(os.WriteFile(constructorPath, constructorCode, 0755))
-
+		upgradeLegacyMigrations(migrationPath)
+		if isFileExist(constructorPath) {
+			errorHandler(os.Remove(constructorPath))
 		}
+		errorHandler(os.WriteFile(constructorPath, constructorBuilder(), 0755))
 	}
 }
 
 // migrationBuilder generates the content for a new migration file.
-// It takes database configuration and migration name as parameters and returns
+// It takes database configuration, migration name, migration ID, description and changes as parameters and returns
 // the generated migration code as a byte slice.
-func migrationBuilder(c DbConfig, migrationName string) []byte {
+// The migration is a tdb.Migration whose Up applies the changes in order and Down undoes them in reverse order.
+func migrationBuilder(c DbConfig, migrationName string, migrationId string, description string, changes []schemaChange) []byte {
 	var builder strings.Builder
 
 	imports := `
 package migrations
 
-import "text-database/tdb"
+import "github.com/sheymor21/text-database/tdb"
 `
 	constants := fmt.Sprintf(`
 	// migrationName        = %q
@@ -56,49 +694,61 @@ import "text-database/tdb"
 	// migrationVersion     = 1
 	// migrationDate        = %q
 	// migrationDescription = %q
-`, migrationName, c.DatabaseName, time.Now().UTC().String(), "Initial Migration")
+`, migrationName, c.DatabaseName, time.Now().UTC().String(), description)
 
-	functionGenerateTable := fmt.Sprintf(`
-func generate%s() {
-%s
-	config := tdb.DbConfig{EncryptionKey: "", DatabaseName: "%s"}
-	db, err := config.CreateDatabase()
-	if err != nil {
-		return
-	}
-
-	for _, t := range *tablesS {
-		tb := db.NewTable(t.name, t.columns)
-		for _, v := range t.values {
-			tb = tb.AddValues(v.value)
-		}
+	var up strings.Builder
+	var down strings.Builder
+	for i := range changes {
+		up.WriteString(fmt.Sprintf("\t\t\t%s,\n", changes[i].up))
+		down.WriteString(fmt.Sprintf("\t\t\t%s,\n", changes[len(changes)-1-i].down))
 	}
-}`, upperCase(migrationName), migrationTableBuilder(c), c.DatabaseName)
+	migration := fmt.Sprintf(`
+var %sMigration = tdb.Migration{
+	ID: %q,
+	Up: func(db tdb.Db) error {
+		return runSteps(
+%s		)
+	},
+	Down: func(db tdb.Db) error {
+		return runSteps(
+%s		)
+	},
+}
+`, upperCase(migrationName), migrationId, up.String(), down.String())
 
 	builder.WriteString(imports)
 	builder.WriteString(constants)
-	builder.WriteString(functionGenerateTable)
+	builder.WriteString(migration)
 	return []byte(builder.String())
 }
 
 // constructorBuilder generates the content for the migration constructor file.
-// It creates a file that contains information about all migrations and their execution order.
+// It creates a file that contains information about all migrations and their execution order,
+// and the helpers used by the generated migrations.
 // Returns the generated constructor code as a byte slice.
-func constructorBuilder(migrationName string) []byte {
+func constructorBuilder() []byte {
 	var builder strings.Builder
 	var namesBuilder strings.Builder
+	var migrationsBuilder strings.Builder
 	namesBuilder.WriteString("// Migrations Order:\n")
 	for i, m := range getMigrationsNames() {
 		names := fmt.Sprintf("// [%d] %s", i+1, m)
 		namesBuilder.WriteString(names)
 		namesBuilder.WriteString("\n")
-
+		migrationsBuilder.WriteString(fmt.Sprintf("\t\t%sMigration,\n", upperCase(strings.Split(m, "_Migration_")[0])))
 	}
 	imports := `
 package migrations
 
+import (
+	"fmt"
+	"github.com/sheymor21/text-database/tdb"
+	"strings"
+)
+
 `
 	types := `
+// table and value are used by the migrations generated with a full copy of the tables
 type table struct {
 	name    string
 	columns []string
@@ -107,95 +757,149 @@ type table struct {
 type value struct {
 	value []string
 }`
-	lastMigration := fmt.Sprintf(`
-func GenerateMigration() {
-	generate%s()
-}`, upperCase(migrationName))
+	helpers := `
 
-	builder.WriteString(imports)
-	builder.WriteString(namesBuilder.String())
-	builder.WriteString(types)
-	builder.WriteString(lastMigration)
-	return []byte(builder.String())
+// runSteps runs the steps in order and stops at the first error
+func runSteps(steps ...func() error) error {
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// migrationTableBuilder generates the table definitions for the migration.
-// It processes the database configuration and returns a string containing
-// the table structures and their associated data.
-func migrationTableBuilder(c DbConfig) string {
-	var builder strings.Builder
-	tables := getTables(false)
+func renameTable(db tdb.Db, tableName string, newName string) error {
+	tb, err := db.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	return tb.UpdateTableName(newName)
+}
 
-	for _, t := range tables {
-		tableName := strings.ReplaceAll(t.nameRaw, "-", "")
-		columns := migrationColumnBuilder(t.columns)
-		var values string
-		if c.DataConfig != nil {
-			values = migrationValuesBuilder(t.nameRaw, c.DataConfig)
-		}
-		//values := "[]value{}"
-		tableStr := fmt.Sprintf(`{
-			name:    %q,
-			columns: %s,
-			values:  %s,
-		},`,
-			tableName, columns, values)
-		builder.WriteString(tableStr)
-	}
-	tableStrF := fmt.Sprintf(`tablesS := &[]table{
-		%s
-	}`, builder.String())
-	return tableStrF
+func addColumn(db tdb.Db, tableName string, column string) error {
+	tb, err := db.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	return tb.AddColumn(column)
 }
 
-// migrationColumnBuilder creates a string representation of column definitions.
-// It takes a slice of column definitions and returns them formatted as a string
-// suitable for use in migration files.
-func migrationColumnBuilder(column []string) string {
-	columnNames := make([]string, (len(column)/2)-1)
-	n := 0
-	for i := 3; i < len(column); i = i + 2 {
-		columnNames[n] = column[i]
-		n++
+func dropColumn(db tdb.Db, tableName string, column string) error {
+	tb, err := db.GetTableByName(tableName)
+	if err != nil {
+		return err
 	}
+	return tb.DeleteColumn(column)
+}
 
-	slices := stringSliceBuilder(columnNames)
-	columnStr := fmt.Sprintf("[]string{%s}", slices)
-	return columnStr
+func renameColumn(db tdb.Db, tableName string, column string, newName string) error {
+	tb, err := db.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	return tb.UpdateColumnName(column, newName)
 }
 
-// migrationValuesBuilder generates the values section of a migration for a specific table.
-// It takes a table name and data configuration, returning a string containing
-// the formatted values for the migration.
-func migrationValuesBuilder(tableName string, r []DataConfig) string {
-	var builder strings.Builder
-	for _, v := range r {
-		if v.TableName == strings.ReplaceAll(tableName, "-", "") {
-			for _, iv := range v.Values {
-				valuesS := stringSliceBuilder(iv)
-				a := fmt.Sprintf("{value: []string{%s}}", valuesS)
-				builder.WriteString(a)
-				builder.WriteString(",")
+func execSql(db tdb.Db, sql string) error {
+	_, err := db.FromSql(sql)
+	return err
+}
+
+// createTables creates the tables of a migration converted from the first version of the generator,
+// the first value of every row is its id
+func createTables(db tdb.Db, tables []table) error {
+	for _, t := range tables {
+		if _, err := db.NewTable(t.name, t.columns); err != nil {
+			return err
+		}
+		columns := append([]string{"\"id\""}, t.columns...)
+		for i := 1; i < len(columns); i++ {
+			columns[i] = "\"" + strings.ReplaceAll(columns[i], "\"", "\"\"") + "\""
+		}
+		for _, v := range t.values {
+			values := make([]string, len(v.value))
+			for i, value := range v.value {
+				values[i] = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+			}
+			sql := fmt.Sprintf("INSERT INTO \"%s\" (%s) VALUES (%s)", t.name, strings.Join(columns, ", "), strings.Join(values, ", "))
+			if err := execSql(db, sql); err != nil {
+				return err
 			}
-			break
 		}
 	}
-	dataName := fmt.Sprintf("[]value{%s}", builder.String())
-	return dataName
+	return nil
+}`
+	migrations := fmt.Sprintf(`
+
+// Migrations returns every migration in execution order
+func Migrations() []tdb.Migration {
+	return []tdb.Migration{
+%s	}
 }
 
-// stringSliceBuilder converts a slice of strings into a comma-separated string
-// with proper quotation marks for use in migration files.
-func stringSliceBuilder(s []string) string {
-	var builder strings.Builder
-	for i := 0; i < len(s); i++ {
+// GenerateMigration applies the pending migrations to the database
+func GenerateMigration(db tdb.Db) error {
+	return tdb.Migrate(db, Migrations()...)
+}
+`, migrationsBuilder.String())
 
-		builder.WriteString(fmt.Sprintf(`"%s"`, s[i]))
-		if i != len(s)-1 {
-			builder.WriteString(",")
+	builder.WriteString(imports)
+	builder.WriteString(namesBuilder.String())
+	builder.WriteString(types)
+	builder.WriteString(helpers)
+	builder.WriteString(migrations)
+	return []byte(builder.String())
+}
+
+// upgradeLegacyMigrations rewrites the migration files written by the first version of the generator,
+// which declared a generate function instead of a tdb.Migration, so the constructor can list them
+// migrationPath: directory of the migrations
+func upgradeLegacyMigrations(migrationPath string) {
+	for _, fileName := range getMigrationsNames() {
+		path := migrationPath + "/" + fileName
+		code, ok := legacyMigrationBuilder(fileName, string(must(os.ReadFile(path))))
+		if ok {
+			errorHandler(os.WriteFile(path, code, 0755))
 		}
 	}
-	return builder.String()
+}
+
+// legacyMigrationBuilder converts a migration file of the first version of the generator, which created the tables
+// listed in tablesS inside a generate function, into a migration that creates them with the createTables helper
+// fileName: name of the migration file
+// content: content of the migration file
+// Returns false if the file is not a legacy migration
+func legacyMigrationBuilder(fileName string, content string) ([]byte, bool) {
+	migrationId := strings.TrimSuffix(fileName, ".go")
+	migrationName := strings.Split(migrationId, "_Migration_")[0]
+	function := fmt.Sprintf("func generate%s() {", upperCase(migrationName))
+	start := strings.Index(content, function)
+	tablesStart := strings.Index(content, "tablesS := &[]table{")
+	tablesEnd := strings.Index(content, "\n\tconfig := tdb.DbConfig{")
+	if start == -1 || tablesStart < start || tablesEnd < tablesStart {
+		return nil, false
+	}
+	constants := ""
+	if index := strings.Index(content, "\t// migrationName"); index != -1 && index < start {
+		constants = "\n" + strings.TrimRight(content[index:start], "\n") + "\n"
+	}
+	// files written without DataConfig left the values of every table empty
+	tables := strings.ReplaceAll(content[tablesStart:tablesEnd], "values:  ,", "values:  nil,")
+	migration := fmt.Sprintf(`
+package migrations
+
+import "github.com/sheymor21/text-database/tdb"
+%s
+var %sMigration = tdb.Migration{
+	ID: %q,
+	Up: func(db tdb.Db) error {
+		%s
+		return createTables(db, *tablesS)
+	},
+}
+`, constants, upperCase(migrationName), migrationId, tables)
+	return []byte(migration), true
 }
 
 // upperCase converts the first character of a string to uppercase.
@@ -234,7 +938,7 @@ func getMigrationsNames() []string {
 	}
 	var fileNames []string
 	for _, entry := range entries {
-		if entry.Name() != "constructor.go" {
+		if strings.Contains(entry.Name(), "_Migration_") && strings.HasSuffix(entry.Name(), ".go") {
 			fileNames = append(fileNames, entry.Name())
 		}
 	}
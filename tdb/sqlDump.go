@@ -0,0 +1,187 @@
+package tdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Dump writes the database as an SQL script: a CREATE TABLE statement for every table, with its foreign keys,
+// followed by an INSERT statement for every row. The referenced tables come first, so the script can be
+// replayed by Restore or run in SQLite and PostgreSQL, where every column is TEXT and id is the primary key.
+// The system tables, such as the applied migrations, are not dumped.
+//
+// Example:
+//
+//	file, _ := os.Create("backup.sql")
+//	defer file.Close()
+//	err := db.Dump(file)
+func (d *db) Dump(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "-- text-database dump of %s\n", d.GetName())
+	keys := getForeignKeys()
+	var tables []table
+	for _, tb := range getTables(true) {
+		if !isSystemTable(tb.getSimpleName()) {
+			tables = append(tables, tb)
+		}
+	}
+	for _, tb := range seedOrder(tables) {
+		name := tb.getSimpleName()
+		columns := tableColumnNames(tb)
+		var definitions []string
+		for _, column := range columns {
+			definition := sqlIdentifier(column) + " TEXT"
+			if column == "id" {
+				definition += " PRIMARY KEY"
+			}
+			for _, key := range keys {
+				if key.ForeignTableName == name && key.ForeignColumnName == column && key.DefaultValue != "" {
+					definition += " DEFAULT " + sqlLiteral(key.DefaultValue)
+					break
+				}
+			}
+			definitions = append(definitions, definition)
+		}
+		for _, key := range keys {
+			if key.ForeignTableName != name {
+				continue
+			}
+			definition := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)",
+				sqlIdentifier(key.ForeignColumnName), sqlIdentifier(key.TableName), sqlIdentifier(key.ColumnName))
+			if key.OnDelete != "" && key.OnDelete != NoAction {
+				definition += " ON DELETE " + string(key.OnDelete)
+			}
+			if key.OnUpdate != "" && key.OnUpdate != NoAction {
+				definition += " ON UPDATE " + string(key.OnUpdate)
+			}
+			definitions = append(definitions, definition)
+		}
+		fmt.Fprintf(writer, "\nCREATE TABLE %s (\n  %s\n);\n", sqlIdentifier(name), strings.Join(definitions, ",\n  "))
+
+		identifiers := make([]string, len(columns))
+		for i, column := range columns {
+			identifiers[i] = sqlIdentifier(column)
+		}
+		for _, row := range tb.GetRows() {
+			values := make([]string, len(columns))
+			for i, column := range columns {
+				values[i] = sqlLiteral(strings.ReplaceAll(row.SearchValue(column), "U+0020", " "))
+			}
+			fmt.Fprintf(writer, "INSERT INTO %s (%s) VALUES (%s);\n", sqlIdentifier(name), strings.Join(identifiers, ", "), strings.Join(values, ", "))
+		}
+	}
+	return writer.Flush()
+}
+
+// Restore replays an SQL script written by Dump, or any script of statements supported by FromSql.
+// The script runs in a transaction, so nothing is restored if a statement fails;
+// inside a transaction started with Begin it is left to the caller to roll back.
+// The statements are applied in memory and the database is written once. The foreign keys are checked
+// when the script ends, so a row can reference one that comes later, as in self-referencing tables.
+// Returns a ForeignKeyViolationError if a reference is still broken
+//
+// Example:
+//
+//	file, _ := os.Open("backup.sql")
+//	defer file.Close()
+//	err := db.Restore(file)
+func (d *db) Restore(r io.Reader) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	script, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	ownTransaction := activeTransaction == nil
+	if ownTransaction {
+		if err = d.Begin(); err != nil {
+			return err
+		}
+	}
+	deferred := deferForeignKeys
+	deferForeignKeys = true
+	err = bufferWrites(func() error {
+		for _, statement := range mergeInserts(splitSqlStatements(string(script))) {
+			if _, err := d.FromSql(statement); err != nil {
+				return fmt.Errorf("%s: %w", statement, err)
+			}
+		}
+		if deferred {
+			// the caller defers the checks to its own commit
+			return nil
+		}
+		return checkAllForeignKeys()
+	})
+	deferForeignKeys = deferred
+	if err != nil {
+		if ownTransaction {
+			_ = d.Rollback()
+		}
+		return err
+	}
+	if ownTransaction {
+		return d.Commit()
+	}
+	return nil
+}
+
+// mergeInserts joins consecutive INSERT statements into the same table and columns into multi-row statements,
+// so Restore saves a table once per batch of rows instead of once per row
+func mergeInserts(statements []string) []string {
+	var merged []string
+	prefix, rows := "", 0
+	for _, statement := range statements {
+		index := sqlValuesIndex(statement)
+		if index != -1 && rows > 0 && rows < importBatchSize && statement[:index] == prefix {
+			merged[len(merged)-1] += ", " + strings.TrimSpace(statement[index+len("VALUES"):])
+			rows++
+			continue
+		}
+		merged = append(merged, statement)
+		prefix, rows = "", 0
+		if index != -1 {
+			prefix, rows = statement[:index], 1
+		}
+	}
+	return merged
+}
+
+// sqlValuesIndex returns the index of the VALUES keyword of an INSERT statement, outside the quoted names and values
+// Returns -1 if the statement is not an INSERT
+func sqlValuesIndex(statement string) int {
+	if len(statement) < 6 || !strings.EqualFold(statement[:6], "INSERT") {
+		return -1
+	}
+	var quote byte
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case (c == 'V' || c == 'v') && i > 0 && i+6 < len(statement) && strings.EqualFold(statement[i:i+6], "VALUES") &&
+			strings.ContainsRune(" \n\t)", rune(statement[i-1])) && strings.ContainsRune(" \n\t(", rune(statement[i+6])):
+			return i
+		}
+	}
+	return -1
+}
+
+// sqlIdentifier quotes a table or column name
+func sqlIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlLiteral quotes a value as an SQL string, null is NULL
+func sqlLiteral(value string) string {
+	if value == "null" {
+		return "NULL"
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,125 @@
+package tdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var sqlMigrationFileRegex = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// sqlMigrationFiles is the content of the up and down files of a SQL migration.
+type sqlMigrationFiles struct {
+	number int
+	id     string
+	up     *string
+	down   *string
+}
+
+// LoadSqlMigrations reads the migrations of the numbered NNNN_name.up.sql and NNNN_name.down.sql files of fsys,
+// sorted by number. The ID of a migration is NNNN_name and its statements run through FromSql,
+// a down file is optional. Other files are ignored.
+// The checksum of every migration is the SHA-256 of its files, so Migrate refuses an applied migration that was edited.
+//
+// Example:
+//
+//	//go:embed migrations/*.sql
+//	var migrationFiles embed.FS
+//
+//	files, _ := fs.Sub(migrationFiles, "migrations")
+//	migrations, err := tdb.LoadSqlMigrations(files)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = tdb.Migrate(db, migrations...)
+func LoadSqlMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*sqlMigrationFiles{}
+	numbers := map[int]string{}
+	for _, entry := range entries {
+		match := sqlMigrationFileRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		id := match[1] + "_" + match[2]
+		if other, ok := numbers[number]; ok && other != id {
+			return nil, fmt.Errorf("duplicate migration number: %s and %s", other, id)
+		}
+		numbers[number] = id
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if files[id] == nil {
+			files[id] = &sqlMigrationFiles{number: number, id: id}
+		}
+		script := string(content)
+		if match[3] == "up" {
+			files[id].up = &script
+		} else {
+			files[id].down = &script
+		}
+	}
+
+	sorted := make([]*sqlMigrationFiles, 0, len(files))
+	for _, f := range files {
+		if f.up == nil {
+			return nil, fmt.Errorf("migration %s has no up file", f.id)
+		}
+		sorted = append(sorted, f)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].number < sorted[j].number })
+	migrations := make([]Migration, len(sorted))
+	for i, f := range sorted {
+		migrations[i] = sqlMigration(f)
+	}
+	return migrations, nil
+}
+
+// LoadSqlMigrationsDir reads the SQL migrations of the directory, see LoadSqlMigrations
+//
+// Example:
+//
+//	migrations, err := tdb.LoadSqlMigrationsDir("./migrations")
+func LoadSqlMigrationsDir(path string) ([]Migration, error) {
+	return LoadSqlMigrations(os.DirFS(path))
+}
+
+// sqlMigration creates the migration that runs the scripts of the files
+func sqlMigration(f *sqlMigrationFiles) Migration {
+	up := *f.up
+	m := Migration{
+		ID: f.id,
+		Up: func(db Db) error { return runSqlScript(db, up) },
+	}
+	source := up + "\x00"
+	if f.down != nil {
+		down := *f.down
+		m.Down = func(db Db) error { return runSqlScript(db, down) }
+		source += down
+	}
+	sum := sha256.Sum256([]byte(source))
+	m.Checksum = hex.EncodeToString(sum[:])
+	return m
+}
+
+// runSqlScript runs the statements of the script in order through FromSql,
+// statements are separated by ; and the lines starting with -- are comments
+// Returns the error of the first failing statement
+func runSqlScript(db Db, script string) error {
+	for _, statement := range splitSqlStatements(script) {
+		if _, err := db.FromSql(statement); err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"unicode"
 )
 
 type SqlRows struct {
@@ -13,22 +14,26 @@ type SqlRows struct {
 }
 
 // validateSql validates and processes SQL queries, returning the query results and any errors.
-// It supports SELECT, UPDATE, DELETE, INSERT, and DROP operations.
+// It supports SELECT, UPDATE, DELETE, INSERT, DROP, CREATE TABLE and ALTER TABLE operations.
 func validateSql(d db, sql string) (SqlRows, error) {
-	sql = strings.ReplaceAll(sql, ",", " ")
-	sql = strings.ReplaceAll(sql, "(", " ")
-	sql = strings.ReplaceAll(sql, ")", " ")
-	sqlS := strings.Split(sql, " ")
-	sqlS = removeEmptyIndex(sqlS)
+	sqlS := sqlTokens(sql)
+	if len(sqlS) == 0 {
+		return SqlRows{}, &SqlSyntaxError{itemName: "sql option"}
+	}
 	sqlS[0] = strings.ToUpper(sqlS[0])
+	if readOnly && sqlS[0] != "SELECT" {
+		return SqlRows{}, ErrReadOnly
+	}
+	if name := sqlTableName(sqlS); isSystemTable(name) {
+		return SqlRows{}, &NotFoundError{itemName: "Table: " + name}
+	}
 	switch sqlS[0] {
 	case "SELECT":
 		upper := strings.ToUpper(sql)
 		if !strings.Contains(upper, "FROM") {
 			return SqlRows{}, &SqlSyntaxError{itemName: "FROM"}
 		}
-		result := sqlSelect(sqlS)
-		return result, nil
+		return sqlSelect(sqlS)
 	case "UPDATE":
 		if strings.ToUpper(sqlS[2]) != "SET" {
 			return SqlRows{}, &SqlSyntaxError{itemName: "SET"}
@@ -51,11 +56,267 @@ func validateSql(d db, sql string) (SqlRows, error) {
 	case "DROP":
 		err := sqlDrop(d, sqlS)
 		return SqlRows{}, err
+	case "CREATE":
+		if len(sqlS) < 4 || strings.ToUpper(sqlS[1]) != "TABLE" {
+			return SqlRows{}, &SqlSyntaxError{itemName: "TABLE"}
+		}
+		return SqlRows{}, sqlCreate(d, sql, sqlS)
+	case "ALTER":
+		if len(sqlS) < 5 || strings.ToUpper(sqlS[1]) != "TABLE" {
+			return SqlRows{}, &SqlSyntaxError{itemName: "TABLE"}
+		}
+		return SqlRows{}, sqlAlter(sqlS)
 	default:
 		return SqlRows{}, &SqlSyntaxError{itemName: "sql option"}
 	}
 }
 
+// sqlTableName returns the name of the table targeted by the query, or an empty string if it is not found
+func sqlTableName(sqlS []string) string {
+	index := -1
+	switch sqlS[0] {
+	case "SELECT", "DELETE":
+		if from := slices.Index(sqlS, "FROM"); from != -1 {
+			index = from + 1
+		}
+	case "UPDATE":
+		index = 1
+	case "INSERT", "DROP", "ALTER":
+		index = 2
+	}
+	if index == -1 || index >= len(sqlS) {
+		return ""
+	}
+	return sqlS[index]
+}
+
+// sqlTokens splits a statement into its words. Whitespace, commas and parentheses separate the words,
+// a quoted string is part of a word without its quotes ('it”s' is it's), and an unquoted NULL is the null value
+func sqlTokens(sql string) []string {
+	var tokens []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	flush := func() {
+		if !inWord {
+			return
+		}
+		token := word.String()
+		if !quoted && strings.EqualFold(token, "NULL") {
+			token = "null"
+		}
+		tokens = append(tokens, token)
+		word.Reset()
+		inWord, quoted = false, false
+	}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			inWord, quoted = true, true
+			for i++; i < len(sql); i++ {
+				if sql[i] == c {
+					if i+1 < len(sql) && sql[i+1] == c {
+						i++
+					} else {
+						break
+					}
+				}
+				word.WriteByte(sql[i])
+			}
+		case c == ',' || c == '(' || c == ')' || unicode.IsSpace(rune(c)):
+			flush()
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// SplitSqlStatements splits a script into the statements that FromSql executes, separated by ';'
+// Comments starting with -- are removed, quoted strings may contain ';', -- and line breaks and are kept as written
+//
+// Example:
+//
+//	for _, statement := range tdb.SplitSqlStatements(script) {
+//		_, err := db.FromSql(statement)
+//	}
+func SplitSqlStatements(script string) []string {
+	return splitSqlStatements(script)
+}
+
+// splitSqlStatements splits a script into its statements, separated by ';'.
+// Comments starting with -- are removed, and quoted strings may contain ';', -- and line breaks
+func splitSqlStatements(script string) []string {
+	var statements []string
+	var statement strings.Builder
+	add := func() {
+		if text := strings.TrimSpace(statement.String()); text != "" {
+			statements = append(statements, text)
+		}
+		statement.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := i + 1
+			for ; end < len(script); end++ {
+				if script[end] == c {
+					if end+1 < len(script) && script[end+1] == c {
+						end++
+						continue
+					}
+					break
+				}
+			}
+			end = min(end, len(script)-1)
+			statement.WriteString(script[i : end+1])
+			i = end
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			statement.WriteByte('\n')
+		case c == ';':
+			add()
+		default:
+			statement.WriteByte(c)
+		}
+	}
+	add()
+	return statements
+}
+
+// sqlCreate handles CREATE TABLE Name (column [type] [DEFAULT value] [REFERENCES table (column)], ...,
+// FOREIGN KEY (column) REFERENCES table (column) [ON DELETE action] [ON UPDATE action]).
+// The types and the other constraints are ignored, the id column is always created
+func sqlCreate(d db, sql string, sqlS []string) error {
+	name := sqlS[2]
+	if _, err := getTableByName(name, false); err == nil {
+		return ErrTableExists
+	}
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start == -1 || end < start {
+		_, err := d.NewTable(name, sqlS[3:])
+		return err
+	}
+	var columns []string
+	var keys []ForeignKey
+	defaults := map[string]string{}
+	for _, definition := range splitSqlList(sql[start+1 : end]) {
+		tokens := sqlTokens(definition)
+		if len(tokens) == 0 {
+			continue
+		}
+		if strings.EqualFold(tokens[0], "CONSTRAINT") && len(tokens) > 2 {
+			tokens = tokens[2:]
+		}
+		switch strings.ToUpper(tokens[0]) {
+		case "PRIMARY", "UNIQUE", "CHECK":
+			continue
+		case "FOREIGN":
+			if len(tokens) < 3 || !strings.EqualFold(tokens[1], "KEY") {
+				return &SqlSyntaxError{itemName: "FOREIGN KEY"}
+			}
+			key, err := sqlReferences(tokens[3:])
+			if err != nil {
+				return err
+			}
+			key.ForeignColumnName = tokens[2]
+			keys = append(keys, key)
+			continue
+		}
+		column := tokens[0]
+		for i := 1; i < len(tokens); i++ {
+			switch strings.ToUpper(tokens[i]) {
+			case "DEFAULT":
+				if i+1 < len(tokens) {
+					defaults[column] = tokens[i+1]
+				}
+			case "REFERENCES":
+				key, err := sqlReferences(tokens[i:])
+				if err != nil {
+					return err
+				}
+				key.ForeignColumnName = column
+				keys = append(keys, key)
+			}
+		}
+		if column != "id" {
+			columns = append(columns, column)
+		}
+	}
+	if _, err := d.NewTable(name, columns); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		key.ForeignTableName = name
+		if key.TableName == "" {
+			key.TableName = name
+		}
+		key.DefaultValue = defaults[key.ForeignColumnName]
+		if err := d.AddForeignKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlReferences parses REFERENCES table (column) [ON DELETE action] [ON UPDATE action] into the referenced side of a key
+func sqlReferences(tokens []string) (ForeignKey, error) {
+	if len(tokens) < 3 || !strings.EqualFold(tokens[0], "REFERENCES") {
+		return ForeignKey{}, &SqlSyntaxError{itemName: "REFERENCES"}
+	}
+	key := ForeignKey{TableName: tokens[1], ColumnName: tokens[2]}
+	for i := 3; i+2 < len(tokens) && strings.EqualFold(tokens[i], "ON"); {
+		event := strings.ToUpper(tokens[i+1])
+		words := 1
+		if next := strings.ToUpper(tokens[i+2]); (next == "NO" || next == "SET") && i+3 < len(tokens) {
+			words = 2
+		}
+		action := ReferentialAction(strings.ToUpper(strings.Join(tokens[i+2:i+2+words], " ")))
+		switch event {
+		case "DELETE":
+			key.OnDelete = action
+		case "UPDATE":
+			key.OnUpdate = action
+		default:
+			return ForeignKey{}, &SqlSyntaxError{itemName: "ON DELETE or ON UPDATE"}
+		}
+		i += 2 + words
+	}
+	return key, nil
+}
+
+// splitSqlList splits a list by the commas that are not inside parentheses or quotes
+func splitSqlList(list string) []string {
+	var items []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, list[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, list[start:])
+}
+
 // sqlDrop handles DROP table operations by deleting the specified table from the database.
 func sqlDrop(d db, sqlS []string) error {
 	tableName := sqlS[2]
@@ -66,12 +327,43 @@ func sqlDrop(d db, sqlS []string) error {
 	return nil
 }
 
+// sqlAlter handles ALTER TABLE operations: ADD COLUMN, DROP COLUMN, RENAME COLUMN old TO new and RENAME TO new.
+func sqlAlter(sqlS []string) error {
+	tb, err := getTableByName(sqlS[2], false)
+	if err != nil {
+		return err
+	}
+	operation := strings.ToUpper(sqlS[3])
+	params := sqlS[4:]
+	if strings.ToUpper(params[0]) == "COLUMN" {
+		params = params[1:]
+	}
+	switch {
+	case operation == "ADD" && len(params) == 1:
+		return tb.AddColumn(params[0])
+	case operation == "DROP" && len(params) == 1:
+		return tb.DeleteColumn(params[0])
+	case operation == "RENAME" && len(params) == 2 && strings.ToUpper(params[0]) == "TO":
+		return tb.UpdateTableName(params[1])
+	case operation == "RENAME" && len(params) == 3 && strings.ToUpper(params[1]) == "TO":
+		return tb.UpdateColumnName(params[0], params[2])
+	default:
+		return &SqlSyntaxError{itemName: "ALTER TABLE operation"}
+	}
+}
+
 // sqlSelect processes SELECT queries by extracting data from specified tables and applying
 // any WHERE conditions to filter the results.
-func sqlSelect(sqlS []string) SqlRows {
+func sqlSelect(sqlS []string) (SqlRows, error) {
 	index := slices.Index(sqlS, "FROM")
+	if index == -1 || index+1 >= len(sqlS) {
+		return SqlRows{}, &SqlSyntaxError{itemName: "FROM"}
+	}
 	tableName := sqlS[index+1]
-	tb, _ := getTableByName(tableName, true)
+	tb, err := getTableByName(tableName, true)
+	if err != nil {
+		return SqlRows{}, err
+	}
 	rows := tb.GetRows()
 	columns := getSqlColumns(tb, sqlS)
 	whereParams := sqlWhere(sqlS)
@@ -99,7 +391,7 @@ func sqlSelect(sqlS []string) SqlRows {
 		AffectRows: 0,
 		Rows:       finalResult,
 	}
-	return *sqlRows
+	return *sqlRows, nil
 }
 
 // sqlWhere extracts and processes WHERE clause parameters from SQL queries.
@@ -129,15 +421,21 @@ func sqlUpdate(sqlS []string) (SqlRows, error) {
 	newS := sqlS[setIndex+1 : whereIndex]
 	newS = fixSqlParams(newS)
 	rows := tb.SearchAll(whereParams[0], whereParams[2])
-	for i := 0; i < len(newS); i += 3 {
-		for _, row := range rows {
-			err := tb.UpdateValue(newS[i], row.SearchValue("id"), newS[i+2])
-			if err != nil {
-				return SqlRows{}, err
+	// the statement is restored as a whole, so a refused row doesn't leave the previous ones written
+	err := applyReferentialChange(func() error {
+		for i := 0; i < len(newS); i += 3 {
+			for _, row := range rows {
+				if err := tb.UpdateValue(newS[i], row.SearchValue("id"), newS[i+2]); err != nil {
+					return err
+				}
 			}
 		}
+		tb.save()
+		return nil
+	})
+	if err != nil {
+		return SqlRows{}, err
 	}
-	tb.save()
 	return SqlRows{
 		AffectRows: len(rows),
 		Rows:       nil,
@@ -153,13 +451,21 @@ func sqlDelete(sqlS []string) (SqlRows, error) {
 	tb, _ := getTableByName(tableName, true)
 	whereParams := sqlWhere(sqlS)
 	rows := tb.SearchAll(whereParams[0], whereParams[2])
-	for _, row := range rows {
-		err := tb.DeleteRow(row.SearchValue("id"), false)
-		if err != nil {
-			return SqlRows{}, err
+	err := applyReferentialChange(func() error {
+		for _, row := range rows {
+			if _, err := tb.GetRowById(row.SearchValue("id")); err != nil {
+				continue
+			}
+			if err := tb.DeleteRow(row.SearchValue("id"), false); err != nil {
+				return err
+			}
 		}
+		tb.save()
+		return nil
+	})
+	if err != nil {
+		return SqlRows{}, err
 	}
-	tb.save()
 	return SqlRows{
 		AffectRows: len(rows),
 		Rows:       nil,
@@ -185,9 +491,24 @@ func sqlInsert(sqlS []string) (SqlRows, error) {
 
 	values := sqlS[valuesIndex+1:]
 	a := divideEachNewRow(len(columns), values)
+	// every row is checked before the table is saved once, so a refused row leaves the table unchanged
+	header := tableHeader(tb.rawTable)
+	keys := getForeignKeys()
+	lines := make([]string, 0, len(a))
+	var inserted []Row
 	for _, v := range a {
-		tb.addValuesIdGenerationOff(v)
+		rows := make([]Row, len(v))
+		for i, value := range v {
+			rows[i] = Row{columns: tb.columns, value: value}
+		}
+		row := builtRow(tb, valuesBuilder(header, rows, false))
+		if err := checkInsertedRow(tableName, keys, row, inserted); err != nil {
+			return SqlRows{}, err
+		}
+		inserted = append(inserted, row)
+		lines = append(lines, row.value)
 	}
+	tb.rawTable = strings.Replace(tb.rawTable, "!*!", strings.Join(lines, "\n")+"\n!*!", 1)
 	tb.save()
 	d := len(a)
 	return SqlRows{
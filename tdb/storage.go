@@ -0,0 +1,320 @@
+package tdb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage defines the contract for persisting the raw content of a database.
+// Implementations always read and write the whole database at once.
+//
+// Example:
+//
+//	config := tdb.DbConfig{
+//		DatabaseName: "mydb.txt",
+//		Storage:      tdb.NewMemoryStorage(),
+//	}
+//	db, err := config.CreateDatabase()
+type Storage interface {
+
+	// Read returns the complete content of the database
+	Read() ([]byte, error)
+
+	// Write replaces the complete content of the database
+	// Implementations should never leave a partially written database behind
+	Write(data []byte) error
+
+	// Exists reports whether the database has already been created
+	Exists() bool
+}
+
+// Locker is an optional interface a Storage can implement to serialize access
+// to the underlying database while it is being read or written.
+// The lock is held for a single Read or Write only, so it makes each of them
+// atomic but does not turn a read-modify-write into one; concurrent writers
+// still need a transaction or their own synchronization.
+type Locker interface {
+	Lock()
+	Unlock()
+}
+
+// FileStorage stores the database in a file of the local filesystem.
+// An existing file keeps its permissions, new files are only readable by their owner.
+type FileStorage struct {
+	path string
+	perm os.FileMode
+	// fixedPerm gives every write perm, even when the file already exists
+	fixedPerm bool
+	mu        sync.Mutex
+}
+
+// MemoryStorage keeps the database in memory, it is useful for unit tests.
+type MemoryStorage struct {
+	data    []byte
+	created bool
+	mu      sync.Mutex
+}
+
+// FSStorage reads the database from a fs.FS, such as an embed.FS.
+// The storage is read-only, every Write returns ErrReadOnly.
+type FSStorage struct {
+	fsys fs.FS
+	name string
+}
+
+// StreamStorage stores the database in an io.ReadWriteSeeker.
+// If the stream implements Truncate(size int64) error, it is truncated after each write,
+// otherwise writing less data than the stream holds returns ErrStreamNotTruncatable.
+type StreamStorage struct {
+	rws io.ReadWriteSeeker
+	mu  sync.Mutex
+}
+
+var dbStorage Storage
+
+// NewFileStorage creates a Storage backed by the file at the given path
+//
+// Example:
+//
+//	storage := tdb.NewFileStorage("data/mydb.txt")
+func NewFileStorage(path string) *FileStorage {
+	return &FileStorage{path: path, perm: 0600}
+}
+
+// NewMemoryStorage creates an empty in-memory Storage
+//
+// Example:
+//
+//	storage := tdb.NewMemoryStorage()
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{}
+}
+
+// NewFSStorage creates a read-only Storage that reads the file name from fsys
+//
+// Example:
+//
+//	//go:embed data/mydb.txt
+//	var content embed.FS
+//
+//	storage := tdb.NewFSStorage(content, "data/mydb.txt")
+func NewFSStorage(fsys fs.FS, name string) *FSStorage {
+	return &FSStorage{fsys: fsys, name: name}
+}
+
+// NewStreamStorage creates a Storage backed by an io.ReadWriteSeeker
+//
+// Example:
+//
+//	file, _ := os.OpenFile("mydb.txt", os.O_RDWR|os.O_CREATE, 0644)
+//	storage := tdb.NewStreamStorage(file)
+func NewStreamStorage(rws io.ReadWriteSeeker) *StreamStorage {
+	return &StreamStorage{rws: rws}
+}
+
+// Read returns the content of the file.
+func (s *FileStorage) Read() ([]byte, error) {
+	return os.ReadFile(s.path)
+}
+
+// Write writes the data into a temporary file and renames it over the database file,
+// so readers never observe a half-written database.
+// A symlink is followed, so the file it points to is replaced and the link is kept.
+func (s *FileStorage) Write(data []byte) error {
+	path, err := filepath.EvalSymlinks(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		path = s.path
+	} else if err != nil {
+		return err
+	}
+	perm := s.perm
+	if info, statErr := os.Stat(path); statErr == nil && !s.fixedPerm {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+	}
+	return err
+}
+
+// Exists reports whether the database file exists.
+func (s *FileStorage) Exists() bool {
+	return isFileExist(s.path)
+}
+
+// Lock locks the storage.
+func (s *FileStorage) Lock() {
+	s.mu.Lock()
+}
+
+// Unlock unlocks the storage.
+func (s *FileStorage) Unlock() {
+	s.mu.Unlock()
+}
+
+// Read returns a copy of the stored data.
+func (s *MemoryStorage) Read() ([]byte, error) {
+	return bytes.Clone(s.data), nil
+}
+
+// Write replaces the stored data with a copy of data.
+func (s *MemoryStorage) Write(data []byte) error {
+	s.data = bytes.Clone(data)
+	s.created = true
+	return nil
+}
+
+// Exists reports whether the storage has been written at least once.
+func (s *MemoryStorage) Exists() bool {
+	return s.created
+}
+
+// Lock locks the storage.
+func (s *MemoryStorage) Lock() {
+	s.mu.Lock()
+}
+
+// Unlock unlocks the storage.
+func (s *MemoryStorage) Unlock() {
+	s.mu.Unlock()
+}
+
+// Read returns the content of the file inside the fs.FS.
+func (s *FSStorage) Read() ([]byte, error) {
+	return fs.ReadFile(s.fsys, s.name)
+}
+
+// Write always returns ErrReadOnly.
+func (s *FSStorage) Write(data []byte) error {
+	return ErrReadOnly
+}
+
+// Exists reports whether the file exists inside the fs.FS.
+func (s *FSStorage) Exists() bool {
+	if _, err := fs.Stat(s.fsys, s.name); errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	return true
+}
+
+// Read returns the whole content of the stream.
+func (s *StreamStorage) Read() ([]byte, error) {
+	if _, err := s.rws.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(s.rws)
+}
+
+// Write replaces the content of the stream, a stream can't be written atomically.
+// Returns ErrStreamNotTruncatable, without writing, if the old content would be left after the data
+func (s *StreamStorage) Write(data []byte) error {
+	t, truncatable := s.rws.(interface{ Truncate(size int64) error })
+	if !truncatable {
+		size, err := s.rws.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if size > int64(len(data)) {
+			return ErrStreamNotTruncatable
+		}
+	}
+	if _, err := s.rws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := s.rws.Write(data); err != nil {
+		return err
+	}
+	if truncatable {
+		return t.Truncate(int64(len(data)))
+	}
+	return nil
+}
+
+// Exists reports whether the stream has any content.
+func (s *StreamStorage) Exists() bool {
+	size, err := s.rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false
+	}
+	return size > 0
+}
+
+// Lock locks the storage.
+func (s *StreamStorage) Lock() {
+	s.mu.Lock()
+}
+
+// Unlock unlocks the storage.
+func (s *StreamStorage) Unlock() {
+	s.mu.Unlock()
+}
+
+// isReadOnlyStorage reports whether the storage can never be written
+func isReadOnlyStorage(s Storage) bool {
+	_, ok := s.(*FSStorage)
+	return ok
+}
+
+// lockDatabase locks the current storage if it implements Locker
+// Returns the function that releases the lock
+// Callers hold it around a single Read or Write, never across both
+func lockDatabase() func() {
+	if l, ok := dbStorage.(Locker); ok {
+		l.Lock()
+		return l.Unlock
+	}
+	return func() {}
+}
+
+// readDatabase reads the raw content of the current database from its storage.
+func readDatabase() []byte {
+	unlock := lockDatabase()
+	defer unlock()
+	return must(dbStorage.Read())
+}
+
+// writeDatabase replaces the raw content of the current database in its storage.
+func writeDatabase(data []byte) {
+	unlock := lockDatabase()
+	defer unlock()
+	errorHandler(dbStorage.Write(data))
+}
+
+// bufferWrites runs fn with the storage replaced by an in-memory copy of the database and writes the result once,
+// so a sequence of changes costs a single write. Nothing is written if fn fails.
+// Like every operation on the current database it must not run concurrently with other operations on it
+func bufferWrites(fn func() error) error {
+	storage := dbStorage
+	memory := NewMemoryStorage()
+	errorHandler(memory.Write(readDatabase()))
+	dbStorage = memory
+	err := fn()
+	dbStorage = storage
+	if err != nil {
+		return err
+	}
+	writeDatabase(must(memory.Read()))
+	return nil
+}
@@ -1,11 +1,13 @@
 package tdb
 
 import (
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"os"
+	"io"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -44,16 +46,16 @@ type Table interface {
 	// Example usage:
 	//
 	//	// Add multiple values at once
-	//	table.AddValues("John Doe", "john@example.com", "active")
-	AddValues(values ...string)
+	//	err := table.AddValues("John Doe", "john@example.com", "active")
+	AddValues(values ...string) error
 
 	// UpdateTableName changes the name of the table.
 	//
 	// Example usage:
 	//
 	//	// Rename table from "users" to "customers"
-	//	table.UpdateTableName("customers")
-	UpdateTableName(newName string)
+	//	err := table.UpdateTableName("customers")
+	UpdateTableName(newName string) error
 
 	// UpdateColumnName changes the name of a column.
 	// Returns an error if the column doesn't exist.
@@ -80,6 +82,14 @@ type Table interface {
 	//	err := table.DeleteRow("user123", true)
 	DeleteRow(id string, cascade bool) error
 
+	// AddColumn appends a new column to the table, the existing rows get a null value.
+	// Returns an error if the column already exists.
+	//
+	// Example usage:
+	//
+	//	err := table.AddColumn("phone")
+	AddColumn(columnName string) error
+
 	// DeleteColumn removes a column from the table.
 	// Returns an error if the column doesn't exist.
 	//
@@ -176,9 +186,32 @@ type Table interface {
 	//	related, err := table.SearchByForeignKey("customer_123")
 	SearchByForeignKey(id string) ([]ComplexRow, error)
 
+	// ExportCSV writes the table as CSV with a header of its column names, id included.
+	//
+	// Example usage:
+	//
+	//	file, _ := os.Create("users.csv")
+	//	defer file.Close()
+	//	err := table.ExportCSV(file)
+	ExportCSV(w io.Writer) error
+
+	// ExportJSON writes the rows of the table as an array of objects keyed by column name, id included.
+	//
+	// Example usage:
+	//
+	//	err := table.ExportJSON(os.Stdout)
+	ExportJSON(w io.Writer) error
+
+	// ExportNDJSON writes the rows of the table as newline-delimited JSON, an object per line.
+	//
+	// Example usage:
+	//
+	//	err := table.ExportNDJSON(file)
+	ExportNDJSON(w io.Writer) error
+
 	// Internal methods used by the package implementation
 	getSimpleName() string
-	addValuesIdGenerationOff(values []string)
+	addValuesIdGenerationOff(values []string) error
 	table() table
 	save()
 }
@@ -245,10 +278,16 @@ func (t *table) getSimpleName() string {
 // It requires the column name and the value to be added as arguments.
 // Returns an error if the column does not exist or if there is an issue during the value addition process.
 func (t *table) AddValue(column string, value string) error {
+	if readOnly {
+		return ErrReadOnly
+	}
 	s, err := valueBuilder(*t, column, value)
 	if err != nil {
 		return err
 	}
+	if err = checkForeignKeys(t.getSimpleName(), builtRow(*t, s)); err != nil {
+		return err
+	}
 
 	t.rawTable = strings.Replace(t.rawTable, "!*!", s, 1)
 	t.save()
@@ -267,11 +306,24 @@ func (t *table) GetName() string {
 
 // AddValues appends one or more values to the table and updates its internal representation.
 // Each string in the `values` parameter represents a new row of data to be added.
-func (t *table) AddValues(values ...string) {
-	*t = addValues(*t, values, true)
+func (t *table) AddValues(values ...string) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	tb, err := addValues(*t, values, true)
+	if err != nil {
+		return err
+	}
+	*t = tb
+	return nil
 }
-func (t *table) addValuesIdGenerationOff(values []string) {
-	*t = addValues(*t, values, false)
+func (t *table) addValuesIdGenerationOff(values []string) error {
+	tb, err := addValues(*t, values, false)
+	if err != nil {
+		return err
+	}
+	*t = tb
+	return nil
 }
 func (t *table) GetColumns() []string {
 	return getColumns(t.rawTable)
@@ -279,25 +331,48 @@ func (t *table) GetColumns() []string {
 
 // UpdateTableName changes the name of the table to the specified new name.
 // The change is persisted to storage automatically.
+// Returns ErrReadOnly if the database is read-only, and ErrEncryptedColumn if the table has encrypted columns,
+// since they are configured by name when the database is opened.
 //
 // Example usage:
 //
 //	// Rename a table from "users" to "customers"
-//	table.UpdateTableName("customers")
-func (t *table) UpdateTableName(newName string) {
+//	err := table.UpdateTableName("customers")
+func (t *table) UpdateTableName(newName string) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	if isSystemTable(newName) {
+		return ErrReservedName
+	}
+	if hasEncryptedColumn(t.getSimpleName(), "") {
+		return ErrEncryptedColumn
+	}
 	formatName := strings.Replace(t.nameRaw, "-----", "", 2)
 	formatName = formatName + "_End"
 	formatName = fmt.Sprintf("-----%s-----", formatName)
 	rawNewName := fmt.Sprintf("-----%s-----", newName)
 	rawNewNameEnd := fmt.Sprintf("-----%s-----", newName+"_End")
 
+	oldName := t.getSimpleName()
 	t.rawTable = strings.Replace(t.rawTable, t.nameRaw, rawNewName, 1)
 	t.rawTable = strings.Replace(t.rawTable, formatName, rawNewNameEnd, 1)
 	t.save()
+	t.nameRaw = rawNewName
+	updateForeignKeys(func(key ForeignKey) (ForeignKey, bool) {
+		if key.TableName == oldName {
+			key.TableName = newName
+		}
+		if key.ForeignTableName == oldName {
+			key.ForeignTableName = newName
+		}
+		return key, true
+	})
+	return nil
 }
 
 // UpdateColumnName changes the name of a column from oldColumnName to newColumnName.
-// Returns an error if the column doesn't exist in the table.
+// Returns an error if the column doesn't exist in the table, and ErrEncryptedColumn if the column is encrypted.
 //
 // Example usage:
 //
@@ -308,13 +383,29 @@ func (t *table) UpdateTableName(newName string) {
 //	    fmt.Println("Column not found:", err)
 //	}
 func (t *table) UpdateColumnName(oldColumnName string, newColumnName string) error {
+	if readOnly {
+		return ErrReadOnly
+	}
 	index := slices.Index(t.columns, oldColumnName)
 	if index == -1 {
 		return &NotFoundError{itemName: "Column"}
 	}
+	if hasEncryptedColumn(t.getSimpleName(), oldColumnName) {
+		return ErrEncryptedColumn
+	}
 	t.columns[index] = newColumnName
 	t.rawTable = strings.Replace(t.rawTable, oldColumnName, newColumnName, 1)
 	t.save()
+	name := t.getSimpleName()
+	updateForeignKeys(func(key ForeignKey) (ForeignKey, bool) {
+		if key.TableName == name && key.ColumnName == oldColumnName {
+			key.ColumnName = newColumnName
+		}
+		if key.ForeignTableName == name && key.ForeignColumnName == oldColumnName {
+			key.ForeignColumnName = newColumnName
+		}
+		return key, true
+	})
 	return nil
 
 }
@@ -330,7 +421,9 @@ func (t *table) UpdateColumnName(oldColumnName string, newColumnName string) err
 //	// Update status of an order
 //	err := table.UpdateValue("status", "order_456", "shipped")
 func (t *table) UpdateValue(columnName string, id string, newValue string) error {
-
+	if readOnly {
+		return ErrReadOnly
+	}
 	index := slices.Index(t.columns, columnName)
 	if index == -1 {
 		return &NotFoundError{itemName: "Column"}
@@ -339,17 +432,32 @@ func (t *table) UpdateValue(columnName string, id string, newValue string) error
 	if rowErr != nil {
 		return rowErr
 	}
-	rowSlice := strings.Split(row.value, "|")
-	rowSlice[index+1] = " " + newValue + " "
-	row.value = strings.Join(rowSlice, "|")
-	row.value = strings.Trim(row.value, " ")
-	updateTable, err := updateRow(t.rawTable, id, row.value)
-	if err != nil {
+	newRow := replaceRowValue(row, index, newValue)
+	name := t.getSimpleName()
+	if err := checkForeignKeys(name, newRow); err != nil {
 		return err
 	}
-	t.rawTable = updateTable
-	t.save()
-	return nil
+	return applyReferentialChange(func() error {
+		change := newReferentialChange(false)
+		if row.SearchValue(columnName) != newRow.SearchValue(columnName) {
+			if err := change.onUpdate(name, row, columnName, newValue); err != nil {
+				return err
+			}
+		}
+		if change.changed {
+			tb, err := getTableByName(name, true)
+			if err != nil {
+				return err
+			}
+			*t = tb
+		}
+		tb, err := setRowValue(*t, columnName, id, newValue)
+		if err != nil {
+			return err
+		}
+		*t = tb
+		return nil
+	})
 }
 func (t *table) GetRows() Rows {
 	values := getRows(t.rawTable)
@@ -367,7 +475,9 @@ func (t *table) GetRowById(id string) (Row, error) {
 }
 
 // DeleteRow removes a row from the table by its ID.
-// If cascade is true, it also deletes any related rows in other tables that reference this row.
+// The ON DELETE action of every foreign key that references the row is applied to the referencing rows,
+// a NO ACTION or RESTRICT key returns a ForeignKeyViolationError if another table references the row.
+// If cascade is true, the referencing rows are deleted whatever the action of the foreign key.
 // Returns an error if the row doesn't exist or if there's an issue with cascade deletion.
 //
 // Example usage:
@@ -378,24 +488,77 @@ func (t *table) GetRowById(id string) (Row, error) {
 //	// Delete a row and its related records
 //	err := table.DeleteRow("456", true)
 func (t *table) DeleteRow(id string, cascade bool) error {
-	newTable, err := deleteRow(*t, id)
+	if readOnly {
+		return ErrReadOnly
+	}
+	row, err := t.GetRowById(id)
 	if err != nil {
 		return err
 	}
-	if cascade {
-		foreignKeyErr := deleteByForeignKey(*t, id)
-		if foreignKeyErr != nil {
-			return foreignKeyErr
+	name := t.getSimpleName()
+	return applyReferentialChange(func() error {
+		change := newReferentialChange(cascade)
+		if err := change.onDelete(name, row); err != nil {
+			return err
 		}
+		if change.changed {
+			tb, err := getTableByName(name, true)
+			if err != nil {
+				return err
+			}
+			*t = tb
+		}
+		newTable, err := deleteRow(*t, id)
+		if err != nil {
+			return err
+		}
+		*t = newTable
+		return nil
+	})
+}
+func (t *table) AddColumn(columnName string) error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	if strings.TrimSpace(columnName) == "" || strings.Contains(columnName, " ") {
+		return errors.New("invalid column name")
+	}
+	if slices.Contains(t.columns, columnName) {
+		return errors.New("column already exist")
+	}
+	name := t.getSimpleName()
+	stored, err := getTableByName(name, false)
+	if err != nil {
+		return err
+	}
+	position := 1
+	if markers := columnMarkerRegex.FindAllStringSubmatch(strings.Join(stored.columns, " "), -1); len(markers) > 0 {
+		position, _ = strconv.Atoi(markers[len(markers)-1][1])
+		position++
+	}
+	stored.columns = append(stored.columns, fmt.Sprintf("[%d]", position), columnName)
+	lines := strings.Split(stored.rawTable, "\n")
+	lines[2] = strings.Join(stored.columns, " ")
+	for i := 3; i < len(lines)-3; i++ {
+		lines[i] += fmt.Sprintf(" |%d| null", position)
 	}
-	*t = newTable
+	stored.rawTable = strings.Join(lines, "\n")
+	stored.save()
+	*t, _ = getTableByName(name, true)
 	return nil
 }
 func (t *table) DeleteColumn(columnName string) error {
+	if readOnly {
+		return ErrReadOnly
+	}
 	index := slices.Index(t.columns, columnName)
 	if index == -1 {
 		return &NotFoundError{itemName: "Column"}
 	}
+	name := t.getSimpleName()
+	if stored, err := getTableByName(name, false); err == nil {
+		t.rawTable = stored.rawTable
+	}
 	t.columns = slices.Delete(t.columns, index, index+1)
 	position := fmt.Sprintf("[%d]", index-1)
 	t.columns = slices.Replace(t.columns, index-1, index, position)
@@ -410,14 +573,16 @@ func (t *table) DeleteColumn(columnName string) error {
 	t.rawTable = strings.Join(newTable, "\n")
 	t.rawTable = deleteColumnData(t.rawTable, index)
 	t.save()
+	updateForeignKeys(func(key ForeignKey) (ForeignKey, bool) {
+		return key, !(key.TableName == name && key.ColumnName == columnName) &&
+			!(key.ForeignTableName == name && key.ForeignColumnName == columnName)
+	})
+	*t, _ = getTableByName(name, true)
 	return nil
 }
 func (t *table) SearchOne(column string, value string) (Row, error) {
-	rows := t.GetRows()
-	index := slices.Index(rows[0].columns, column)
-	for _, r := range rows {
-		row := strings.Split(r.value, " ")
-		if row[index] == value {
+	for _, r := range t.GetRows() {
+		if r.SearchValue(column) == value {
 			return r, nil
 		}
 	}
@@ -451,32 +616,62 @@ func (t *table) save() {
 	for i, v := range tables {
 		if v.GetName() == t.GetName() {
 			tables[i] = *t
+			tables[i].rawTable = encodeRowSpaces(*t)
 		}
 	}
 	saveTables(tables)
 }
-func deleteByForeignKey(tb table, id string) error {
-	key, err := tb.SearchByForeignKey(id)
-	if err != nil {
-		return err
-	}
-	for i, v := range key {
-		if v.Table.GetName() != tb.GetName() {
-			newTb := v.Table.table()
-			for _, j := range v.Rows {
-				rowId := j.SearchValue("id")
-				tbWithoutRow, deleteErr := deleteRow(newTb, rowId)
-				if deleteErr != nil {
-					return deleteErr
-				}
-				newTb = tbWithoutRow.table()
-			}
-			key[i].Table = &newTb
+
+// rowValues returns the values of the row in column order, splitting it by the |n| markers of the columns
+// so the values can contain spaces
+// Returns false if the markers of the row don't match the columns
+func rowValues(columns []string, value string) ([]string, bool) {
+	var positions []string
+	for i := 0; i < len(columns); i += 2 {
+		positions = append(positions, strings.Trim(columns[i], "[]"))
+	}
+	values := make([]string, len(positions))
+	rest := value
+	for k, position := range positions {
+		marker := "|" + position + "| "
+		if !strings.HasPrefix(rest, marker) {
+			return nil, false
 		}
+		rest = rest[len(marker):]
+		if k == len(positions)-1 {
+			values[k] = rest
+			break
+		}
+		next := "|" + positions[k+1] + "| "
+		if strings.HasPrefix(rest, next) {
+			continue
+		}
+		end := strings.Index(rest, " "+next)
+		if end == -1 {
+			return nil, false
+		}
+		values[k], rest = rest[:end], rest[end+1:]
 	}
-	return nil
+	return values, true
 }
 
+// encodeRowSpaces returns the raw table with the spaces inside the values encoded,
+// so a table read with the spaces decoded is saved in the stored format
+func encodeRowSpaces(t table) string {
+	lines := strings.Split(t.rawTable, "\n")
+	for i := 3; i < len(lines); i++ {
+		values, ok := rowValues(t.columns, lines[i])
+		if !ok {
+			continue
+		}
+		fields := make([]string, len(values))
+		for k, v := range values {
+			fields[k] = fmt.Sprintf("|%s| %s", strings.Trim(t.columns[k*2], "[]"), encodeValue(v))
+		}
+		lines[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(lines, "\n")
+}
 func (r *Rows) String() string {
 	s := make([]string, len(*r))
 	return strings.Join(s, "\n")
@@ -508,13 +703,73 @@ func (r *Row) SearchValue(column string) string {
 	if index == -1 {
 		return ""
 	}
+	if values, ok := rowValues(r.columns, r.value); ok && index%2 == 1 {
+		return decodeLineBreaks(values[index/2])
+	}
 	s := strings.Split(r.value, " ")
-	return s[index]
+	return decodeLineBreaks(s[index])
+}
+
+// encodeValue encodes the spaces and line breaks of a value so it is stored as a single field of a row
+func encodeValue(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	value = strings.ReplaceAll(value, "\n", "U+000A")
+	return strings.ReplaceAll(value, " ", "U+0020")
+}
+
+// decodeLineBreaks converts the encoded line breaks of a value back to line breaks
+func decodeLineBreaks(value string) string {
+	return strings.ReplaceAll(value, "U+000A", "\n")
 }
 func (r *Row) String() string {
 	return r.value
 }
 
+// Columns returns the column names of the row, in order
+//
+// Example usage:
+//
+//	for _, column := range row.Columns() {
+//		fmt.Println(column, row.SearchValue(column))
+//	}
+func (r *Row) Columns() []string {
+	columns := make([]string, 0, len(r.columns)/2)
+	for i := 1; i < len(r.columns); i += 2 {
+		columns = append(columns, r.columns[i])
+	}
+	return columns
+}
+
+// replaceRowValue returns the row with the value of the column at index replaced
+// index: index of the column name in the table columns
+func replaceRowValue(row Row, index int, value string) Row {
+	rowSlice := strings.Split(row.value, "|")
+	rowSlice[index+1] = " " + value + " "
+	row.value = strings.Trim(strings.Join(rowSlice, "|"), " ")
+	return row
+}
+
+// setRowValue writes the value in the column of the row and saves the table, without any foreign key check
+// Returns the updated table or an error if the column or the row doesn't exist
+func setRowValue(tb table, columnName string, id string, value string) (table, error) {
+	index := slices.Index(tb.columns, columnName)
+	if index == -1 {
+		return table{}, &NotFoundError{itemName: "Column"}
+	}
+	row, err := tb.GetRowById(id)
+	if err != nil {
+		return table{}, err
+	}
+	row = replaceRowValue(row, index, value)
+	updateTable, err := updateRow(tb.rawTable, id, row.value)
+	if err != nil {
+		return table{}, err
+	}
+	tb.rawTable = updateTable
+	tb.save()
+	return tb, nil
+}
+
 // deleteRow removes a row from the table by its ID and returns the updated table.
 // Returns an error if the row is not found.
 func deleteRow(tb table, id string) (table, error) {
@@ -560,37 +815,18 @@ func searchAll(tb table, column string, value string) Rows {
 // getTableForeignKey retrieves all foreign key relationships for the given table.
 // Returns an error if no foreign keys are found.
 func getTableForeignKey(tb table) ([]foreignKey, error) {
-	if !isForeignKeyAvailable(tb.getSimpleName()) {
-		return nil, &NotFoundError{itemName: "ForeignKey"}
-	}
 	var foreignKeys []foreignKey
-
-	link, _ := getTableByName("Links", false)
-	tb1 := searchAll(link, "table1", tb.getSimpleName())
-	for _, row := range tb1 {
-		tbName := row.SearchValue("table2")
-		columnLink := row.SearchValue("columnLink2")
-		foreignKeys = append(foreignKeys, foreignKey{tableName: tbName, column: columnLink})
-	}
-	return foreignKeys, nil
-}
-func isForeignKeyAvailable(tableName string) bool {
-	tb, err := getTableByName("Links", false)
-	if err != nil {
-		return false
-	}
-	rows := tb.GetRows()
-	for _, row := range rows {
-		v := row.SearchValue("table1")
-		if v == tableName {
-			return true
-		}
-		v = row.SearchValue("table2")
-		if v == tableName {
-			return true
+	available := false
+	for _, key := range getForeignKeys() {
+		if key.TableName == tb.getSimpleName() {
+			foreignKeys = append(foreignKeys, foreignKey{tableName: key.ForeignTableName, column: key.ForeignColumnName})
 		}
+		available = available || key.TableName == tb.getSimpleName() || key.ForeignTableName == tb.getSimpleName()
+	}
+	if !available {
+		return nil, &NotFoundError{itemName: "ForeignKey"}
 	}
-	return false
+	return foreignKeys, nil
 }
 func orderBy(r Rows, column string, ascend bool) ([]Row, error) {
 	newSlice := make([]Row, len(r))
@@ -605,13 +841,13 @@ func orderBy(r Rows, column string, ascend bool) ([]Row, error) {
 	}
 
 	sort.Slice(newSlice, func(i, j int) bool {
-		s := strings.Split(newSlice[i].value, " ")
-		s2 := strings.Split(newSlice[j].value, " ")
+		s := newSlice[i].SearchValue(column)
+		s2 := newSlice[j].SearchValue(column)
 		if ascend {
-			return s[columnIndex] > s2[columnIndex]
+			return s > s2
 		} else {
 
-			return s[columnIndex] < s2[columnIndex]
+			return s < s2
 		}
 	})
 	return newSlice, nil
@@ -671,8 +907,7 @@ func valueBuilder(table table, columnName string, value string) (string, error)
 
 	for i := 3; i < count; i += 2 {
 		if co[i] == columnName {
-			value = strings.ReplaceAll(value, " ", "U+0020")
-			co[i] = value
+			co[i] = encodeValue(value)
 		} else {
 			co[i] = "null"
 		}
@@ -704,7 +939,7 @@ func valuesBuilder(table string, values []Row, idGenerate bool) string {
 		if n > count {
 			break
 		}
-		co[n] = strings.ReplaceAll(r.value, " ", "U+0020")
+		co[n] = encodeValue(r.value)
 		n = n + 2
 
 	}
@@ -712,7 +947,21 @@ func valuesBuilder(table string, values []Row, idGenerate bool) string {
 	result := union + "\n!*!"
 	return result
 }
-func addValues(table table, values []string, idGenerate bool) table {
+
+// tableHeader returns the first lines of the raw table up to the column header,
+// enough for valuesBuilder without splitting every row of a large table
+func tableHeader(rawTable string) string {
+	return strings.Join(strings.SplitN(rawTable, "\n", 4)[:3], "\n")
+}
+
+// builtRow returns the row built by valueBuilder or valuesBuilder for the table
+func builtRow(table table, s string) Row {
+	return Row{columns: table.columns, value: strings.TrimSuffix(s, "\n!*!")}
+}
+
+// addValues appends a row with the values to the table and saves it
+// Returns a ForeignKeyViolationError if a value references a missing row
+func addValues(table table, values []string, idGenerate bool) (table, error) {
 	rows := make([]Row, len(values))
 	for i, v := range values {
 		rows[i] = Row{
@@ -721,9 +970,12 @@ func addValues(table table, values []string, idGenerate bool) table {
 		}
 	}
 	s := valuesBuilder(table.rawTable, rows, idGenerate)
+	if err := checkForeignKeys(table.getSimpleName(), builtRow(table, s)); err != nil {
+		return table, err
+	}
 	table.rawTable = strings.Replace(table.rawTable, "!*!", s, 1)
 	table.save()
-	return table
+	return table, nil
 }
 
 // saveTables writes the tables to the database file.
@@ -733,8 +985,5 @@ func saveTables(tables []table) {
 	if len(tables) != 0 {
 		newTable = addTableFrontiers(tables)
 	}
-	if encryptionKeyExist {
-		newTable = must(globalEncoderKey.Encode(newTable))
-	}
-	errorHandler(os.WriteFile(dbName, []byte(newTable), 0666))
+	saveDatabase(newTable)
 }
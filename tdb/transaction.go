@@ -0,0 +1,70 @@
+package tdb
+
+// transaction keeps the stored content of the database when the transaction started,
+// so it can be restored on rollback.
+type transaction struct {
+	snapshot []byte
+}
+
+var activeTransaction *transaction
+
+// Begin starts a transaction, the changes made until Commit can be undone with Rollback
+// Returns ErrTransactionInProgress if a transaction is already started
+//
+// Example:
+//
+//	err := db.Begin()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) Begin() error {
+	if readOnly {
+		return ErrReadOnly
+	}
+	if activeTransaction != nil {
+		return ErrTransactionInProgress
+	}
+	activeTransaction = &transaction{snapshot: readDatabase()}
+	return nil
+}
+
+// Commit ends the transaction and keeps its changes
+// When the foreign key checks are deferred they run now,
+// if a reference is broken the transaction is rolled back and the ForeignKeyViolationError is returned
+//
+// Example:
+//
+//	err := db.Commit()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) Commit() error {
+	if activeTransaction == nil {
+		return ErrNoTransaction
+	}
+	if deferForeignKeys {
+		if err := checkAllForeignKeys(); err != nil {
+			_ = d.Rollback()
+			return err
+		}
+	}
+	activeTransaction = nil
+	return nil
+}
+
+// Rollback ends the transaction and restores the database as it was when the transaction started
+//
+// Example:
+//
+//	err := db.Rollback()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func (d *db) Rollback() error {
+	if activeTransaction == nil {
+		return ErrNoTransaction
+	}
+	writeDatabase(activeTransaction.snapshot)
+	activeTransaction = nil
+	return nil
+}